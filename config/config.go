@@ -2,6 +2,10 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -9,52 +13,320 @@ import (
 )
 
 type JWTConfig struct {
-	Secret   string        `mapstructure:"secret"`
-	Duration time.Duration `mapstructure:"duration"`
+	// Secret HS256 密钥；未配置 keys 时必填，配置了 keys 后不再用于签发
+	Secret string `mapstructure:"secret" validate:"omitempty,min=16"`
+	// Duration access token 有效期，应尽量短
+	Duration time.Duration `mapstructure:"duration" validate:"required,gt=0"`
+	// RefreshDuration refresh token 有效期，为 0 时使用 30 天
+	RefreshDuration time.Duration `mapstructure:"refresh_duration" validate:"gte=0"`
+	// SigningKey 当前用于签发的密钥 kid，必须是 keys 中带私钥的一项
+	SigningKey string `mapstructure:"signing_key"`
+	// Keys 非对称密钥集合。轮换时先加入新密钥，再切换 signing_key，
+	// 旧密钥保留到其签发的 token 全部过期后再移除（可只保留公钥）
+	Keys []JWTKeyConfig `mapstructure:"keys" validate:"unique=ID,dive"`
 }
+
+// JWTKeyConfig 一把 JWT 签名密钥，PEM 文件的相对路径以配置文件所在目录为基准
+type JWTKeyConfig struct {
+	ID        string `mapstructure:"kid" validate:"required"`
+	Algorithm string `mapstructure:"algorithm" validate:"required,oneof=RS256 ES256 EdDSA"`
+	// PrivateKeyFile PKCS#1/PKCS#8/SEC1 私钥，签发用；只做校验的旧密钥可留空
+	PrivateKeyFile string `mapstructure:"private_key_file" validate:"required_without=PublicKeyFile"`
+	// PublicKeyFile PKIX 公钥，为空时从私钥推导
+	PublicKeyFile string `mapstructure:"public_key_file"`
+}
+
+// LogConfig 日志配置；除 level、overrides 外修改后需重启生效
 type LogConfig struct {
-	Level      string `mapstructure:"level"`
-	Filename   string `mapstructure:"filename"`    // 日志文件路径
-	MaxSize    int    `mapstructure:"max_size"`    // 每个文件最大 10MB
-	MaxBackups int    `mapstructure:"max_backups"` // 保留最近 5 个旧文件
-	MaxAge     int    `mapstructure:"max_age"`     // 保留最近 30 天的日志
+	// Level 全局日志级别，各 sink 在此之上再按自身 level 过滤
+	Level string `mapstructure:"level" validate:"omitempty,oneof=debug info warn error"`
+	// Overrides logger 名称 -> 级别，按名称前缀匹配（"db" 同时作用于 "db.pool"），可低于全局级别；修改后自动生效。
+	// 名称见 utils.NamedLogger 的调用处，如 database、ratelimit、analytics
+	Overrides map[string]string `mapstructure:"overrides" validate:"dive,oneof=debug info warn error"`
+	// Format sink 未指定格式时使用的编码：json、console 或 logfmt，默认 json
+	Format string `mapstructure:"format" validate:"omitempty,oneof=json console logfmt"`
+	// Filename 等滚动参数是 file sink 的默认值
+	Filename   string `mapstructure:"filename"`                     // 日志文件路径，默认 ./logs/app.log
+	MaxSize    int    `mapstructure:"max_size" validate:"gte=0"`    // 每个文件最大 MB，默认 10
+	MaxBackups int    `mapstructure:"max_backups" validate:"gte=0"` // 保留的旧文件数，默认 5
+	MaxAge     int    `mapstructure:"max_age" validate:"gte=0"`     // 旧文件保留天数，默认 30
 	Compress   bool   `mapstructure:"compress" `
+	// Sinks 输出目标；未配置时输出到 stdout（console 格式）与 Filename（json 格式）
+	Sinks []LogSinkConfig `mapstructure:"sinks" validate:"dive"`
+	// Service、Version 与主机名作为 service 字段附加到每条日志；
+	// Service 默认 echotest，Version 为空时取构建信息中的模块版本
+	Service string `mapstructure:"service"`
+	Version string `mapstructure:"version"`
+	// BodyDump 请求/响应 body 抓取，未配置时关闭
+	BodyDump *BodyDumpConfig `mapstructure:"body_dump"`
+}
+
+// BodyDumpConfig 排查客户端对接问题时按路由或抽样记录请求与响应 body，写入独立的日志流，不进入访问日志。
+// 除 sink 外修改后自动生效
+type BodyDumpConfig struct {
+	// Routes 按路由路径开启，如 /api/links/:code；可带方法前缀，如 "POST /api/links"
+	Routes []string `mapstructure:"routes" validate:"dive,required"`
+	// SampleRate 其余请求按百分比抽样，0 表示不抽样
+	SampleRate float64 `mapstructure:"sample_rate" validate:"gte=0,lte=100"`
+	// MaxBytes 每个 body 最多记录的字节数，默认 4096，超出部分截断
+	MaxBytes int `mapstructure:"max_bytes" validate:"gte=0"`
+	// RedactPaths 需要脱敏的 JSON 路径，如 user.email、items.*.secret；
+	// password、token、secret、key 及 *_token、*_secret、*_key 字段无论位置始终脱敏
+	RedactPaths []string `mapstructure:"redact_paths" validate:"dive,required"`
+	// RedactHeaders 额外需要脱敏的头；Authorization、Cookie、Set-Cookie、X-API-Key 始终脱敏
+	RedactHeaders []string `mapstructure:"redact_headers" validate:"dive,required"`
+	// Sink 输出目标，默认为 json 格式的 ./logs/body.log；修改后需重启
+	Sink *LogSinkConfig `mapstructure:"sink"`
+}
+
+// LogSinkConfig 一个日志输出目标
+type LogSinkConfig struct {
+	Type string `mapstructure:"type" validate:"required,oneof=stdout stderr file"`
+	// Format 为空时使用 log.format
+	Format string `mapstructure:"format" validate:"omitempty,oneof=json console logfmt"`
+	// Level 该 sink 的最低级别，如 stderr 只输出 error；为空时只受全局级别控制
+	Level string `mapstructure:"level" validate:"omitempty,oneof=debug info warn error"`
+	// Filename 等仅 file 使用，为空或为 0 时取 log 段的值
+	Filename   string `mapstructure:"filename"`
+	MaxSize    int    `mapstructure:"max_size" validate:"gte=0"`
+	MaxBackups int    `mapstructure:"max_backups" validate:"gte=0"`
+	MaxAge     int    `mapstructure:"max_age" validate:"gte=0"`
+	Compress   *bool  `mapstructure:"compress"`
 }
 type Config struct {
-	Server *ServerInfo `mapstructure:"server"`
-	Log    *LogConfig  `mapstructure:"log"`
-	JWT    *JWTConfig  `mapstructure:"jwt"`
+	Server    *ServerInfo      `mapstructure:"server" validate:"required"`
+	Log       *LogConfig       `mapstructure:"log"`
+	JWT       *JWTConfig       `mapstructure:"jwt" validate:"required"`
+	Database  *DatabaseConfig  `mapstructure:"database"`
+	Analytics *AnalyticsConfig `mapstructure:"analytics"`
+	Authz     *AuthzConfig     `mapstructure:"authz"`
+	RateLimit *RateLimitConfig `mapstructure:"ratelimit"`
+	Quota     *QuotaConfig     `mapstructure:"quota"`
+	LoadShed  *LoadShedConfig  `mapstructure:"loadshed"`
+}
+
+// LoadShedConfig 自适应并发限制：按延迟动态调整同时处理的请求数，超出时按优先级短暂排队后返回 503。
+// 未配置时不限制；修改后需重启生效
+type LoadShedConfig struct {
+	InitialLimit int `mapstructure:"initial_limit" validate:"gte=0"`
+	MinLimit     int `mapstructure:"min_limit" validate:"gte=0"`
+	MaxLimit     int `mapstructure:"max_limit" validate:"omitempty,gtefield=MinLimit"`
+	// Tolerance 短期延迟超过长期延迟的多少倍时开始收缩上限
+	Tolerance    float64       `mapstructure:"tolerance" validate:"omitempty,gte=1"`
+	QueueTimeout time.Duration `mapstructure:"queue_timeout" validate:"gte=0"`
+	MaxQueue     int           `mapstructure:"max_queue" validate:"gte=0"`
+	// Reserve 为高优先级请求保留的并发比例
+	Reserve float64 `mapstructure:"reserve" validate:"gte=0,lt=0.5"`
+	// CriticalPaths、HighPaths 按路由路径（如 /:code）划分优先级，其余为普通优先级；
+	// 过载时普通请求最先被丢弃，critical 最后
+	CriticalPaths []string `mapstructure:"critical_paths" validate:"dive,required"`
+	HighPaths     []string `mapstructure:"high_paths" validate:"dive,required"`
+}
+
+// QuotaConfig 按自然日、自然月（UTC）计数的用量配额，计数保存在数据库中，与限流叠加。
+// 额度与路由组绑定修改后自动生效，已有计数不受影响。
+type QuotaConfig struct {
+	Policies map[string]QuotaPolicy `mapstructure:"policies" validate:"dive"`
+	// Groups 路由组名 -> 依次计数的配额名
+	Groups map[string][]string `mapstructure:"groups" validate:"dive,dive,required"`
+}
+
+// QuotaPolicy 一项配额
+type QuotaPolicy struct {
+	// Period 计数周期：day 或 month
+	Period string `mapstructure:"period" validate:"required,oneof=day month"`
+	// Subject 计数对象：user，或 apikey（API key 请求按 key 单独计数，JWT 请求仍按用户）
+	Subject string `mapstructure:"subject" validate:"required,oneof=user apikey"`
+	// Limit 硬上限，达到后请求被拒绝；0 表示不允许使用
+	Limit int64 `mapstructure:"limit" validate:"gte=0"`
+	// Soft 软上限，达到后请求仍被处理，但响应带 X-Quota-Warning 头；0 表示不提醒
+	Soft int64 `mapstructure:"soft" validate:"gte=0,ltefield=Limit"`
+	// Methods 只对这些 HTTP 方法计数，为空表示全部
+	Methods []string `mapstructure:"methods" validate:"dive,oneof=GET HEAD POST PUT PATCH DELETE"`
+	// Plans 按用户套餐覆盖 limit、soft
+	Plans map[string]QuotaPlan `mapstructure:"plans" validate:"dive"`
+}
+
+// QuotaPlan 某个套餐的配额
+type QuotaPlan struct {
+	Limit int64 `mapstructure:"limit" validate:"gte=0"`
+	Soft  int64 `mapstructure:"soft" validate:"gte=0,ltefield=Limit"`
+}
+
+// Limits 返回 plan 对应的硬上限与软上限
+func (p QuotaPolicy) Limits(plan string) (limit, soft int64) {
+	if pl, ok := p.Plans[plan]; ok {
+		return pl.Limit, pl.Soft
+	}
+	return p.Limit, p.Soft
+}
+
+// QuotaPolicies 返回路由组绑定的配额名
+func (c *Config) QuotaPolicies(group string) []string {
+	if c.Quota == nil {
+		return nil
+	}
+	return c.Quota.Groups[group]
+}
+
+// RateLimitConfig 按路由组生效的命名限流策略，与 server.rate_limit_* 的全局按 IP 限流叠加。
+// 速率、突发、key 来源与路由组绑定修改后自动生效。
+type RateLimitConfig struct {
+	Policies map[string]RateLimitPolicy `mapstructure:"policies" validate:"dive"`
+	// Groups 路由组名 -> 依次应用的策略名
+	Groups map[string][]string `mapstructure:"groups" validate:"dive,dive,required"`
+}
+
+// RateLimitPolicy 一条限流策略
+type RateLimitPolicy struct {
+	Rate  float64 `mapstructure:"rate" validate:"gt=0"`
+	Burst int     `mapstructure:"burst" validate:"gte=1"`
+	// Key 限流维度：ip、user（JWT 或 API key 对应的用户）、apikey（每个 key 单独计数）、header；
+	// 取不到用户或 key 时退化为按 IP
+	Key    string `mapstructure:"key" validate:"required,oneof=ip user apikey header"`
+	Header string `mapstructure:"header" validate:"required_if=Key header"`
+	// Methods 只对这些 HTTP 方法生效，为空表示全部
+	Methods []string `mapstructure:"methods" validate:"dive,oneof=GET HEAD POST PUT PATCH DELETE"`
+	// Plans 按用户套餐覆盖 rate、burst，未列出的套餐使用策略本身的值
+	Plans map[string]RateLimitPlan `mapstructure:"plans" validate:"dive"`
+}
+
+// RateLimitPlan 某个套餐的限流额度
+type RateLimitPlan struct {
+	Rate  float64 `mapstructure:"rate" validate:"gt=0"`
+	Burst int     `mapstructure:"burst" validate:"gte=1"`
+}
+
+// Limit 返回 plan 对应的 rate、burst
+func (p RateLimitPolicy) Limit(plan string) (float64, int) {
+	if pl, ok := p.Plans[plan]; ok {
+		return pl.Rate, pl.Burst
+	}
+	return p.Rate, p.Burst
+}
+
+// RateLimitPolicies 返回路由组绑定的策略名
+func (c *Config) RateLimitPolicies(group string) []string {
+	if c.RateLimit == nil {
+		return nil
+	}
+	return c.RateLimit.Groups[group]
+}
+
+// AuthzConfig 角色与路由组权限配置，修改后自动生效（已签发 token 中的 scope 要等重新登录或刷新后才会更新）
+type AuthzConfig struct {
+	// Roles 角色 -> 授予的 scope，签发 token 时按用户角色写入；"*" 表示全部，"links:*" 表示 links 下全部
+	Roles map[string][]string `mapstructure:"roles" validate:"dive,dive,required"`
+	// Policies 路由组名 -> 访问规则，未配置的路由组只要求登录
+	Policies map[string][]PolicyRule `mapstructure:"policies" validate:"dive,dive"`
+}
+
+// PolicyRule 一条访问规则：Roles 满足其一、Scopes 全部具备才放行
+type PolicyRule struct {
+	// Methods 规则适用的 HTTP 方法，为空表示全部
+	Methods []string `mapstructure:"methods" validate:"dive,oneof=GET HEAD POST PUT PATCH DELETE"`
+	Roles   []string `mapstructure:"roles" validate:"dive,required"`
+	Scopes  []string `mapstructure:"scopes" validate:"dive,required"`
+}
+
+// DefaultRoleScopes 未配置 authz.roles 时使用的角色权限
+var DefaultRoleScopes = map[string][]string{
+	"user":  {"links:read", "links:write"},
+	"admin": {"*"},
+}
+
+// ScopesForRole 返回角色被授予的 scope，未配置 authz.roles 时使用 DefaultRoleScopes
+func (c *Config) ScopesForRole(role string) []string {
+	roles := DefaultRoleScopes
+	if c.Authz != nil && len(c.Authz.Roles) > 0 {
+		roles = c.Authz.Roles
+	}
+	return roles[role]
+}
+
+// Policy 返回路由组对应的访问规则
+func (c *Config) Policy(group string) []PolicyRule {
+	if c.Authz == nil {
+		return nil
+	}
+	return c.Authz.Policies[group]
+}
+
+// AnalyticsConfig 点击事件异步写入参数
+type AnalyticsConfig struct {
+	BufferSize    int           `mapstructure:"buffer_size" validate:"gte=0"`    // 缓冲区容量，写满后丢弃
+	BatchSize     int           `mapstructure:"batch_size" validate:"gte=0"`     // 每批 COPY 的最大条数
+	FlushInterval time.Duration `mapstructure:"flush_interval" validate:"gte=0"` // 最长刷新间隔
 }
 type ServerInfo struct {
-	Address           string        `mapstructure:"address"`
-	Port              string        `mapstructure:"port"`
-	ReadTimeout       time.Duration `mapstructure:"read_timeout"`
-	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
-	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
-	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
-	MaxHeaderBytes    int           `mapstructure:"max_header_bytes"`
-	ShutdownTimeout   time.Duration `mapstructure:"shutdown_timeout"`
+	Address string `mapstructure:"address" validate:"omitempty,ip|hostname_rfc1123"`
+	Port    string `mapstructure:"port" validate:"required,port_string"`
+	// 对外访问地址（如 https://s.example.com），用于拼接 short_url；为空时取请求的 Host
+	BaseURL           string        `mapstructure:"base_url" validate:"omitempty,http_url"`
+	ReadTimeout       time.Duration `mapstructure:"read_timeout" validate:"gte=0"`
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout" validate:"gte=0"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout" validate:"gte=0"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout" validate:"gte=0"`
+	MaxHeaderBytes    int           `mapstructure:"max_header_bytes" validate:"gte=0"`
+	ShutdownTimeout   time.Duration `mapstructure:"shutdown_timeout" validate:"gte=0"`
 	// 中间件：请求体大小限制（字节）
-	BodyLimit int64 `mapstructure:"body_limit"`
+	BodyLimit int64 `mapstructure:"body_limit" validate:"gte=0"`
 	// 限流：每秒请求数、突发容量
-	RateLimitRate  float64 `mapstructure:"rate_limit_rate"`
-	RateLimitBurst int     `mapstructure:"rate_limit_burst"`
+	RateLimitRate  float64 `mapstructure:"rate_limit_rate" validate:"gte=0"`
+	RateLimitBurst int     `mapstructure:"rate_limit_burst" validate:"gte=0"`
+	// 限流器最多跟踪的 key 数量与空闲回收时间，修改后需重启生效
+	RateLimitMaxKeys int           `mapstructure:"rate_limit_max_keys" validate:"gte=0"`
+	RateLimitIdleTTL time.Duration `mapstructure:"rate_limit_idle_ttl" validate:"gte=0"`
+	// 限流状态存储：memory（默认，各实例独立计数）或 postgres（多实例共享，需配置 database），修改后需重启生效
+	RateLimitBackend string `mapstructure:"rate_limit_backend" validate:"omitempty,oneof=memory postgres"`
+	// CORS 允许的来源，为空时允许所有来源（*）
+	CORSAllowOrigins []string `mapstructure:"cors_allow_origins" validate:"dive,required"`
 }
 type DatabaseConfig struct {
-	Driver       string `mapstructure:"driver"`
-	Host         string `mapstructure:"host"`
-	Port         int    `mapstructure:"port"`
-	User         string `mapstructure:"user"`
+	Driver       string `mapstructure:"driver" validate:"required,oneof=postgres"`
+	Host         string `mapstructure:"host" validate:"required,ip|hostname_rfc1123"`
+	Port         int    `mapstructure:"port" validate:"required,min=1,max=65535"`
+	User         string `mapstructure:"user" validate:"required"`
 	Password     string `mapstructure:"password"`
-	DBName       string `mapstructure:"dbname"`
-	SSLMode      string `mapstructure:"ssl_mode"`
-	MaxIdleConns int    `mapstructure:"max_idle_conns"`
-	MaxOpenConns int    `mapstructure:"max_open_conns"`
+	DBName       string `mapstructure:"dbname" validate:"required"`
+	SSLMode      string `mapstructure:"ssl_mode" validate:"omitempty,oneof=disable allow prefer require verify-ca verify-full"`
+	MaxIdleConns int    `mapstructure:"max_idle_conns" validate:"gte=0"`
+	MaxOpenConns int    `mapstructure:"max_open_conns" validate:"gte=0"`
+	// 连接最长存活时间、最长空闲时间，0 表示不限制
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime" validate:"gte=0"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time" validate:"gte=0"`
+	// 启动时 Ping 失败的重试次数与初始退避间隔（每次翻倍）；重试次数未配置时为 5，0 表示不重试
+	ConnectRetries int           `mapstructure:"connect_retries" validate:"gte=0"`
+	ConnectBackoff time.Duration `mapstructure:"connect_backoff" validate:"gte=0"`
+	// 启动时自动执行内嵌的数据库迁移
+	AutoMigrate bool `mapstructure:"auto_migrate"`
+}
+
+// defaultConnectRetries 未配置 database.connect_retries 时启动 Ping 的重试次数
+const defaultConnectRetries = 5
+
+// DSN 按 lib/pq 可识别的 URL 格式拼接连接串，用户名和密码会做转义
+func (d DatabaseConfig) DSN() string {
+	u := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(d.User, d.Password),
+		Host:   net.JoinHostPort(d.Host, strconv.Itoa(d.Port)),
+		Path:   "/" + d.DBName,
+	}
+	if d.SSLMode != "" {
+		u.RawQuery = url.Values{"sslmode": {d.SSLMode}}.Encode()
+	}
+	return u.String()
 }
 
 func NewConfig(filePath string) (*Config, error) {
-	fmt.Println("正在加载路径:", filePath)
+	// 输出到 stderr，避免混入 openapi dump 等命令的标准输出
+	fmt.Fprintln(os.Stderr, "正在加载路径:", filePath)
+	return load(filePath)
+}
 
+// load 读取并解析配置文件，每次调用都使用新的 viper 实例，供首次加载与热加载共用
+func load(filePath string) (*Config, error) {
 	v := viper.New() // 建议使用局部实例，避免全局污染
 	v.SetConfigFile(filePath)
 	v.SetConfigType("yaml") // 明确指定格式
@@ -79,5 +351,13 @@ func NewConfig(filePath string) (*Config, error) {
 	if err := v.Unmarshal(conf); err != nil {
 		return nil, fmt.Errorf("解析配置文件失败: %w", err)
 	}
+	// 未配置的字段在此补默认值；不能用 v.SetDefault，否则没有 database 段时也会生成该段
+	if conf.Database != nil && !v.IsSet("database.connect_retries") {
+		conf.Database.ConnectRetries = defaultConnectRetries
+	}
+	// 4. 校验
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
 	return conf, nil
 }
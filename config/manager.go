@@ -0,0 +1,127 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce 编辑器保存文件时通常会触发多次写事件，合并后再重新加载
+const reloadDebounce = 200 * time.Millisecond
+
+// Subscriber 配置变更回调，old 为替换前的配置，cur 为新配置；两者都不应被修改
+type Subscriber func(old, cur *Config)
+
+// Manager 持有当前生效的配置，支持监听文件变化后校验、原子替换并通知订阅者。
+// 只有读取 Current 的组件才能感知变更；监听地址、超时、数据库、JWT 等仍需重启生效。
+type Manager struct {
+	path    string
+	current atomic.Pointer[Config]
+
+	mu          sync.Mutex
+	subscribers []Subscriber
+
+	// reloadMu 串行化 Reload，保证订阅者按替换顺序收到通知；回调期间持有，订阅者不能在回调中调用 Reload
+	reloadMu sync.Mutex
+}
+
+// NewManager 加载配置文件并创建 Manager
+func NewManager(filePath string) (*Manager, error) {
+	cfg, err := NewConfig(filePath)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manager{path: filePath}
+	m.current.Store(cfg)
+	return m, nil
+}
+
+// Path 返回配置文件路径
+func (m *Manager) Path() string {
+	return m.path
+}
+
+// Current 返回当前生效的配置
+func (m *Manager) Current() *Config {
+	return m.current.Load()
+}
+
+// Subscribe 注册配置变更回调，回调在重新加载的协程中按注册顺序同步执行
+func (m *Manager) Subscribe(fn Subscriber) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.subscribers = append(m.subscribers, fn)
+}
+
+// Reload 重新读取并校验配置文件，成功后替换当前配置并通知订阅者；
+// 失败时保留原配置并返回错误。多次 Reload 依次执行；回调订阅者时不持有 mu，订阅者可以在回调中调用 Subscribe。
+func (m *Manager) Reload() error {
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	cfg, err := load(m.path)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	old := m.current.Swap(cfg)
+	subscribers := slices.Clone(m.subscribers)
+	m.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(old, cfg)
+	}
+	return nil
+}
+
+// WatchConfig 监听配置文件变化并自动 Reload，阻塞直到 ctx 取消。
+// 监听的是所在目录而不是文件本身，这样编辑器“写临时文件再重命名”的保存方式也能被捕获。
+func (m *Manager) WatchConfig(ctx context.Context, logger *slog.Logger) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("创建配置文件监听失败: %w", err)
+	}
+	defer watcher.Close()
+
+	target := filepath.Clean(m.path)
+	if err := watcher.Add(filepath.Dir(target)); err != nil {
+		return fmt.Errorf("监听配置目录失败: %w", err)
+	}
+
+	timer := time.NewTimer(reloadDebounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(ev.Name) != target || !ev.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+				continue
+			}
+			timer.Reset(reloadDebounce)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Warn("config watcher error", "error", err)
+		case <-timer.C:
+			if err := m.Reload(); err != nil {
+				logger.Error("config reload rejected, keeping previous config", "path", m.path, "error", err)
+				continue
+			}
+			logger.Info("config reloaded", "path", m.path)
+		}
+	}
+}
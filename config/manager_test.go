@@ -0,0 +1,164 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const baseYAML = `
+server:
+  port: "8080"
+  rate_limit_rate: 10
+  rate_limit_burst: 20
+jwt:
+  secret: "test-secret-0123456789"
+  duration: 1h
+log:
+  level: info
+`
+
+func writeConfig(t *testing.T, path, body string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestManager_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, baseYAML)
+	m, err := NewManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var notified int
+	m.Subscribe(func(old, cur *Config) {
+		notified++
+		if old.Server.RateLimitRate != 10 || cur.Server.RateLimitRate != 50 {
+			t.Errorf("回调参数错误: old=%v cur=%v", old.Server.RateLimitRate, cur.Server.RateLimitRate)
+		}
+	})
+
+	writeConfig(t, path, `
+server:
+  port: "8080"
+  rate_limit_rate: 50
+jwt:
+  secret: "test-secret-0123456789"
+  duration: 1h
+`)
+	if err := m.Reload(); err != nil {
+		t.Fatalf("合法配置应重新加载成功: %v", err)
+	}
+	if notified != 1 {
+		t.Errorf("期望通知 1 次，实际 %d 次", notified)
+	}
+	if got := m.Current().Server.RateLimitRate; got != 50 {
+		t.Errorf("当前配置应已替换，rate=%v", got)
+	}
+}
+
+func TestManager_ReloadRejectsInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, baseYAML)
+	m, err := NewManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Subscribe(func(_, _ *Config) { t.Error("非法配置不应通知订阅者") })
+	before := m.Current()
+
+	writeConfig(t, path, `
+server:
+  rate_limit_rate: -1
+jwt:
+  secret: "test-secret-0123456789"
+  duration: 1h
+log:
+  level: verbose
+`)
+	if err := m.Reload(); err == nil {
+		t.Fatal("非法配置应返回错误")
+	}
+	if m.Current() != before {
+		t.Error("校验失败时应保留原配置")
+	}
+}
+
+func TestManager_SubscriberCanSubscribe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, baseYAML)
+	m, err := NewManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var late int
+	m.Subscribe(func(_, _ *Config) {
+		m.Subscribe(func(_, _ *Config) { late++ })
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- m.Reload() }()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("订阅者在回调中调用 Subscribe 导致死锁")
+	}
+	if late != 0 {
+		t.Errorf("回调中新注册的订阅者不应收到本次通知，得到 %d 次", late)
+	}
+	if err := m.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if late != 1 {
+		t.Errorf("新注册的订阅者应收到下一次通知，得到 %d 次", late)
+	}
+}
+
+func TestManager_ConcurrentReloadsNotifyInOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfig(t, path, baseYAML)
+	m, err := NewManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		mu   sync.Mutex
+		last = m.Current()
+	)
+	m.Subscribe(func(old, cur *Config) {
+		// 拉长回调耗时，使并发的 Reload 有机会交错
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		if old != last {
+			t.Error("通知顺序应与替换顺序一致")
+		}
+		last = cur
+	})
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := m.Reload(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if last != m.Current() {
+		t.Error("订阅者最终看到的配置应与 Current 一致")
+	}
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// validate 配置校验器：字段名取 mapstructure 标签，错误路径与 YAML 中的写法一致
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	// port_string 字符串形式的端口号（server.port 为 string 类型，内置的 port 规则只支持数字类型）
+	v.RegisterValidation("port_string", func(fl validator.FieldLevel) bool {
+		n, err := strconv.ParseUint(fl.Field().String(), 10, 16)
+		return err == nil && n >= 1
+	})
+	v.RegisterStructValidation(validateJWT, JWTConfig{})
+	v.RegisterStructValidation(validateRateLimit, RateLimitConfig{})
+	v.RegisterStructValidation(validateQuota, QuotaConfig{})
+	return v
+}
+
+// validateQuota 路由组引用的配额必须已定义
+func validateQuota(sl validator.StructLevel) {
+	c := sl.Current().Interface().(QuotaConfig)
+	for group, names := range c.Groups {
+		for i, name := range names {
+			if _, ok := c.Policies[name]; !ok {
+				sl.ReportError(name, fmt.Sprintf("groups[%s][%d]", group, i), "Groups", "quota", name)
+			}
+		}
+	}
+}
+
+// validateRateLimit 路由组引用的策略必须已定义
+func validateRateLimit(sl validator.StructLevel) {
+	c := sl.Current().Interface().(RateLimitConfig)
+	for group, names := range c.Groups {
+		for i, name := range names {
+			if _, ok := c.Policies[name]; !ok {
+				sl.ReportError(name, fmt.Sprintf("groups[%s][%d]", group, i), "Groups", "policy", name)
+			}
+		}
+	}
+}
+
+// validateJWT 未配置 keys 时必须有 secret；配置了 keys 时 signing_key 必须指向一把带私钥的密钥
+func validateJWT(sl validator.StructLevel) {
+	c := sl.Current().Interface().(JWTConfig)
+	if len(c.Keys) == 0 {
+		if c.Secret == "" {
+			sl.ReportError(c.Secret, "secret", "Secret", "required", "")
+		}
+		return
+	}
+	for _, k := range c.Keys {
+		if k.ID == c.SigningKey && k.PrivateKeyFile != "" {
+			return
+		}
+	}
+	sl.ReportError(c.SigningKey, "signing_key", "SigningKey", "signing_key", "")
+}
+
+// Violation 一条校验失败项
+type Violation struct {
+	// Path YAML 路径，如 server.port、server.cors_allow_origins[0]
+	Path string
+	// Rule 未通过的规则，如 required、min、oneof
+	Rule string
+	// Param 规则参数，如 min=16 中的 16
+	Param string
+	// Message 可读的错误描述
+	Message string
+}
+
+func (v Violation) String() string {
+	return v.Path + ": " + v.Message
+}
+
+// ValidationError 汇总一次校验中的全部失败项
+type ValidationError struct {
+	Violations []Violation
+}
+
+func (e *ValidationError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "配置校验失败（共 %d 项）:", len(e.Violations))
+	for _, v := range e.Violations {
+		b.WriteString("\n  - ")
+		b.WriteString(v.String())
+	}
+	return b.String()
+}
+
+// Validate 按结构体上的 validate 标签校验整棵配置树，一次性返回全部失败项（*ValidationError）
+func (c *Config) Validate() error {
+	err := validate.Struct(c)
+	if err == nil {
+		return nil
+	}
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) {
+		return err
+	}
+	verr := &ValidationError{Violations: make([]Violation, 0, len(fieldErrs))}
+	for _, fe := range fieldErrs {
+		verr.Violations = append(verr.Violations, Violation{
+			Path:    yamlPath(fe.Namespace()),
+			Rule:    fe.Tag(),
+			Param:   fe.Param(),
+			Message: describe(fe),
+		})
+	}
+	return verr
+}
+
+// yamlPath 去掉命名空间开头的结构体名 "Config."
+func yamlPath(namespace string) string {
+	if _, rest, ok := strings.Cut(namespace, "."); ok {
+		return rest
+	}
+	return namespace
+}
+
+// describe 生成可读的错误描述；secret、password 等敏感字段不回显取值
+func describe(fe validator.FieldError) string {
+	isString := fe.Kind() == reflect.String
+	var msg string
+	switch fe.Tag() {
+	case "required":
+		msg = "不能为空"
+	case "min":
+		if isString {
+			msg = fmt.Sprintf("长度不能小于 %s", fe.Param())
+		} else {
+			msg = fmt.Sprintf("不能小于 %s", fe.Param())
+		}
+	case "max":
+		msg = fmt.Sprintf("不能大于 %s", fe.Param())
+	case "gte":
+		msg = fmt.Sprintf("不能小于 %s", fe.Param())
+	case "gt":
+		msg = fmt.Sprintf("必须大于 %s", fe.Param())
+	case "ltefield":
+		msg = fmt.Sprintf("不能大于 %s", strings.ToLower(fe.Param()))
+	case "gtefield":
+		msg = fmt.Sprintf("不能小于 %s", strings.ToLower(fe.Param()))
+	case "lt":
+		msg = fmt.Sprintf("必须小于 %s", fe.Param())
+	case "oneof":
+		msg = fmt.Sprintf("必须是以下之一: %s", strings.Join(strings.Fields(fe.Param()), ", "))
+	case "port_string":
+		msg = "必须是 1-65535 之间的端口号"
+	case "ip|hostname_rfc1123":
+		msg = "必须是合法的 IP 或主机名"
+	case "required_without":
+		msg = fmt.Sprintf("与 %s 不能同时为空", fe.Param())
+	case "unique":
+		msg = fmt.Sprintf("%s 不能重复", fe.Param())
+	case "signing_key":
+		msg = "必须是 keys 中配置了 private_key_file 的 kid"
+	case "policy":
+		msg = "引用了未定义的限流策略"
+	case "quota":
+		msg = "引用了未定义的配额"
+	case "required_if":
+		msg = "不能为空"
+	case "http_url":
+		msg = "必须是 http/https 地址"
+	default:
+		msg = fmt.Sprintf("不满足规则 %s", fe.Tag())
+		if fe.Param() != "" {
+			msg += "=" + fe.Param()
+		}
+	}
+	field := strings.ToLower(fe.Field())
+	if fe.Tag() != "required" && !strings.Contains(field, "secret") && !strings.Contains(field, "password") {
+		msg += fmt.Sprintf("（当前值: %v）", fe.Value())
+	}
+	return msg
+}
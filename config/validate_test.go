@@ -0,0 +1,162 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func validConfig() *Config {
+	return &Config{
+		Server: &ServerInfo{Port: "8080", RateLimitRate: 10, RateLimitBurst: 20},
+		JWT:    &JWTConfig{Secret: "0123456789abcdef", Duration: time.Hour},
+		Database: &DatabaseConfig{
+			Driver: "postgres", Host: "127.0.0.1", Port: 5432, User: "postgres", DBName: "urldb", SSLMode: "disable",
+		},
+	}
+}
+
+func TestConfig_Validate_OK(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("合法配置不应报错: %v", err)
+	}
+}
+
+func TestConfig_Validate_ReportsEveryViolation(t *testing.T) {
+	cfg := validConfig()
+	cfg.Server.Port = "99999"
+	cfg.Server.ReadTimeout = -time.Second
+	cfg.JWT.Secret = ""
+	cfg.Database.Host = ""
+	cfg.Database.SSLMode = "sometimes"
+	cfg.Log = &LogConfig{Level: "verbose"}
+
+	err := cfg.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("期望 *ValidationError，得到 %v", err)
+	}
+	want := map[string]string{
+		"server.port":         "port_string",
+		"server.read_timeout": "gte",
+		"jwt.secret":          "required",
+		"database.host":       "required",
+		"database.ssl_mode":   "oneof",
+		"log.level":           "oneof",
+	}
+	got := map[string]string{}
+	for _, v := range verr.Violations {
+		got[v.Path] = v.Rule
+	}
+	for path, rule := range want {
+		if got[path] != rule {
+			t.Errorf("%s: 期望规则 %q，得到 %q", path, rule, got[path])
+		}
+	}
+	if len(verr.Violations) != len(want) {
+		t.Errorf("期望 %d 项错误，得到 %d 项: %v", len(want), len(verr.Violations), err)
+	}
+}
+
+func TestConfig_Validate_MissingSections(t *testing.T) {
+	err := (&Config{}).Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Violations) != 2 {
+		t.Fatalf("缺少 server 与 jwt 应报 2 项错误，得到 %v", err)
+	}
+}
+
+func TestConfig_Validate_JWTKeys(t *testing.T) {
+	cfg := validConfig()
+	cfg.JWT.Secret = ""
+	cfg.JWT.SigningKey = "2026-10"
+	cfg.JWT.Keys = []JWTKeyConfig{
+		{ID: "2026-04", Algorithm: "ES256", PublicKeyFile: "keys/2026-04.pub.pem"},
+		{ID: "2026-10", Algorithm: "EdDSA", PrivateKeyFile: "keys/2026-10.pem"},
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("配置了 keys 时 secret 可为空: %v", err)
+	}
+
+	// 签发密钥只有公钥、算法不支持、两个文件都为空
+	cfg.JWT.SigningKey = "2026-04"
+	cfg.JWT.Keys = append(cfg.JWT.Keys, JWTKeyConfig{ID: "x", Algorithm: "HS512"})
+	err := cfg.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("期望 *ValidationError，得到 %v", err)
+	}
+	got := map[string]string{}
+	for _, v := range verr.Violations {
+		got[v.Path] = v.Rule
+	}
+	want := map[string]string{
+		"jwt.signing_key":              "signing_key",
+		"jwt.keys[2].algorithm":        "oneof",
+		"jwt.keys[2].private_key_file": "required_without",
+	}
+	for path, rule := range want {
+		if got[path] != rule {
+			t.Errorf("%s: 期望规则 %q，得到 %q（%v）", path, rule, got[path], err)
+		}
+	}
+}
+
+func TestConfig_Validate_RateLimitPolicies(t *testing.T) {
+	cfg := validConfig()
+	cfg.RateLimit = &RateLimitConfig{
+		Policies: map[string]RateLimitPolicy{
+			"per_ip":  {Rate: 1, Burst: 1, Key: "ip"},
+			"by_head": {Rate: 1, Burst: 1, Key: "header"},
+		},
+		Groups: map[string][]string{"links": {"per_ip", "missing"}},
+	}
+	err := cfg.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("期望 *ValidationError，得到 %v", err)
+	}
+	got := map[string]string{}
+	for _, v := range verr.Violations {
+		got[v.Path] = v.Rule
+	}
+	if got["ratelimit.groups[links][1]"] != "policy" {
+		t.Errorf("应报告未定义的策略，得到 %v", err)
+	}
+	if got["ratelimit.policies[by_head].header"] != "required_if" {
+		t.Errorf("key 为 header 时 header 必填，得到 %v", err)
+	}
+}
+
+func TestConfig_Validate_Quotas(t *testing.T) {
+	cfg := validConfig()
+	cfg.Quota = &QuotaConfig{
+		Policies: map[string]QuotaPolicy{
+			"links_created": {Period: "month", Subject: "user", Limit: 100, Soft: 80},
+			"api_calls":     {Period: "week", Subject: "apikey", Limit: 10, Soft: 20},
+		},
+		Groups: map[string][]string{"links": {"links_created", "missing"}},
+	}
+	err := cfg.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("期望 *ValidationError，得到 %v", err)
+	}
+	got := map[string]string{}
+	for _, v := range verr.Violations {
+		got[v.Path] = v.Rule
+	}
+	want := map[string]string{
+		"quota.groups[links][1]":           "quota",
+		"quota.policies[api_calls].period": "oneof",
+		"quota.policies[api_calls].soft":   "ltefield",
+	}
+	for path, rule := range want {
+		if got[path] != rule {
+			t.Errorf("%s: 期望规则 %q，得到 %q（%v）", path, rule, got[path], err)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("期望 %d 项错误，得到 %v", len(want), got)
+	}
+}
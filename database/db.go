@@ -1,21 +1,75 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
 
 	"echotest/config"
 	_ "github.com/lib/pq"
 )
 
-func NewDB(cfg config.DatabaseConfig) (*sql.DB, error) {
+const (
+	defaultConnectBackoff = time.Second
+	maxConnectBackoff     = 30 * time.Second
+	pingTimeout           = 5 * time.Second
+)
+
+// NewDB 按配置打开连接池并设置池参数；启动时 Ping 失败会按指数退避重试，
+// ctx 取消（如收到退出信号）时立即放弃。logger 为 nil 时不打印重试日志。
+func NewDB(ctx context.Context, cfg config.DatabaseConfig, logger *slog.Logger) (*sql.DB, error) {
 	db, err := sql.Open(cfg.Driver, cfg.DSN())
 	if err != nil {
 		return nil, err
 	}
 
-	if err := db.Ping(); err != nil {
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	if err := pingWithRetry(ctx, db, cfg, logger); err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
+
+func pingWithRetry(ctx context.Context, db *sql.DB, cfg config.DatabaseConfig, logger *slog.Logger) error {
+	// 默认值由配置加载时补齐，这里的 0 表示不重试
+	retries := cfg.ConnectRetries
+	if retries < 0 {
+		return fmt.Errorf("connect_retries 不能为负数: %d", retries)
+	}
+	backoff := cfg.ConnectBackoff
+	if backoff <= 0 {
+		backoff = defaultConnectBackoff
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		err = db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		if attempt > retries {
+			break
+		}
+		if logger != nil {
+			logger.Warn("database ping failed, retrying",
+				"attempt", attempt, "backoff", backoff, "host", cfg.Host, "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("连接数据库被取消: %w", ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
+	return fmt.Errorf("连接数据库失败（已重试 %d 次）: %w", retries, err)
+}
@@ -0,0 +1,381 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 迁移文件沿用 golang-migrate 的命名：{version}_{name}.up.sql / {version}_{name}.down.sql，
+// sqlc 也直接读取该目录作为 schema。
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+const (
+	migrationsDir = "migrations"
+	// schemaTable 记录已执行的迁移版本
+	schemaTable = "schema_versions"
+	// migrateLockKey 迁移时持有的 Postgres advisory lock，保证多实例同时启动时只有一个在迁移
+	migrateLockKey int64 = 0x6563686f74657374 // "echotest"
+)
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_([A-Za-z0-9_\-]+)\.(up|down)\.sql$`)
+
+// Migration 一个版本的迁移脚本
+type Migration struct {
+	Version uint64
+	Name    string
+	Up      string
+	Down    string
+	// Checksum up 脚本的 SHA-256，执行时记入版本表，用于发现已执行的迁移文件被修改
+	Checksum string
+}
+
+// MigrationStatus 某个版本的执行状态
+type MigrationStatus struct {
+	Version   uint64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Missing 表示数据库中记录了该版本，但程序中已没有对应的迁移文件
+	Missing bool
+	// Modified 表示该版本执行后 up 脚本被修改，与版本表中记录的校验和不一致
+	Modified bool
+}
+
+// appliedVersion 版本表中的一条记录；checksum 为空表示记录早于校验和功能，不做比对
+type appliedVersion struct {
+	at       time.Time
+	checksum string
+}
+
+// queryer *sql.DB 与 *sql.Conn 共有的查询方法
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Migrator 基于内嵌 SQL 文件的迁移执行器
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	logger     *slog.Logger
+}
+
+// NewMigrator 使用程序内嵌的迁移文件创建 Migrator；logger 为 nil 时不打印执行日志
+func NewMigrator(db *sql.DB, logger *slog.Logger) (*Migrator, error) {
+	migrations, err := LoadMigrations(migrationsFS, migrationsDir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations, logger: logger}, nil
+}
+
+// LoadMigrations 读取 dir 下的迁移文件并按版本号升序返回
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("读取迁移目录失败: %w", err)
+	}
+	byVersion := make(map[uint64]*Migration)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		m := migrationFileRe.FindStringSubmatch(e.Name())
+		if m == nil {
+			return nil, fmt.Errorf("迁移文件名不合法: %s", e.Name())
+		}
+		version, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("迁移文件版本号不合法: %s", e.Name())
+		}
+		body, err := fs.ReadFile(fsys, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("版本 %d 存在多个名称: %s / %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("版本 %d 缺少 up 脚本", mig.Version)
+		}
+		sum := sha256.Sum256([]byte(mig.Up))
+		mig.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Up 按版本号升序执行所有未执行的迁移；已执行的迁移文件被修改时不执行任何迁移并返回错误
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.withLock(ctx, func(conn *sql.Conn, applied map[uint64]appliedVersion) error {
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, mig, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down 按版本从高到低回滚 steps 个已执行的迁移
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if steps <= 0 {
+		return fmt.Errorf("回滚步数必须大于 0")
+	}
+	return m.withLock(ctx, func(conn *sql.Conn, applied map[uint64]appliedVersion) error {
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, mig, false); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// Goto 迁移到指定版本：执行不超过 version 的未执行迁移，回滚高于 version 的已执行迁移。
+// version 为 0 表示全部回滚。与 Up 一样，已执行的迁移文件被修改时返回错误。
+func (m *Migrator) Goto(ctx context.Context, version uint64) error {
+	if version != 0 && !m.hasVersion(version) {
+		return fmt.Errorf("迁移版本 %d 不存在", version)
+	}
+	return m.withLock(ctx, func(conn *sql.Conn, applied map[uint64]appliedVersion) error {
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; ok && mig.Version > version {
+				if err := m.apply(ctx, conn, mig, false); err != nil {
+					return err
+				}
+			}
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; !ok && mig.Version <= version {
+				if err := m.apply(ctx, conn, mig, true); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Status 返回每个版本的执行状态，按版本号升序。只读取版本表，不获取迁移锁，
+// 其他实例正在迁移时也能立即返回（可能看到迁移进行到一半的状态）
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := appliedVersions(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		a, ok := applied[mig.Version]
+		result = append(result, MigrationStatus{
+			Version:   mig.Version,
+			Name:      mig.Name,
+			Applied:   ok,
+			AppliedAt: a.at,
+			Modified:  ok && a.checksum != "" && a.checksum != mig.Checksum,
+		})
+	}
+	for v, a := range applied {
+		if !m.hasVersion(v) {
+			result = append(result, MigrationStatus{Version: v, Applied: true, AppliedAt: a.at, Missing: true})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
+
+// verify 检查已执行迁移的 up 脚本与记录的校验和一致
+func (m *Migrator) verify(applied map[uint64]appliedVersion) error {
+	for _, mig := range m.migrations {
+		if a, ok := applied[mig.Version]; ok && a.checksum != "" && a.checksum != mig.Checksum {
+			return fmt.Errorf("版本 %d (%s) 执行后 up 脚本被修改，校验和与版本表不一致；已执行的迁移不应修改，请新增迁移", mig.Version, mig.Name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) hasVersion(version uint64) bool {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// withLock 在独占连接上获取 advisory lock、确保版本表存在，并把已执行版本交给 fn。
+// advisory lock 属于会话级别，所以后续事务都必须在同一个连接上执行。
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn, applied map[uint64]appliedVersion) error) (err error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrateLockKey); err != nil {
+		return fmt.Errorf("获取迁移锁失败: %w", err)
+	}
+	defer func() {
+		// 使用独立的 context，保证 ctx 已取消时也能释放锁
+		if _, uerr := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrateLockKey); uerr != nil {
+			err = errors.Join(err, fmt.Errorf("释放迁移锁失败: %w", uerr))
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+schemaTable+` (
+	version    BIGINT      PRIMARY KEY,
+	name       TEXT        NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+)`); err != nil {
+		return fmt.Errorf("创建迁移版本表失败: %w", err)
+	}
+	// 早于校验和功能创建的版本表没有 checksum 列
+	if _, err := conn.ExecContext(ctx, `ALTER TABLE `+schemaTable+` ADD COLUMN IF NOT EXISTS checksum TEXT NOT NULL DEFAULT ''`); err != nil {
+		return fmt.Errorf("升级迁移版本表失败: %w", err)
+	}
+
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+	// 没有校验和的旧记录以当前文件补齐，之后的修改即可被发现
+	for _, mig := range m.migrations {
+		if a, ok := applied[mig.Version]; ok && a.checksum == "" {
+			if _, err := conn.ExecContext(ctx, "UPDATE "+schemaTable+" SET checksum = $2 WHERE version = $1", int64(mig.Version), mig.Checksum); err != nil {
+				return fmt.Errorf("补齐迁移校验和失败: %w", err)
+			}
+			a.checksum = mig.Checksum
+			applied[mig.Version] = a
+		}
+	}
+	return fn(conn, applied)
+}
+
+// appliedVersions 读取版本表；版本表不存在（从未迁移过）时返回空集合
+func appliedVersions(ctx context.Context, q queryer) (map[uint64]appliedVersion, error) {
+	columns, err := q.QueryContext(ctx,
+		"SELECT column_name FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1", schemaTable)
+	if err != nil {
+		return nil, fmt.Errorf("查询迁移版本表失败: %w", err)
+	}
+	var exists, hasChecksum bool
+	for columns.Next() {
+		var name string
+		if err := columns.Scan(&name); err != nil {
+			columns.Close()
+			return nil, err
+		}
+		exists = true
+		hasChecksum = hasChecksum || name == "checksum"
+	}
+	columns.Close()
+	if err := columns.Err(); err != nil {
+		return nil, err
+	}
+	applied := make(map[uint64]appliedVersion)
+	if !exists {
+		return applied, nil
+	}
+
+	checksum := "''"
+	if hasChecksum {
+		checksum = "checksum"
+	}
+	rows, err := q.QueryContext(ctx, "SELECT version, applied_at, "+checksum+" FROM "+schemaTable)
+	if err != nil {
+		return nil, fmt.Errorf("查询迁移版本失败: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			v int64
+			a appliedVersion
+		)
+		if err := rows.Scan(&v, &a.at, &a.checksum); err != nil {
+			return nil, err
+		}
+		applied[uint64(v)] = a
+	}
+	return applied, rows.Err()
+}
+
+// apply 在单独的事务中执行一个迁移并同步更新版本表，失败时整体回滚
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration, up bool) error {
+	script, direction := mig.Up, "up"
+	if !up {
+		script, direction = mig.Down, "down"
+		if script == "" {
+			return fmt.Errorf("版本 %d (%s) 缺少 down 脚本，无法回滚", mig.Version, mig.Name)
+		}
+	}
+
+	start := time.Now()
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("执行迁移 %d_%s.%s.sql 失败: %w", mig.Version, mig.Name, direction, err)
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, "INSERT INTO "+schemaTable+" (version, name, checksum) VALUES ($1, $2, $3)",
+			int64(mig.Version), mig.Name, mig.Checksum)
+	} else {
+		_, err = tx.ExecContext(ctx, "DELETE FROM "+schemaTable+" WHERE version = $1", int64(mig.Version))
+	}
+	if err != nil {
+		return fmt.Errorf("更新迁移版本表失败: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if m.logger != nil {
+		m.logger.Info("migration applied",
+			"version", mig.Version, "name", mig.Name, "direction", direction, "took", time.Since(start))
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// openTestDB 设置 ECHOTEST_PG_DSN（URL 格式）后在独立的 schema 中连接测试库，测试结束后删除该 schema；未设置时跳过
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("ECHOTEST_PG_DSN")
+	if dsn == "" {
+		t.Skip("未设置 ECHOTEST_PG_DSN，跳过 Postgres 测试")
+	}
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	schema := "migrate_test_" + hex.EncodeToString(b)
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec("DROP SCHEMA " + schema + " CASCADE") })
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("search_path", schema)
+	u.RawQuery = q.Encode()
+	db, err := sql.Open("postgres", u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTestMigrator(t *testing.T, db *sql.DB, files map[string]string) *Migrator {
+	t.Helper()
+	fsys := fstest.MapFS{}
+	for name, body := range files {
+		fsys["m/"+name] = &fstest.MapFile{Data: []byte(body)}
+	}
+	migrations, err := LoadMigrations(fsys, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// stepFiles 每个版本向 steps 表写入自己的版本号（回滚时写入负数），用于断言执行顺序
+var stepFiles = map[string]string{
+	"000003_c.up.sql":   "INSERT INTO steps (v) VALUES (3);",
+	"000003_c.down.sql": "INSERT INTO steps (v) VALUES (-3);",
+	"000001_a.up.sql":   "CREATE TABLE steps (seq SERIAL PRIMARY KEY, v INT NOT NULL); INSERT INTO steps (v) VALUES (1);",
+	"000001_a.down.sql": "DROP TABLE steps;",
+	"000002_b.up.sql":   "INSERT INTO steps (v) VALUES (2);",
+	"000002_b.down.sql": "INSERT INTO steps (v) VALUES (-2);",
+}
+
+func steps(t *testing.T, db *sql.DB) []int {
+	t.Helper()
+	rows, err := db.Query("SELECT v FROM steps ORDER BY seq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var out []int
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+func appliedOf(t *testing.T, m *Migrator) []uint64 {
+	t.Helper()
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []uint64
+	for _, s := range statuses {
+		if s.Applied {
+			out = append(out, s.Version)
+		}
+	}
+	return out
+}
+
+func TestMigrator_UpDownOrder(t *testing.T) {
+	db := openTestDB(t)
+	m := newTestMigrator(t, db, stepFiles)
+	ctx := context.Background()
+
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := steps(t, db); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("Up 应按版本升序执行，得到 %v", got)
+	}
+	if got := appliedOf(t, m); !slices.Equal(got, []uint64{1, 2, 3}) {
+		t.Errorf("期望已执行 1 2 3，得到 %v", got)
+	}
+	// 再次执行不会重复迁移
+	if err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := steps(t, db); len(got) != 3 {
+		t.Errorf("重复 Up 不应再次执行，得到 %v", got)
+	}
+
+	if err := m.Down(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := steps(t, db); !slices.Equal(got, []int{1, 2, 3, -3, -2}) {
+		t.Errorf("Down 应按版本降序回滚，得到 %v", got)
+	}
+	if got := appliedOf(t, m); !slices.Equal(got, []uint64{1}) {
+		t.Errorf("回滚两步后只应剩下版本 1，得到 %v", got)
+	}
+}
+
+func TestMigrator_ChecksumMismatch(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	if err := newTestMigrator(t, db, map[string]string{"000001_a.up.sql": stepFiles["000001_a.up.sql"]}).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"000001_a.up.sql": stepFiles["000001_a.up.sql"] + " -- 执行后被修改",
+		"000002_b.up.sql": stepFiles["000002_b.up.sql"],
+	}
+	m := newTestMigrator(t, db, files)
+	err := m.Up(ctx)
+	if err == nil || !strings.Contains(err.Error(), "校验和") {
+		t.Fatalf("已执行的迁移被修改时 Up 应失败，得到 %v", err)
+	}
+	if got := steps(t, db); !slices.Equal(got, []int{1}) {
+		t.Errorf("校验失败时不应执行任何迁移，得到 %v", got)
+	}
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 2 || !statuses[0].Modified || statuses[1].Modified || statuses[1].Applied {
+		t.Errorf("Status 应标记版本 1 被修改、版本 2 未执行，得到 %+v", statuses)
+	}
+}
+
+func TestMigrator_MissingVersion(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	if err := newTestMigrator(t, db, stepFiles).Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 新程序中删除了版本 3 的文件
+	files := map[string]string{}
+	for name, body := range stepFiles {
+		if !strings.HasPrefix(name, "000003_") {
+			files[name] = body
+		}
+	}
+	m := newTestMigrator(t, db, files)
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 3 || statuses[2].Version != 3 || !statuses[2].Missing || !statuses[2].Applied {
+		t.Errorf("数据库中有而文件中没有的版本应标记为 missing，得到 %+v", statuses)
+	}
+	if err := m.Up(ctx); err != nil {
+		t.Errorf("缺少文件的已执行版本不应阻止 Up: %v", err)
+	}
+	// Down 只回滚有文件的版本
+	if err := m.Down(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := appliedOf(t, m); !slices.Equal(got, []uint64{1, 3}) {
+		t.Errorf("期望回滚版本 2，得到已执行 %v", got)
+	}
+}
+
+func TestMigrator_StatusDoesNotTakeLock(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	m := newTestMigrator(t, db, stepFiles)
+
+	// 从未迁移过：全部待执行，且只读的 Status 不创建版本表
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 3 || statuses[0].Applied {
+		t.Errorf("期望 3 个待执行版本，得到 %+v", statuses)
+	}
+	var exists bool
+	if err := db.QueryRow("SELECT to_regclass($1) IS NOT NULL", schemaTable).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("Status 不应创建版本表")
+	}
+
+	// 模拟另一个实例正在迁移
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrateLockKey); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrateLockKey)
+
+	statusCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if _, err := m.Status(statusCtx); err != nil {
+		t.Errorf("持有迁移锁时 Status 应立即返回: %v", err)
+	}
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations_Embedded(t *testing.T) {
+	migrations, err := LoadMigrations(migrationsFS, migrationsDir)
+	if err != nil {
+		t.Fatalf("内嵌迁移文件应能正常加载: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("期望至少存在一个迁移")
+	}
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i-1].Version >= migrations[i].Version {
+			t.Errorf("迁移应按版本号严格递增: %d 之后是 %d", migrations[i-1].Version, migrations[i].Version)
+		}
+	}
+}
+
+func TestLoadMigrations_SortsAndPairs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"m/000002_add_b.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+		"m/000001_add_a.up.sql":   {Data: []byte("CREATE TABLE a ();")},
+		"m/000001_add_a.down.sql": {Data: []byte("DROP TABLE a;")},
+	}
+	migrations, err := LoadMigrations(fsys, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("期望 2 个迁移，得到 %d", len(migrations))
+	}
+	if migrations[0].Version != 1 || migrations[0].Name != "add_a" || migrations[0].Down == "" {
+		t.Errorf("版本 1 解析错误: %+v", migrations[0])
+	}
+	if migrations[1].Version != 2 || migrations[1].Down != "" {
+		t.Errorf("版本 2 解析错误: %+v", migrations[1])
+	}
+	if len(migrations[0].Checksum) != 64 || migrations[0].Checksum == migrations[1].Checksum {
+		t.Errorf("应为每个 up 脚本计算 SHA-256 校验和，得到 %q / %q", migrations[0].Checksum, migrations[1].Checksum)
+	}
+}
+
+func TestLoadMigrations_Invalid(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"非法文件名":  {"m/init.sql": {Data: []byte("SELECT 1")}},
+		"缺少 up":  {"m/000001_a.down.sql": {Data: []byte("SELECT 1")}},
+		"同版本不同名": {"m/000001_a.up.sql": {Data: []byte("SELECT 1")}, "m/000001_b.down.sql": {Data: []byte("SELECT 1")}},
+	}
+	for name, fsys := range cases {
+		if _, err := LoadMigrations(fsys, "m"); err == nil {
+			t.Errorf("%s: 期望返回错误", name)
+		}
+	}
+}
+
+func TestMigrator_Verify(t *testing.T) {
+	m := &Migrator{migrations: []Migration{{Version: 1, Name: "a", Checksum: "aaa"}, {Version: 2, Name: "b", Checksum: "bbb"}}}
+	cases := []struct {
+		name    string
+		applied map[uint64]appliedVersion
+		wantErr bool
+	}{
+		{"一致", map[uint64]appliedVersion{1: {checksum: "aaa"}}, false},
+		{"旧记录没有校验和", map[uint64]appliedVersion{1: {}}, false},
+		{"文件已删除的版本", map[uint64]appliedVersion{3: {checksum: "ccc"}}, false},
+		{"已执行的脚本被修改", map[uint64]appliedVersion{1: {checksum: "aaa"}, 2: {checksum: "changed"}}, true},
+	}
+	for _, tc := range cases {
+		if err := m.verify(tc.applied); (err != nil) != tc.wantErr {
+			t.Errorf("%s: 期望错误 %v，得到 %v", tc.name, tc.wantErr, err)
+		}
+	}
+}
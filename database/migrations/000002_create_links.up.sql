@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS links (
+    id         BIGSERIAL PRIMARY KEY,
+    code       TEXT        NOT NULL UNIQUE,
+    target_url TEXT        NOT NULL,
+    owner_id   BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    permanent  BOOLEAN     NOT NULL DEFAULT FALSE,
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS links_owner_id_idx ON links (owner_id, id DESC);
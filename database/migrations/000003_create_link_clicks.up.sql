@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS link_clicks (
+    id         BIGSERIAL PRIMARY KEY,
+    link_id    BIGINT      NOT NULL REFERENCES links (id) ON DELETE CASCADE,
+    clicked_at TIMESTAMPTZ NOT NULL,
+    referrer   TEXT        NOT NULL DEFAULT '',
+    user_agent TEXT        NOT NULL DEFAULT '',
+    ip_prefix  TEXT        NOT NULL DEFAULT '',
+    request_id TEXT        NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS link_clicks_link_id_clicked_at_idx ON link_clicks (link_id, clicked_at);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      BIGINT      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name         TEXT        NOT NULL,
+    prefix       TEXT        NOT NULL,
+    key_hash     TEXT        NOT NULL UNIQUE,
+    scopes       TEXT[]      NOT NULL DEFAULT '{}',
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id);
@@ -0,0 +1,2 @@
+DROP FUNCTION IF EXISTS ratelimit_take(TEXT[], INT[], FLOAT8[], FLOAT8[]);
+DROP TABLE IF EXISTS rate_limits;
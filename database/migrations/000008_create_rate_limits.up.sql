@@ -0,0 +1,38 @@
+-- 共享限流状态：每个 key 只保存 GCRA 的理论到达时间（TAT），早于当前时间的记录与不存在等价，可随时清理
+CREATE TABLE IF NOT EXISTS rate_limits (
+    key TEXT        PRIMARY KEY,
+    tat TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS rate_limits_tat_idx ON rate_limits (tat);
+
+-- ratelimit_take 为一批 key 原子地扣减配额。p_counts 为该 key 在本批次中的请求数，
+-- p_interval 为发放间隔 T（秒，1/rate），p_tolerance 为窗口 burst*T（秒）。
+-- 返回每个 key 允许的请求数、扣减前的 TAT（不早于当前时间）与数据库当前时间，由调用方计算响应头。
+-- 调用方应按 key 排序传入，保证多个实例并发调用时加锁顺序一致。
+CREATE OR REPLACE FUNCTION ratelimit_take(p_keys TEXT[], p_counts INT[], p_interval FLOAT8[], p_tolerance FLOAT8[])
+RETURNS TABLE (rl_key TEXT, rl_allowed INT, rl_tat TIMESTAMPTZ, rl_now TIMESTAMPTZ)
+LANGUAGE plpgsql AS $$
+DECLARE
+    v_now     TIMESTAMPTZ := clock_timestamp();
+    v_tat     TIMESTAMPTZ;
+    v_allowed INT;
+BEGIN
+    FOR i IN 1 .. coalesce(array_length(p_keys, 1), 0) LOOP
+        INSERT INTO rate_limits AS r (key, tat) VALUES (p_keys[i], v_now) ON CONFLICT (key) DO NOTHING;
+        SELECT greatest(r.tat, v_now) INTO v_tat FROM rate_limits AS r WHERE r.key = p_keys[i] FOR UPDATE;
+        -- 时间精度为微秒，留 1 微秒误差
+        v_allowed := greatest(0, least(p_counts[i],
+            floor((p_tolerance[i] - extract(epoch FROM v_tat - v_now) + 1e-6) / p_interval[i])::INT));
+        IF v_allowed > 0 THEN
+            UPDATE rate_limits AS r SET tat = v_tat + make_interval(secs => v_allowed * p_interval[i])
+            WHERE r.key = p_keys[i];
+        END IF;
+        rl_key := p_keys[i];
+        rl_allowed := v_allowed;
+        rl_tat := v_tat;
+        rl_now := v_now;
+        RETURN NEXT;
+    END LOOP;
+END;
+$$;
@@ -0,0 +1,9 @@
+-- 用量配额计数：每个计数对象（user:<id> 或 apikey:<id>）每项配额每个周期一行，历史周期保留用于对账
+CREATE TABLE IF NOT EXISTS usage_counters (
+    subject      TEXT        NOT NULL,
+    quota        TEXT        NOT NULL,
+    period_start TIMESTAMPTZ NOT NULL,
+    used         BIGINT      NOT NULL DEFAULT 0,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (subject, quota, period_start)
+);
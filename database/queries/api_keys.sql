@@ -0,0 +1,24 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetAPIKeyByHash :one
+SELECT api_keys.*, users.email, users.role, users.plan
+FROM api_keys
+JOIN users ON users.id = api_keys.user_id
+WHERE api_keys.key_hash = $1;
+
+-- name: ListUserAPIKeys :many
+SELECT * FROM api_keys
+WHERE user_id = $1
+ORDER BY id DESC;
+
+-- name: DeleteUserAPIKey :execrows
+DELETE FROM api_keys
+WHERE id = $1 AND user_id = $2;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = now()
+WHERE id = $1;
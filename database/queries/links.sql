@@ -0,0 +1,28 @@
+-- name: CreateLink :one
+INSERT INTO links (code, target_url, owner_id, permanent, expires_at)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetLinkByCode :one
+SELECT * FROM links
+WHERE code = $1;
+
+-- name: GetOwnerLink :one
+SELECT * FROM links
+WHERE code = $1 AND owner_id = $2;
+
+-- name: ListOwnerLinks :many
+SELECT * FROM links
+WHERE owner_id = $1
+ORDER BY id DESC
+LIMIT $2 OFFSET $3;
+
+-- name: UpdateOwnerLink :one
+UPDATE links
+SET target_url = $3, permanent = $4, expires_at = $5, updated_at = now()
+WHERE code = $1 AND owner_id = $2
+RETURNING *;
+
+-- name: DeleteOwnerLink :execrows
+DELETE FROM links
+WHERE code = $1 AND owner_id = $2;
@@ -0,0 +1,33 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+VALUES ($1, $2, $3, $4)
+RETURNING *;
+
+-- name: GetRefreshTokenByHash :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1;
+
+-- name: MarkRefreshTokenRotated :execrows
+UPDATE refresh_tokens
+SET rotated_at = now()
+WHERE id = $1 AND rotated_at IS NULL AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = now()
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeAccessToken :exec
+INSERT INTO revoked_access_tokens (jti, expires_at)
+VALUES ($1, $2)
+ON CONFLICT (jti) DO NOTHING;
+
+-- name: IsAccessTokenRevoked :one
+SELECT EXISTS (
+    SELECT 1 FROM revoked_access_tokens
+    WHERE jti = $1
+);
+
+-- name: DeleteExpiredRevokedAccessTokens :exec
+DELETE FROM revoked_access_tokens
+WHERE expires_at < now();
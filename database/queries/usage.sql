@@ -0,0 +1,17 @@
+-- name: IncrementUsage :one
+-- 已达到 max_used 时不更新也不返回行（sql.ErrNoRows）
+INSERT INTO usage_counters (subject, quota, period_start, used)
+VALUES ($1, $2, $3, 1)
+ON CONFLICT (subject, quota, period_start) DO UPDATE
+SET used = usage_counters.used + 1, updated_at = now()
+WHERE usage_counters.used < sqlc.arg(max_used)
+RETURNING used;
+
+-- name: DecrementUsage :exec
+UPDATE usage_counters
+SET used = used - 1, updated_at = now()
+WHERE subject = $1 AND quota = $2 AND period_start = $3 AND used > 0;
+
+-- name: GetUsage :one
+SELECT used FROM usage_counters
+WHERE subject = $1 AND quota = $2 AND period_start = $3;
@@ -0,0 +1,172 @@
+// Package analytics 异步记录短链接点击事件：跳转请求只把事件放入有界缓冲区，
+// 后台协程按批量大小或时间间隔写入数据库，跳转延迟不依赖数据库写入。
+package analytics
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	db "echotest/internal/models"
+)
+
+const (
+	defaultBufferSize    = 10000
+	defaultBatchSize     = 500
+	defaultFlushInterval = time.Second
+	// flushTimeout 单次批量写入的超时时间
+	flushTimeout = 10 * time.Second
+)
+
+// Sink 批量写入点击事件，由 repository.ClickRepository 实现
+type Sink interface {
+	CopyClicks(ctx context.Context, clicks []db.LinkClick) error
+}
+
+// Config 缓冲与刷新参数，零值使用默认值
+type Config struct {
+	// BufferSize 缓冲区容量，写满后新事件被丢弃
+	BufferSize int
+	// BatchSize 单次写入的最大事件数，攒够即刷新
+	BatchSize int
+	// FlushInterval 未攒够一批时的最长等待时间
+	FlushInterval time.Duration
+}
+
+// Recorder 有界缓冲的点击事件记录器
+type Recorder struct {
+	sink      Sink
+	cfg       Config
+	logger    *slog.Logger
+	events    chan db.LinkClick
+	done      chan struct{}
+	dropped   atomic.Uint64
+	mu        sync.RWMutex
+	closed    bool
+	startOnce sync.Once
+}
+
+// NewRecorder 创建 Recorder，需调用 Start 启动后台刷新协程；logger 为 nil 时不打印日志
+func NewRecorder(sink Sink, cfg Config, logger *slog.Logger) *Recorder {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaultBufferSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultFlushInterval
+	}
+	return &Recorder{
+		sink:   sink,
+		cfg:    cfg,
+		logger: logger,
+		events: make(chan db.LinkClick, cfg.BufferSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start 启动后台刷新协程，重复调用无副作用
+func (r *Recorder) Start() {
+	r.startOnce.Do(func() { go r.loop() })
+}
+
+// Record 非阻塞地放入一个事件；缓冲区已满或已关闭时丢弃并返回 false
+func (r *Recorder) Record(click db.LinkClick) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		r.dropped.Add(1)
+		return false
+	}
+	select {
+	case r.events <- click:
+		return true
+	default:
+		r.dropped.Add(1)
+		return false
+	}
+}
+
+// Dropped 返回累计丢弃的事件数
+func (r *Recorder) Dropped() uint64 {
+	return r.dropped.Load()
+}
+
+// Close 停止接收新事件并等待缓冲区中的事件全部写入；ctx 到期时放弃等待。
+// 应在 HTTP 服务关闭之后调用，保证优雅关闭期间产生的事件也能落库。
+func (r *Recorder) Close(ctx context.Context) error {
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.events)
+	}
+	r.mu.Unlock()
+
+	r.Start() // 未启动时也要把已缓冲的事件写完
+	select {
+	case <-r.done:
+		if n := r.Dropped(); n > 0 && r.logger != nil {
+			r.logger.Warn("click events dropped", "count", n)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Recorder) loop() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]db.LinkClick, 0, r.cfg.BatchSize)
+	for {
+		select {
+		case click, ok := <-r.events:
+			if !ok {
+				r.flush(batch)
+				return
+			}
+			batch = append(batch, click)
+			if len(batch) >= r.cfg.BatchSize {
+				r.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				r.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+func (r *Recorder) flush(batch []db.LinkClick) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+	if err := r.sink.CopyClicks(ctx, batch); err != nil {
+		r.dropped.Add(uint64(len(batch)))
+		if r.logger != nil {
+			r.logger.Error("failed to flush click events", "count", len(batch), "error", err)
+		}
+	}
+}
+
+// TruncateIP 为保护隐私截断客户端 IP：IPv4 保留 /24，IPv6 保留 /48，返回网段地址
+func TruncateIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsed.Mask(net.CIDRMask(48, 128)).String()
+}
@@ -0,0 +1,90 @@
+package analytics
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	db "echotest/internal/models"
+)
+
+type fakeSink struct {
+	mu      sync.Mutex
+	batches [][]db.LinkClick
+}
+
+func (s *fakeSink) CopyClicks(_ context.Context, clicks []db.LinkClick) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]db.LinkClick(nil), clicks...))
+	return nil
+}
+
+func (s *fakeSink) total() (batches, clicks int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range s.batches {
+		clicks += len(b)
+	}
+	return len(s.batches), clicks
+}
+
+func TestRecorder_FlushesInBatchesAndDrainsOnClose(t *testing.T) {
+	sink := &fakeSink{}
+	r := NewRecorder(sink, Config{BufferSize: 100, BatchSize: 10, FlushInterval: time.Hour}, nil)
+	r.Start()
+
+	for i := 0; i < 25; i++ {
+		if !r.Record(db.LinkClick{LinkID: int64(i)}) {
+			t.Fatalf("第 %d 个事件不应被丢弃", i)
+		}
+	}
+	if err := r.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	batches, clicks := sink.total()
+	if clicks != 25 {
+		t.Errorf("关闭后应写入全部 25 个事件，实际 %d", clicks)
+	}
+	if batches != 3 {
+		t.Errorf("期望 3 批（10+10+5），实际 %d", batches)
+	}
+	if r.Record(db.LinkClick{}) {
+		t.Error("关闭后的 Record 应返回 false")
+	}
+}
+
+func TestRecorder_DropsWhenBufferFull(t *testing.T) {
+	sink := &fakeSink{}
+	// 未 Start，事件只会堆积在缓冲区中
+	r := NewRecorder(sink, Config{BufferSize: 2, BatchSize: 10}, nil)
+	r.Record(db.LinkClick{})
+	r.Record(db.LinkClick{})
+	if r.Record(db.LinkClick{}) {
+		t.Error("缓冲区已满时应丢弃事件")
+	}
+	if r.Dropped() != 1 {
+		t.Errorf("期望丢弃 1 个，实际 %d", r.Dropped())
+	}
+	if err := r.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, clicks := sink.total(); clicks != 2 {
+		t.Errorf("未启动的 Recorder 关闭时也应写入缓冲区中的 2 个事件，实际 %d", clicks)
+	}
+}
+
+func TestTruncateIP(t *testing.T) {
+	cases := map[string]string{
+		"203.0.113.77":             "203.0.113.0",
+		"2001:db8:abcd:12:1:2:3:4": "2001:db8:abcd::",
+		"::ffff:198.51.100.9":      "198.51.100.0",
+		"not-an-ip":                "",
+	}
+	for in, want := range cases {
+		if got := TruncateIP(in); got != want {
+			t.Errorf("TruncateIP(%q) = %q, 期望 %q", in, got, want)
+		}
+	}
+}
@@ -4,38 +4,118 @@ import (
 	"context"
 	"database/sql"
 	"echotest/config"
+	"echotest/database"
+	"echotest/internal/analytics"
+	"echotest/pkg/jwks"
+	"echotest/pkg/openapi"
+	"echotest/pkg/ratelimit"
 	"echotest/pkg/utils"
 	"net/http"
+	"path/filepath"
 	"time"
 
 	"github.com/labstack/echo/v5"
 )
 
 type Application struct {
-	Config *config.Config
-	E      *echo.Echo
-	Ctx    context.Context
-	Cancel context.CancelFunc
-	Db     *sql.DB
+	// Config 启动时加载的配置；热加载后的最新配置通过 ConfigManager.Current() 获取
+	Config        *config.Config
+	ConfigManager *config.Manager
+	E             *echo.Echo
+	Ctx           context.Context
+	Cancel        context.CancelFunc
+	Db            *sql.DB
+	// Keys JWT 签发与校验使用的密钥集合，公钥通过 /.well-known/jwks.json 发布
+	Keys *jwks.Set
+
+	// GlobalLimiter 所有请求共用的按 IP 限流器（server.rate_limit_*）
+	GlobalLimiter *ratelimit.Limiter
+	// RateLimits 按路由组挂载的命名限流策略（config ratelimit 段）
+	RateLimits *utils.RateLimits
+	// Docs 注册路由时附带的接口说明，生成 /openapi.json
+	Docs *openapi.Document
+
+	// clicks 点击事件异步写入器，仅在配置了数据库时存在
+	clicks *analytics.Recorder
 }
 
 // InitApp 加载配置、初始化 Echo（含中间件与日志）、注册路由，返回可运行的 Application
 func InitApp(filePath string) (*Application, error) {
-	cfg, err := config.NewConfig(filePath)
+	mgr, err := config.NewManager(filePath)
+	if err != nil {
+		return nil, err
+	}
+	cfg := mgr.Current()
+	keys, err := utils.NewKeySet(*cfg.JWT, filepath.Dir(filePath))
 	if err != nil {
 		return nil, err
 	}
-	ec, ctx, cancel := utils.Init(cfg)
+	ec, limiter, ctx, cancel := utils.Init(mgr)
 	a := &Application{
-		Config: cfg,
-		E:      ec,
-		Ctx:    ctx,
-		Cancel: cancel,
+		Config:        cfg,
+		ConfigManager: mgr,
+		E:             ec,
+		Ctx:           ctx,
+		Cancel:        cancel,
+		Keys:          keys,
+		GlobalLimiter: limiter,
+		RateLimits:    utils.NewRateLimits(mgr, ec),
+		Docs:          newDocs(cfg),
+	}
+	go a.RateLimits.Run(ctx)
+	if cfg.Database != nil {
+		db, err := database.NewDB(ctx, *cfg.Database, utils.NamedLogger("database"))
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		a.Db = db
+		if cfg.Database.AutoMigrate {
+			if err := a.migrate(); err != nil {
+				db.Close()
+				cancel()
+				return nil, err
+			}
+		}
 	}
+	a.initRateLimitBackend()
 	a.initRouter()
+	if a.clicks != nil {
+		a.clicks.Start()
+	}
+	go func() {
+		if err := mgr.WatchConfig(ctx, ec.Logger); err != nil {
+			ec.Logger.Error("config hot reload disabled", "error", err)
+		}
+	}()
 	return a, nil
 }
 
+// initRateLimitBackend 配置了 server.rate_limit_backend: postgres 时让全局与各策略限流器共享数据库中的配额，
+// 数据库不可用时各限流器自动退回进程内令牌桶
+func (a *Application) initRateLimitBackend() {
+	if a.Config.Server.RateLimitBackend != "postgres" {
+		return
+	}
+	if a.Db == nil {
+		a.E.Logger.Warn("rate_limit_backend postgres requires database, using memory")
+		return
+	}
+	backend := ratelimit.NewPostgres(a.Db, ratelimit.PostgresConfig{}, utils.NamedLogger("ratelimit"))
+	go backend.Run(a.Ctx)
+	a.GlobalLimiter.SetBackend(backend)
+	a.RateLimits.SetBackend(backend)
+}
+
+// migrate 执行内嵌的数据库迁移，供 database.auto_migrate 开启时使用
+func (a *Application) migrate() error {
+	m, err := database.NewMigrator(a.Db, utils.NamedLogger("database"))
+	if err != nil {
+		return err
+	}
+	return m.Up(a.Ctx)
+}
+
 // Run 启动 HTTP 服务并阻塞直到收到退出信号，然后优雅关闭
 func (a *Application) Run() error {
 	defer a.Cancel()
@@ -60,7 +140,26 @@ func (a *Application) Run() error {
 	shutdownTimeout := orDuration(s.ShutdownTimeout, 5*time.Second)
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer shutdownCancel()
-	return server.Shutdown(shutdownCtx)
+	err := server.Shutdown(shutdownCtx)
+	// 服务关闭后不会再有新的点击事件，把缓冲区写完再关闭连接池。
+	// shutdownCtx 可能已被 Shutdown 用完，写入使用单独的超时
+	drained := true
+	if a.clicks != nil {
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		if cerr := a.clicks.Close(drainCtx); cerr != nil {
+			drained = false
+			a.E.Logger.Error("failed to drain click events", "error", cerr)
+		}
+		drainCancel()
+	}
+	// 先停止接收请求再关闭连接池，避免正在处理的请求拿不到连接；
+	// 点击事件仍在写入时不关闭，留给进程退出回收
+	if a.Db != nil && drained {
+		if cerr := a.Db.Close(); cerr != nil {
+			a.E.Logger.Error("failed to close database", "error", cerr)
+		}
+	}
+	return err
 }
 
 func orDuration(d, def time.Duration) time.Duration {
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"echotest/config"
+	"echotest/internal/handler"
+	"echotest/pkg/openapi"
+	"echotest/pkg/problem"
+	"echotest/pkg/utils"
+	"path/filepath"
+
+	"github.com/labstack/echo/v5"
+)
+
+// APIVersion OpenAPI 文档中的接口版本
+const APIVersion = "0.0.0"
+
+// newDocs 创建接口文档并注册认证方式、错误响应结构与自定义校验规则
+func newDocs(cfg *config.Config) *openapi.Document {
+	docs := openapi.New(openapi.Info{
+		Title:       "echotest API",
+		Version:     APIVersion,
+		Description: "错误响应统一为 application/problem+json（RFC 9457）",
+	})
+	if cfg.Server.BaseURL != "" {
+		docs.Server(cfg.Server.BaseURL)
+	}
+	docs.SecurityScheme(handler.SecurityBearer, openapi.SecurityScheme{Type: "http", Scheme: "bearer", BearerFormat: "JWT"})
+	docs.SecurityScheme(handler.SecurityAPIKey, openapi.SecurityScheme{Type: "apiKey", In: "header", Name: "X-API-Key"})
+	docs.ErrorBody(problem.ContentType, problem.Problem{})
+	for tag, fn := range utils.SchemaRules() {
+		docs.Rule(tag, fn)
+	}
+	return docs
+}
+
+// OpenAPI 离线生成接口文档：只加载配置并注册路由，不连接数据库、不启动后台任务
+func OpenAPI(filePath string) ([]byte, error) {
+	mgr, err := config.NewManager(filePath)
+	if err != nil {
+		return nil, err
+	}
+	cfg := mgr.Current()
+	keys, err := utils.NewKeySet(*cfg.JWT, filepath.Dir(filePath))
+	if err != nil {
+		return nil, err
+	}
+	// sql.Open 不会建立连接，仅用于让依赖数据库的路由得以注册
+	db, err := sql.Open("postgres", "")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ec := echo.New()
+	a := &Application{
+		Config:        cfg,
+		ConfigManager: mgr,
+		E:             ec,
+		Ctx:           ctx,
+		Cancel:        cancel,
+		Db:            db,
+		Keys:          keys,
+		RateLimits:    utils.NewRateLimits(mgr, ec),
+		Docs:          newDocs(cfg),
+	}
+	a.initRouter()
+	return a.Docs.JSON()
+}
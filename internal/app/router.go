@@ -4,20 +4,131 @@ package app
 import (
 	"net/http"
 
+	"echotest/internal/analytics"
+	"echotest/internal/handler"
+	"echotest/internal/repository"
+	"echotest/internal/service"
+	"echotest/pkg/jwks"
+	"echotest/pkg/openapi"
 	"echotest/pkg/requestid"
+	"echotest/pkg/utils"
 
 	"github.com/labstack/echo/v5"
 )
 
 // initRouter 初始化所有HTTP路由
 func (a *Application) initRouter() {
+	docs := a.Docs
 	// 测试日志与请求 ID：响应中返回 request_id，便于用该 ID 在日志中检索整条链路
-	a.E.GET("/ping", func(c *echo.Context) error {
-		rid := requestid.GetRequestID(c)
-		a.E.Logger.Info("ping called", "request_id", rid)
+	docs.Add(a.E.GET("/ping", func(c *echo.Context) error {
+		// c.Logger() 由 requestid.Middleware 设置，日志自动带上 request_id
+		c.Logger().Info("ping called")
 		return c.JSON(http.StatusOK, map[string]string{
 			"status":     "ok",
-			"request_id": rid,
+			"request_id": requestid.GetRequestID(c),
 		})
+	}), openapi.Op("健康检查").Tags("system").Returns(http.StatusOK, map[string]string{}))
+
+	// 发布 JWT 公钥，其他服务据此按 kid 校验本服务签发的 token
+	docs.Add(a.E.GET("/.well-known/jwks.json", func(c *echo.Context) error {
+		c.Response().Header().Set("Cache-Control", "public, max-age=300")
+		return c.JSON(http.StatusOK, a.Keys.JWKS())
+	}), openapi.Op("JWT 公钥集合（JWKS）").Tags("system").Returns(http.StatusOK, jwks.JWKSet{}))
+
+	// 运维接口不依赖数据库；未配置数据库时无法查询吊销名单，只校验 token 签名与有效期
+	adminJWT := utils.JWT(a.Keys, nil)
+	if a.Db != nil {
+		requireJWT, scopes := a.initAuthRouter()
+		adminJWT = requireJWT
+		requireAuth := a.initAPIKeyRouter(requireJWT, scopes)
+		quotas := a.initUsageRouter(requireAuth)
+		a.initLinkRouter(requireAuth, quotas)
+	}
+	a.initAdminRouter(adminJWT)
+
+	// 由以上带说明的路由生成，路由变更后文档随之更新
+	a.E.GET("/openapi.json", docs.Handler())
+}
+
+// initAuthRouter 注册 /auth 路由，返回带吊销检查的 JWT 中间件与角色权限解析函数供其他路由使用
+func (a *Application) initAuthRouter() (echo.MiddlewareFunc, service.ScopeResolver) {
+	scopes := func(role string) []string { return a.ConfigManager.Current().ScopesForRole(role) }
+	svc := service.NewAuthService(
+		repository.NewUserRepository(a.Db),
+		repository.NewTokenRepository(a.Db),
+		utils.NewJWT(a.Keys, a.Config.JWT.Duration),
+		a.Config.JWT.RefreshDuration,
+		scopes,
+	)
+	requireAuth := utils.JWT(a.Keys, svc)
+	h := handler.NewAuthHandler(svc)
+
+	g := a.E.Group("/auth", a.RateLimits.Group("auth"))
+	a.Docs.Add(g.POST("/register", h.Register), handler.RegisterDoc)
+	a.Docs.Add(g.POST("/login", h.Login), handler.LoginDoc)
+	a.Docs.Add(g.POST("/refresh", h.Refresh), handler.RefreshDoc)
+	a.Docs.Add(g.POST("/logout", h.Logout, requireAuth), handler.LogoutDoc)
+	return requireAuth, scopes
+}
+
+// initAdminRouter 注册 /admin 运维接口，只接受 admin 角色的 JWT
+func (a *Application) initAdminRouter(requireJWT echo.MiddlewareFunc) {
+	h := handler.NewLogLevelHandler(utils.LogLevel)
+
+	g := a.E.Group("/admin", requireJWT, utils.RequireRole("admin"))
+	a.Docs.Add(g.GET("/loglevel", h.Get), handler.GetLogLevelDoc)
+	a.Docs.Add(g.PUT("/loglevel", h.Set), handler.SetLogLevelDoc)
+	a.Docs.Add(g.DELETE("/loglevel", h.Revert), handler.RevertLogLevelDoc)
+}
+
+// initAPIKeyRouter 注册 /api/keys 路由，返回同时接受 JWT 与 API key 的认证中间件。
+// 管理 API key 本身只接受 JWT，避免泄露的 key 被用来签发新 key。
+func (a *Application) initAPIKeyRouter(requireJWT echo.MiddlewareFunc, scopes service.ScopeResolver) echo.MiddlewareFunc {
+	svc := service.NewAPIKeyService(repository.NewAPIKeyRepository(a.Db), repository.NewUserRepository(a.Db), scopes)
+	h := handler.NewAPIKeyHandler(svc)
+
+	g := a.E.Group("/api/keys", requireJWT, utils.Policy(a.ConfigManager, "apikeys"), a.RateLimits.Group("apikeys"))
+	a.Docs.Add(g.POST("", h.Create), handler.CreateAPIKeyDoc)
+	a.Docs.Add(g.GET("", h.List), handler.ListAPIKeysDoc)
+	a.Docs.Add(g.DELETE("/:id", h.Delete), handler.DeleteAPIKeyDoc)
+	return utils.Authenticate(requireJWT, svc)
+}
+
+// initUsageRouter 注册 /api/usage，返回按路由组计数的配额中间件（config quota 段）
+func (a *Application) initUsageRouter(requireAuth echo.MiddlewareFunc) func(group string) echo.MiddlewareFunc {
+	svc := service.NewQuotaService(repository.NewUsageRepository(a.Db))
+	h := handler.NewUsageHandler(svc, func(c *echo.Context) []utils.Quota {
+		return utils.CurrentQuotas(c, a.ConfigManager.Current())
 	})
+
+	a.Docs.Add(a.E.GET("/api/usage", h.Get, requireAuth), handler.UsageDoc)
+	return func(group string) echo.MiddlewareFunc {
+		return utils.Quotas(a.ConfigManager, svc, group)
+	}
+}
+
+// initLinkRouter 注册短链接相关路由：/api/links 需要 JWT 认证，/:code 为公开跳转
+func (a *Application) initLinkRouter(requireAuth echo.MiddlewareFunc, quotas func(group string) echo.MiddlewareFunc) {
+	var clickCfg analytics.Config
+	if ac := a.Config.Analytics; ac != nil {
+		clickCfg = analytics.Config{BufferSize: ac.BufferSize, BatchSize: ac.BatchSize, FlushInterval: ac.FlushInterval}
+	}
+	// 由 InitApp 启动，生成 OpenAPI 文档时只注册路由
+	a.clicks = analytics.NewRecorder(repository.NewClickRepository(a.Db), clickCfg, utils.NamedLogger("analytics"))
+
+	links := handler.NewLinkHandler(
+		service.NewLinkService(repository.NewLinkRepository(a.Db)),
+		a.Config.Server.BaseURL,
+		a.clicks,
+	)
+
+	// 配额放在限流之后，被限流拒绝的请求不占用配额
+	api := a.E.Group("/api/links", requireAuth, utils.Policy(a.ConfigManager, "links"), a.RateLimits.Group("links"), quotas("links"))
+	a.Docs.Add(api.POST("", links.Create), handler.CreateLinkDoc)
+	a.Docs.Add(api.GET("", links.List), handler.ListLinksDoc)
+	a.Docs.Add(api.GET("/:code", links.Get), handler.GetLinkDoc)
+	a.Docs.Add(api.PUT("/:code", links.Update), handler.UpdateLinkDoc)
+	a.Docs.Add(api.DELETE("/:code", links.Delete), handler.DeleteLinkDoc)
+
+	a.Docs.Add(a.E.GET("/:code", links.Redirect, a.RateLimits.Group("redirect")), handler.RedirectDoc)
 }
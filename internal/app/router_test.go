@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"echotest/config"
+	"echotest/pkg/jwks"
+	"echotest/pkg/problem"
+	"echotest/pkg/utils"
+
+	"github.com/labstack/echo/v5"
+)
+
+func TestInitRouter_AdminRoutesWithoutDatabase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(`
+server:
+  port: "8080"
+jwt:
+  secret: "test-secret-0123456789"
+  duration: 1h
+log:
+  level: info
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mgr, err := config.NewManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := mgr.Current()
+	keys := jwks.NewHMAC([]byte(cfg.JWT.Secret))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler(problem.Default)
+	a := &Application{
+		Config:        cfg,
+		ConfigManager: mgr,
+		E:             e,
+		Ctx:           ctx,
+		Cancel:        cancel,
+		Keys:          keys,
+		RateLimits:    utils.NewRateLimits(mgr, e),
+		Docs:          newDocs(cfg),
+	}
+	a.initRouter()
+
+	do := func(token string) int {
+		req := httptest.NewRequest(http.MethodGet, "/admin/loglevel", nil)
+		if token != "" {
+			req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	if code := do(""); code != http.StatusUnauthorized {
+		t.Errorf("未登录应返回 401，得到 %d", code)
+	}
+	signer := utils.NewJWT(keys, time.Minute)
+	for role, want := range map[string]int{"user": http.StatusForbidden, "admin": http.StatusOK} {
+		token, err := signer.Generate(utils.Principal{UserID: 1, Role: role})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code := do(token); code != want {
+			t.Errorf("%s 期望 %d，得到 %d", role, want, code)
+		}
+	}
+}
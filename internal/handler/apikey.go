@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	db "echotest/internal/models"
+	"echotest/internal/service"
+
+	"github.com/labstack/echo/v5"
+)
+
+// APIKeyHandler API key 管理接口
+type APIKeyHandler struct {
+	svc *service.APIKeyService
+}
+
+// NewAPIKeyHandler 创建 APIKeyHandler
+func NewAPIKeyHandler(svc *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{svc: svc}
+}
+
+type createAPIKeyRequest struct {
+	Name      string     `json:"name" validate:"required,max=100"`
+	Scopes    []string   `json:"scopes" validate:"required,min=1,dive,required,max=64"`
+	ExpiresAt *time.Time `json:"expires_at" validate:"omitempty,after"`
+}
+
+type deleteAPIKeyRequest struct {
+	ID int64 `param:"id" validate:"required,gt=0"`
+}
+
+type apiKeyResponse struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type createAPIKeyResponse struct {
+	apiKeyResponse
+	// Key 明文 key，只在创建时返回一次
+	Key string `json:"key"`
+}
+
+type listAPIKeysResponse struct {
+	Items []apiKeyResponse `json:"items"`
+}
+
+// Create POST /api/keys
+func (h *APIKeyHandler) Create(c *echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+	var req createAPIKeyRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	key, raw, err := h.svc.Create(c.Request().Context(), service.CreateAPIKeyInput{
+		UserID:    userID,
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, createAPIKeyResponse{apiKeyResponse: toAPIKeyResponse(key), Key: raw})
+}
+
+// List GET /api/keys
+func (h *APIKeyHandler) List(c *echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+	keys, err := h.svc.List(c.Request().Context(), userID)
+	if err != nil {
+		return err
+	}
+	items := make([]apiKeyResponse, len(keys))
+	for i, key := range keys {
+		items[i] = toAPIKeyResponse(key)
+	}
+	return c.JSON(http.StatusOK, listAPIKeysResponse{Items: items})
+}
+
+// Delete DELETE /api/keys/:id
+func (h *APIKeyHandler) Delete(c *echo.Context) error {
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+	var req deleteAPIKeyRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if err := h.svc.Delete(c.Request().Context(), userID, req.ID); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func toAPIKeyResponse(key db.ApiKey) apiKeyResponse {
+	resp := apiKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if key.ExpiresAt.Valid {
+		t := key.ExpiresAt.Time
+		resp.ExpiresAt = &t
+	}
+	if key.LastUsedAt.Valid {
+		t := key.LastUsedAt.Time
+		resp.LastUsedAt = &t
+	}
+	return resp
+}
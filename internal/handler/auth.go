@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"echotest/internal/service"
+	"echotest/pkg/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v5"
+)
+
+// AuthHandler 注册、登录、刷新与登出接口
+type AuthHandler struct {
+	svc *service.AuthService
+}
+
+// NewAuthHandler 创建 AuthHandler
+func NewAuthHandler(svc *service.AuthService) *AuthHandler {
+	return &AuthHandler{svc: svc}
+}
+
+type credentialsRequest struct {
+	Email    string `json:"email" validate:"required,email,max=254"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type userResponse struct {
+	ID        int64     `json:"id"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Register POST /auth/register
+func (h *AuthHandler) Register(c *echo.Context) error {
+	var req credentialsRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	user, err := h.svc.Register(c.Request().Context(), req.Email, req.Password)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, userResponse{ID: user.ID, Email: user.Email, CreatedAt: user.CreatedAt})
+}
+
+// Login POST /auth/login
+func (h *AuthHandler) Login(c *echo.Context) error {
+	var req credentialsRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	pair, err := h.svc.Login(c.Request().Context(), req.Email, req.Password)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, toTokenResponse(pair))
+}
+
+// Refresh POST /auth/refresh，每次调用都会轮换 refresh token
+func (h *AuthHandler) Refresh(c *echo.Context) error {
+	var req refreshRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	pair, err := h.svc.Refresh(c.Request().Context(), req.RefreshToken)
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, toTokenResponse(pair))
+}
+
+// Logout POST /auth/logout，需要 JWT；吊销当前 access token 以及请求体中 refresh token 所在的会话
+func (h *AuthHandler) Logout(c *echo.Context) error {
+	var req logoutRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	userID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+	var (
+		jti string
+		exp time.Time
+	)
+	if token, ok := c.Get("user").(*jwt.Token); ok {
+		if claims, ok := token.Claims.(*utils.UserCliams); ok {
+			jti = claims.ID
+			if claims.ExpiresAt != nil {
+				exp = claims.ExpiresAt.Time
+			}
+		}
+	}
+	if err := h.svc.Logout(c.Request().Context(), userID, req.RefreshToken, jti, exp); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+func toTokenResponse(pair service.TokenPair) tokenResponse {
+	return tokenResponse{
+		AccessToken:  pair.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(pair.ExpiresIn / time.Second),
+		RefreshToken: pair.RefreshToken,
+	}
+}
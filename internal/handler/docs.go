@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"net/http"
+
+	"echotest/pkg/openapi"
+	"echotest/pkg/utils"
+)
+
+// 认证方式在 OpenAPI 文档中的名称，由 app 注册到 openapi.Document
+const (
+	SecurityBearer = "bearerAuth"
+	SecurityAPIKey = "apiKey"
+)
+
+// 各接口的 OpenAPI 说明，注册路由时通过 openapi.Document.Add 附加，见 app.initRouter
+var (
+	RegisterDoc = openapi.Op("注册").Tags("auth").
+			Body(credentialsRequest{}).
+			Returns(http.StatusCreated, userResponse{}).
+			Errors(http.StatusBadRequest, http.StatusConflict, http.StatusTooManyRequests)
+	LoginDoc = openapi.Op("登录，签发 access token 与 refresh token").Tags("auth").
+			Body(credentialsRequest{}).
+			Returns(http.StatusOK, tokenResponse{}).
+			Errors(http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests)
+	RefreshDoc = openapi.Op("用 refresh token 换取新的 token").Tags("auth").
+			Describe("refresh token 只能使用一次，重复使用会吊销整个 token 家族").
+			Body(refreshRequest{}).
+			Returns(http.StatusOK, tokenResponse{}).
+			Errors(http.StatusBadRequest, http.StatusUnauthorized)
+	LogoutDoc = openapi.Op("退出登录，吊销当前 access token 与 refresh token").Tags("auth").
+			Body(logoutRequest{}).
+			Returns(http.StatusNoContent, nil).
+			Errors(http.StatusUnauthorized).
+			Secure(SecurityBearer)
+
+	CreateAPIKeyDoc = openapi.Op("创建 API key").Tags("apikeys").
+			Describe("明文 key 只在创建时返回一次；scopes 不能超出当前角色的权限").
+			Body(createAPIKeyRequest{}).
+			Returns(http.StatusCreated, createAPIKeyResponse{}).
+			Errors(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden).
+			Secure(SecurityBearer)
+	ListAPIKeysDoc = openapi.Op("列出当前用户的 API key").Tags("apikeys").
+			Returns(http.StatusOK, listAPIKeysResponse{}).
+			Errors(http.StatusUnauthorized, http.StatusForbidden).
+			Secure(SecurityBearer)
+	DeleteAPIKeyDoc = openapi.Op("吊销 API key").Tags("apikeys").
+			Params(deleteAPIKeyRequest{}).
+			Returns(http.StatusNoContent, nil).
+			Errors(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound).
+			Secure(SecurityBearer)
+
+	UsageDoc = openapi.Op("查询当前周期的配额用量").Tags("usage").
+			Describe("使用 API key 访问时，按 key 计数的配额显示该 key 的用量").
+			Returns(http.StatusOK, listUsageResponse{}).
+			Errors(http.StatusUnauthorized).
+			Secure(SecurityBearer, SecurityAPIKey)
+
+	CreateLinkDoc = openapi.Op("创建短链接").Tags("links").
+			Body(createLinkRequest{}).
+			Returns(http.StatusCreated, linkResponse{}).
+			Errors(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusConflict, http.StatusTooManyRequests).
+			Secure(SecurityBearer, SecurityAPIKey)
+	ListLinksDoc = openapi.Op("分页列出当前用户的短链接").Tags("links").
+			Params(listLinksRequest{}).
+			Returns(http.StatusOK, listLinksResponse{}).
+			Errors(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden).
+			Secure(SecurityBearer, SecurityAPIKey)
+	GetLinkDoc = openapi.Op("查询短链接").Tags("links").
+			Returns(http.StatusOK, linkResponse{}).
+			Errors(http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound).
+			Secure(SecurityBearer, SecurityAPIKey)
+	UpdateLinkDoc = openapi.Op("修改短链接").Tags("links").
+			Body(updateLinkRequest{}).
+			Returns(http.StatusOK, linkResponse{}).
+			Errors(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound).
+			Secure(SecurityBearer, SecurityAPIKey)
+	DeleteLinkDoc = openapi.Op("删除短链接").Tags("links").
+			Returns(http.StatusNoContent, nil).
+			Errors(http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound).
+			Secure(SecurityBearer, SecurityAPIKey)
+	GetLogLevelDoc = openapi.Op("查询当前日志级别").Tags("admin").
+			Returns(http.StatusOK, utils.LogLevelState{}).
+			Errors(http.StatusUnauthorized, http.StatusForbidden).
+			Secure(SecurityBearer)
+	SetLogLevelDoc = openapi.Op("临时调整日志级别").Tags("admin").
+			Describe("设置 duration 时到期自动恢复为配置中的级别；也可向进程发送 SIGUSR1 在 debug 与配置级别之间切换").
+			Body(setLogLevelRequest{}).
+			Returns(http.StatusOK, utils.LogLevelState{}).
+			Errors(http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden).
+			Secure(SecurityBearer)
+	RevertLogLevelDoc = openapi.Op("恢复为配置中的日志级别").Tags("admin").
+				Returns(http.StatusOK, utils.LogLevelState{}).
+				Errors(http.StatusUnauthorized, http.StatusForbidden).
+				Secure(SecurityBearer)
+
+	RedirectDoc = openapi.Op("短链接跳转").Tags("links").
+			ReturnsDescribed(http.StatusFound, nil, "跳转到目标地址").
+			ReturnsDescribed(http.StatusMovedPermanently, nil, "跳转到目标地址（permanent 短链接）").
+			Errors(http.StatusNotFound, http.StatusGone, http.StatusTooManyRequests)
+)
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"net/http"
+
+	"echotest/internal/service"
+	"echotest/pkg/problem"
+)
+
+// 包初始化时登记到 problem.Default 一次，Init 使用的错误处理器即据此映射；重复构建路由不会重复登记
+func init() {
+	RegisterErrors(problem.Default)
+}
+
+// RegisterErrors 把 service 层的领域错误登记到 problem 注册表，handler 直接返回这些错误即可得到对应的状态码
+func RegisterErrors(r *problem.Registry) {
+	// 认证
+	r.Register(service.ErrEmailTaken, http.StatusConflict, "email-taken", "Email already registered")
+	r.Register(service.ErrInvalidCredentials, http.StatusUnauthorized, "invalid-credentials", "Invalid credentials")
+	r.Register(service.ErrUserNotFound, http.StatusUnauthorized, "user-not-found", "User no longer exists")
+	r.Register(service.ErrInvalidRefreshToken, http.StatusUnauthorized, "invalid-refresh-token", "Invalid refresh token")
+	r.Register(service.ErrRefreshTokenReused, http.StatusUnauthorized, "refresh-token-reused", "Refresh token reused")
+	// API key
+	r.Register(service.ErrAPIKeyNotFound, http.StatusNotFound, "api-key-not-found", "API key not found")
+	r.Register(service.ErrScopeNotGranted, http.StatusForbidden, "scope-not-granted", "Scope not granted")
+	// 短链接
+	r.Register(service.ErrLinkNotFound, http.StatusNotFound, "link-not-found", "Link not found")
+	r.Register(service.ErrLinkExpired, http.StatusGone, "link-expired", "Link expired")
+	r.Register(service.ErrAliasTaken, http.StatusConflict, "alias-taken", "Alias already taken")
+}
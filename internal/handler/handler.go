@@ -0,0 +1,24 @@
+// Package handler 实现 HTTP 接口：解析与校验请求、调用 service、组装响应。
+package handler
+
+import (
+	"github.com/labstack/echo/v5"
+)
+
+// bindAndValidate 绑定请求参数（路径、查询、请求体）并使用 CustomValidator 校验；
+// 校验错误原样返回，由 problem.HTTPErrorHandler 逐字段输出
+func bindAndValidate(c *echo.Context, req any) error {
+	if err := c.Bind(req); err != nil {
+		return err
+	}
+	return c.Validate(req)
+}
+
+// currentUserID 读取 JWT 中间件写入 context 的 userID
+func currentUserID(c *echo.Context) (int64, error) {
+	id, ok := c.Get("userID").(int)
+	if !ok || id <= 0 {
+		return 0, echo.ErrUnauthorized
+	}
+	return int64(id), nil
+}
@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"echotest/internal/analytics"
+	db "echotest/internal/models"
+	"echotest/internal/service"
+	"echotest/pkg/requestid"
+
+	"github.com/labstack/echo/v5"
+)
+
+// ClickRecorder 记录跳转产生的点击事件，实现必须是非阻塞的（见 analytics.Recorder）
+type ClickRecorder interface {
+	Record(click db.LinkClick) bool
+}
+
+// LinkHandler 短链接接口
+type LinkHandler struct {
+	svc *service.LinkService
+	// baseURL 生成 short_url 使用的对外地址，为空时按请求的 scheme 与 host 拼接
+	baseURL string
+	// clicks 为 nil 时不记录点击
+	clicks ClickRecorder
+}
+
+// NewLinkHandler 创建 LinkHandler
+func NewLinkHandler(svc *service.LinkService, baseURL string, clicks ClickRecorder) *LinkHandler {
+	return &LinkHandler{svc: svc, baseURL: strings.TrimRight(baseURL, "/"), clicks: clicks}
+}
+
+type createLinkRequest struct {
+	TargetURL string     `json:"target_url" validate:"required,safe_url,max=2048"`
+	Alias     string     `json:"alias" validate:"omitempty,alias"`
+	Permanent bool       `json:"permanent"`
+	ExpiresAt *time.Time `json:"expires_at" validate:"omitempty,after"`
+}
+
+type updateLinkRequest struct {
+	Code      string     `param:"code" validate:"required"`
+	TargetURL string     `json:"target_url" validate:"required,safe_url,max=2048"`
+	Permanent bool       `json:"permanent"`
+	ExpiresAt *time.Time `json:"expires_at" validate:"omitempty,after"`
+}
+
+type listLinksRequest struct {
+	Limit  int `query:"limit" validate:"omitempty,min=1,max=100"`
+	Offset int `query:"offset" validate:"min=0"`
+}
+
+type linkResponse struct {
+	Code      string     `json:"code"`
+	ShortURL  string     `json:"short_url"`
+	TargetURL string     `json:"target_url"`
+	Permanent bool       `json:"permanent"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type listLinksResponse struct {
+	Items  []linkResponse `json:"items"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
+
+// Create POST /api/links
+func (h *LinkHandler) Create(c *echo.Context) error {
+	ownerID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+	var req createLinkRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	link, err := h.svc.Create(c.Request().Context(), service.CreateLinkInput{
+		OwnerID:   ownerID,
+		TargetURL: req.TargetURL,
+		Alias:     req.Alias,
+		Permanent: req.Permanent,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusCreated, h.toResponse(c, link))
+}
+
+// List GET /api/links
+func (h *LinkHandler) List(c *echo.Context) error {
+	ownerID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+	var req listLinksRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	if req.Limit == 0 {
+		req.Limit = 20
+	}
+	links, err := h.svc.List(c.Request().Context(), ownerID, req.Limit, req.Offset)
+	if err != nil {
+		return err
+	}
+	items := make([]linkResponse, len(links))
+	for i, link := range links {
+		items[i] = h.toResponse(c, link)
+	}
+	return c.JSON(http.StatusOK, listLinksResponse{Items: items, Limit: req.Limit, Offset: req.Offset})
+}
+
+// Get GET /api/links/:code
+func (h *LinkHandler) Get(c *echo.Context) error {
+	ownerID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+	link, err := h.svc.Get(c.Request().Context(), ownerID, c.Param("code"))
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, h.toResponse(c, link))
+}
+
+// Update PUT /api/links/:code
+func (h *LinkHandler) Update(c *echo.Context) error {
+	ownerID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+	var req updateLinkRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	link, err := h.svc.Update(c.Request().Context(), service.UpdateLinkInput{
+		OwnerID:   ownerID,
+		Code:      req.Code,
+		TargetURL: req.TargetURL,
+		Permanent: req.Permanent,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		return err
+	}
+	return c.JSON(http.StatusOK, h.toResponse(c, link))
+}
+
+// Delete DELETE /api/links/:code
+func (h *LinkHandler) Delete(c *echo.Context) error {
+	ownerID, err := currentUserID(c)
+	if err != nil {
+		return err
+	}
+	if err := h.svc.Delete(c.Request().Context(), ownerID, c.Param("code")); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Redirect GET /:code，永久链接返回 301，其余返回 302
+func (h *LinkHandler) Redirect(c *echo.Context) error {
+	link, err := h.svc.Resolve(c.Request().Context(), c.Param("code"))
+	if err != nil {
+		return err
+	}
+	if h.clicks != nil {
+		req := c.Request()
+		h.clicks.Record(db.LinkClick{
+			LinkID:    link.ID,
+			ClickedAt: time.Now(),
+			Referrer:  req.Referer(),
+			UserAgent: req.UserAgent(),
+			IpPrefix:  analytics.TruncateIP(c.RealIP()),
+			RequestID: requestid.GetRequestID(c),
+		})
+	}
+	status := http.StatusFound
+	if link.Permanent {
+		status = http.StatusMovedPermanently
+	}
+	return c.Redirect(status, link.TargetUrl)
+}
+
+func (h *LinkHandler) toResponse(c *echo.Context, link db.Link) linkResponse {
+	base := h.baseURL
+	if base == "" {
+		base = c.Scheme() + "://" + c.Request().Host
+	}
+	resp := linkResponse{
+		Code:      link.Code,
+		ShortURL:  base + "/" + link.Code,
+		TargetURL: link.TargetUrl,
+		Permanent: link.Permanent,
+		CreatedAt: link.CreatedAt,
+		UpdatedAt: link.UpdatedAt,
+	}
+	if link.ExpiresAt.Valid {
+		t := link.ExpiresAt.Time
+		resp.ExpiresAt = &t
+	}
+	return resp
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"echotest/pkg/utils"
+
+	"github.com/labstack/echo/v5"
+)
+
+// LogLevelHandler 运行时日志级别管理接口，排查问题时临时打开 debug 而无需重新部署
+type LogLevelHandler struct {
+	levels *utils.LogLevels
+}
+
+// NewLogLevelHandler 创建 LogLevelHandler
+func NewLogLevelHandler(levels *utils.LogLevels) *LogLevelHandler {
+	return &LogLevelHandler{levels: levels}
+}
+
+type setLogLevelRequest struct {
+	Level string `json:"level" validate:"required,oneof=debug info warn error"`
+	// Overrides logger 名称 -> 级别；不传时保留当前覆盖，传 {} 清空
+	Overrides map[string]string `json:"overrides" validate:"omitempty,dive,keys,required,endkeys,oneof=debug info warn error"`
+	// Duration 到期后自动恢复为配置中的级别；不传时保持到 DELETE 或进程重启
+	Duration string `json:"duration" validate:"omitempty,duration=24h"`
+}
+
+// Get GET /admin/loglevel
+func (h *LogLevelHandler) Get(c *echo.Context) error {
+	return c.JSON(http.StatusOK, h.levels.State())
+}
+
+// Set PUT /admin/loglevel
+func (h *LogLevelHandler) Set(c *echo.Context) error {
+	var req setLogLevelRequest
+	if err := bindAndValidate(c, &req); err != nil {
+		return err
+	}
+	level, overrides, err := utils.ParseLogLevels(req.Level, req.Overrides)
+	if err != nil {
+		return err
+	}
+	if req.Overrides == nil {
+		overrides = nil
+	}
+	var d time.Duration
+	if req.Duration != "" {
+		// 已由 duration 规则校验
+		d, _ = time.ParseDuration(req.Duration)
+	}
+	h.levels.Set(level, overrides, d)
+	c.Logger().Warn("log level changed", "level", req.Level, "overrides", req.Overrides, "duration", req.Duration)
+	return c.JSON(http.StatusOK, h.levels.State())
+}
+
+// Revert DELETE /admin/loglevel 恢复为配置中的级别
+func (h *LogLevelHandler) Revert(c *echo.Context) error {
+	h.levels.Revert()
+	c.Logger().Warn("log level reverted to config")
+	return c.JSON(http.StatusOK, h.levels.State())
+}
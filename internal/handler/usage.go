@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"echotest/internal/service"
+	"echotest/pkg/utils"
+
+	"github.com/labstack/echo/v5"
+)
+
+// UsageHandler 用量配额查询接口
+type UsageHandler struct {
+	svc *service.QuotaService
+	// quotas 返回当前请求适用的配额（随配置热加载变化）
+	quotas func(c *echo.Context) []utils.Quota
+}
+
+// NewUsageHandler 创建 UsageHandler
+func NewUsageHandler(svc *service.QuotaService, quotas func(c *echo.Context) []utils.Quota) *UsageHandler {
+	return &UsageHandler{svc: svc, quotas: quotas}
+}
+
+type usageResponse struct {
+	Name      string    `json:"name"`
+	Period    string    `json:"period"`
+	Limit     int64     `json:"limit"`
+	SoftLimit int64     `json:"soft_limit,omitempty"`
+	Used      int64     `json:"used"`
+	Remaining int64     `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+	// SoftExceeded 已达到软上限
+	SoftExceeded bool `json:"soft_exceeded"`
+}
+
+type listUsageResponse struct {
+	Items []usageResponse `json:"items"`
+}
+
+// Get GET /api/usage
+// 使用 API key 访问时，按 key 计数的配额显示该 key 的用量，否则显示用户的用量
+func (h *UsageHandler) Get(c *echo.Context) error {
+	if _, err := currentUserID(c); err != nil {
+		return err
+	}
+	usage, err := h.svc.Usage(c.Request().Context(), h.quotas(c))
+	if err != nil {
+		return err
+	}
+	items := make([]usageResponse, len(usage))
+	for i, u := range usage {
+		items[i] = usageResponse{
+			Name:         u.Name,
+			Period:       u.Period,
+			Limit:        u.Limit,
+			SoftLimit:    u.Soft,
+			Used:         u.Used,
+			Remaining:    max(u.Limit-u.Used, 0),
+			Reset:        u.Reset,
+			SoftExceeded: u.Soft > 0 && u.Used >= u.Soft,
+		}
+	}
+	return c.JSON(http.StatusOK, listUsageResponse{Items: items})
+}
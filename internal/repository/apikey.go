@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+
+	db "echotest/internal/models"
+)
+
+// APIKeyRepository API key 的数据访问
+type APIKeyRepository struct {
+	q *db.Queries
+}
+
+// NewAPIKeyRepository 创建 APIKeyRepository
+func NewAPIKeyRepository(conn db.DBTX) *APIKeyRepository {
+	return &APIKeyRepository{q: db.New(conn)}
+}
+
+// Create 保存新的 API key（只保存哈希）
+func (r *APIKeyRepository) Create(ctx context.Context, arg db.CreateAPIKeyParams) (db.ApiKey, error) {
+	key, err := r.q.CreateAPIKey(ctx, arg)
+	return key, translateError(err)
+}
+
+// GetByHash 按哈希查询 API key，同时带出所属用户的邮箱与角色
+func (r *APIKeyRepository) GetByHash(ctx context.Context, hash string) (db.GetAPIKeyByHashRow, error) {
+	key, err := r.q.GetAPIKeyByHash(ctx, hash)
+	return key, translateError(err)
+}
+
+// ListOwned 列出 userID 的全部 API key，按创建时间倒序
+func (r *APIKeyRepository) ListOwned(ctx context.Context, userID int64) ([]db.ApiKey, error) {
+	keys, err := r.q.ListUserAPIKeys(ctx, userID)
+	return keys, translateError(err)
+}
+
+// DeleteOwned 删除属于 userID 的 API key，不存在时返回 ErrNotFound
+func (r *APIKeyRepository) DeleteOwned(ctx context.Context, id, userID int64) error {
+	n, err := r.q.DeleteUserAPIKey(ctx, db.DeleteUserAPIKeyParams{ID: id, UserID: userID})
+	if err != nil {
+		return translateError(err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Touch 更新最近使用时间
+func (r *APIKeyRepository) Touch(ctx context.Context, id int64) error {
+	return translateError(r.q.TouchAPIKey(ctx, id))
+}
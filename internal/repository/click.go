@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	db "echotest/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// ClickRepository 点击事件的数据访问。
+// sqlc 在 database/sql 下不支持 COPY，这里直接使用 lib/pq 的 CopyIn 批量写入。
+type ClickRepository struct {
+	db *sql.DB
+}
+
+// NewClickRepository 创建 ClickRepository
+func NewClickRepository(conn *sql.DB) *ClickRepository {
+	return &ClickRepository{db: conn}
+}
+
+// CopyClicks 在一个事务内通过 COPY 批量写入点击事件。
+// 先 COPY 到临时表再插入仍存在的链接的点击：刷新前被删除的链接只丢弃它自己的点击，
+// 不会因外键约束让整批写入失败
+func (r *ClickRepository) CopyClicks(ctx context.Context, clicks []db.LinkClick) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `CREATE TEMP TABLE link_clicks_staging (
+    link_id    BIGINT      NOT NULL,
+    clicked_at TIMESTAMPTZ NOT NULL,
+    referrer   TEXT        NOT NULL,
+    user_agent TEXT        NOT NULL,
+    ip_prefix  TEXT        NOT NULL,
+    request_id TEXT        NOT NULL
+) ON COMMIT DROP`); err != nil {
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("link_clicks_staging",
+		"link_id", "clicked_at", "referrer", "user_agent", "ip_prefix", "request_id"))
+	if err != nil {
+		return err
+	}
+	for _, c := range clicks {
+		if _, err := stmt.ExecContext(ctx, c.LinkID, c.ClickedAt, c.Referrer, c.UserAgent, c.IpPrefix, c.RequestID); err != nil {
+			stmt.Close()
+			return err
+		}
+	}
+	// 无参数的 Exec 将缓冲的数据真正发送给服务端
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return err
+	}
+	if err := stmt.Close(); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO link_clicks (link_id, clicked_at, referrer, user_agent, ip_prefix, request_id)
+SELECT s.link_id, s.clicked_at, s.referrer, s.user_agent, s.ip_prefix, s.request_id
+FROM link_clicks_staging s
+WHERE EXISTS (SELECT 1 FROM links l WHERE l.id = s.link_id)`); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+
+	db "echotest/internal/models"
+)
+
+// LinkRepository 短链接的数据访问
+type LinkRepository struct {
+	q *db.Queries
+}
+
+// NewLinkRepository 基于连接池（或事务）创建 LinkRepository
+func NewLinkRepository(conn db.DBTX) *LinkRepository {
+	return &LinkRepository{q: db.New(conn)}
+}
+
+// Create 新建短链接，code 已存在时返回 ErrDuplicate
+func (r *LinkRepository) Create(ctx context.Context, arg db.CreateLinkParams) (db.Link, error) {
+	link, err := r.q.CreateLink(ctx, arg)
+	return link, translateError(err)
+}
+
+// GetByCode 按短码查询，用于跳转
+func (r *LinkRepository) GetByCode(ctx context.Context, code string) (db.Link, error) {
+	link, err := r.q.GetLinkByCode(ctx, code)
+	return link, translateError(err)
+}
+
+// GetOwned 查询属于 ownerID 的短链接
+func (r *LinkRepository) GetOwned(ctx context.Context, code string, ownerID int64) (db.Link, error) {
+	link, err := r.q.GetOwnerLink(ctx, db.GetOwnerLinkParams{Code: code, OwnerID: ownerID})
+	return link, translateError(err)
+}
+
+// ListOwned 分页列出 ownerID 的短链接，按创建时间倒序
+func (r *LinkRepository) ListOwned(ctx context.Context, ownerID int64, limit, offset int32) ([]db.Link, error) {
+	links, err := r.q.ListOwnerLinks(ctx, db.ListOwnerLinksParams{OwnerID: ownerID, Limit: limit, Offset: offset})
+	return links, translateError(err)
+}
+
+// UpdateOwned 更新属于 ownerID 的短链接
+func (r *LinkRepository) UpdateOwned(ctx context.Context, arg db.UpdateOwnerLinkParams) (db.Link, error) {
+	link, err := r.q.UpdateOwnerLink(ctx, arg)
+	return link, translateError(err)
+}
+
+// DeleteOwned 删除属于 ownerID 的短链接，不存在时返回 ErrNotFound
+func (r *LinkRepository) DeleteOwned(ctx context.Context, code string, ownerID int64) error {
+	n, err := r.q.DeleteOwnerLink(ctx, db.DeleteOwnerLinkParams{Code: code, OwnerID: ownerID})
+	if err != nil {
+		return translateError(err)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+// Package repository 封装 sqlc 生成的查询，把驱动层错误统一转换为仓储层错误。
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNotFound 记录不存在
+	ErrNotFound = errors.New("record not found")
+	// ErrDuplicate 违反唯一约束
+	ErrDuplicate = errors.New("duplicate record")
+	// ErrStale 条件更新未命中，记录已被并发修改
+	ErrStale = errors.New("record changed concurrently")
+)
+
+// pgUniqueViolation Postgres 唯一约束冲突的错误码
+const pgUniqueViolation = "23505"
+
+// translateError 将 sql/pq 错误映射为 ErrNotFound、ErrDuplicate，其余原样返回
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation {
+		return ErrDuplicate
+	}
+	return err
+}
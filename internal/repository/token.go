@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	db "echotest/internal/models"
+)
+
+// TokenRepository refresh token 与 access token 吊销名单的数据访问
+type TokenRepository struct {
+	db *sql.DB
+	q  *db.Queries
+}
+
+// NewTokenRepository 创建 TokenRepository；轮换需要事务，因此必须传入连接池
+func NewTokenRepository(conn *sql.DB) *TokenRepository {
+	return &TokenRepository{db: conn, q: db.New(conn)}
+}
+
+// CreateRefresh 保存新的 refresh token（只保存哈希）
+func (r *TokenRepository) CreateRefresh(ctx context.Context, arg db.CreateRefreshTokenParams) (db.RefreshToken, error) {
+	token, err := r.q.CreateRefreshToken(ctx, arg)
+	return token, translateError(err)
+}
+
+// GetRefreshByHash 按哈希查询 refresh token
+func (r *TokenRepository) GetRefreshByHash(ctx context.Context, hash string) (db.RefreshToken, error) {
+	token, err := r.q.GetRefreshTokenByHash(ctx, hash)
+	return token, translateError(err)
+}
+
+// RotateRefresh 在一个事务内把 oldID 标记为已轮换并保存新 token。
+// oldID 已被轮换或吊销（包括并发请求抢先轮换）时返回 ErrStale。
+func (r *TokenRepository) RotateRefresh(ctx context.Context, oldID int64, next db.CreateRefreshTokenParams) (db.RefreshToken, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return db.RefreshToken{}, err
+	}
+	defer tx.Rollback()
+
+	q := r.q.WithTx(tx)
+	n, err := q.MarkRefreshTokenRotated(ctx, oldID)
+	if err != nil {
+		return db.RefreshToken{}, translateError(err)
+	}
+	if n == 0 {
+		return db.RefreshToken{}, ErrStale
+	}
+	token, err := q.CreateRefreshToken(ctx, next)
+	if err != nil {
+		return db.RefreshToken{}, translateError(err)
+	}
+	return token, translateError(tx.Commit())
+}
+
+// RevokeFamily 吊销同一登录会话派生出的全部 refresh token
+func (r *TokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	return translateError(r.q.RevokeRefreshTokenFamily(ctx, familyID))
+}
+
+// RevokeAccess 把 access token 的 jti 加入吊销名单，保留到 token 自身过期为止
+func (r *TokenRepository) RevokeAccess(ctx context.Context, jti string, expiresAt time.Time) error {
+	return translateError(r.q.RevokeAccessToken(ctx, db.RevokeAccessTokenParams{Jti: jti, ExpiresAt: expiresAt}))
+}
+
+// IsAccessRevoked 判断 jti 是否在吊销名单中
+func (r *TokenRepository) IsAccessRevoked(ctx context.Context, jti string) (bool, error) {
+	revoked, err := r.q.IsAccessTokenRevoked(ctx, jti)
+	return revoked, translateError(err)
+}
+
+// PurgeExpiredAccess 清理已自然过期、无需再拦截的 jti
+func (r *TokenRepository) PurgeExpiredAccess(ctx context.Context) error {
+	return translateError(r.q.DeleteExpiredRevokedAccessTokens(ctx))
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	db "echotest/internal/models"
+)
+
+// UsageRepository 用量配额计数的数据访问
+type UsageRepository struct {
+	q *db.Queries
+}
+
+// NewUsageRepository 创建 UsageRepository
+func NewUsageRepository(conn db.DBTX) *UsageRepository {
+	return &UsageRepository{q: db.New(conn)}
+}
+
+// Increment 在用量小于 limit 时原子地加一并返回新的用量；已达到 limit 时不计数，ok 为 false
+func (r *UsageRepository) Increment(ctx context.Context, subject, quota string, periodStart time.Time, limit int64) (used int64, ok bool, err error) {
+	used, err = r.q.IncrementUsage(ctx, db.IncrementUsageParams{
+		Subject:     subject,
+		Quota:       quota,
+		PeriodStart: periodStart,
+		MaxUsed:     limit,
+	})
+	if err = translateError(err); errors.Is(err, ErrNotFound) {
+		return 0, false, nil
+	}
+	return used, err == nil, err
+}
+
+// Decrement 撤销一次计数，用量不会小于 0
+func (r *UsageRepository) Decrement(ctx context.Context, subject, quota string, periodStart time.Time) error {
+	return translateError(r.q.DecrementUsage(ctx, db.DecrementUsageParams{
+		Subject:     subject,
+		Quota:       quota,
+		PeriodStart: periodStart,
+	}))
+}
+
+// Get 返回周期内的用量，没有记录时为 0
+func (r *UsageRepository) Get(ctx context.Context, subject, quota string, periodStart time.Time) (int64, error) {
+	used, err := r.q.GetUsage(ctx, db.GetUsageParams{
+		Subject:     subject,
+		Quota:       quota,
+		PeriodStart: periodStart,
+	})
+	if err = translateError(err); errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	return used, err
+}
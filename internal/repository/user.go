@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+
+	db "echotest/internal/models"
+)
+
+// UserRepository 用户的数据访问
+type UserRepository struct {
+	q *db.Queries
+}
+
+// NewUserRepository 创建 UserRepository
+func NewUserRepository(conn db.DBTX) *UserRepository {
+	return &UserRepository{q: db.New(conn)}
+}
+
+// Create 新建用户，邮箱已存在时返回 ErrDuplicate
+func (r *UserRepository) Create(ctx context.Context, email, passwordHash string) (db.User, error) {
+	user, err := r.q.CreateUser(ctx, db.CreateUserParams{Email: email, PasswordHash: passwordHash})
+	return user, translateError(err)
+}
+
+// GetByEmail 按邮箱查询
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (db.User, error) {
+	user, err := r.q.GetUserByEmail(ctx, email)
+	return user, translateError(err)
+}
+
+// GetByID 按 ID 查询
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (db.User, error) {
+	user, err := r.q.GetUserByID(ctx, id)
+	return user, translateError(err)
+}
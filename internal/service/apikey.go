@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	db "echotest/internal/models"
+	"echotest/internal/repository"
+	"echotest/pkg/requestid"
+	"echotest/pkg/shortcode"
+	"echotest/pkg/utils"
+)
+
+var (
+	// ErrAPIKeyNotFound API key 不存在或不属于当前用户
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	// ErrScopeNotGranted 申请的 scope 超出了用户角色拥有的权限
+	ErrScopeNotGranted = errors.New("scope not granted to user")
+)
+
+// apiKeyIDLength key 中公开部分的长度，与前缀一起保存用于在列表中辨认
+const apiKeyIDLength = 8
+
+// touchInterval 同一个 key 最多每隔多久写一次 last_used_at，避免每个请求都写库
+const touchInterval = time.Minute
+
+// APIKeyStore API key 存储，由 repository.APIKeyRepository 实现
+type APIKeyStore interface {
+	Create(ctx context.Context, arg db.CreateAPIKeyParams) (db.ApiKey, error)
+	GetByHash(ctx context.Context, hash string) (db.GetAPIKeyByHashRow, error)
+	ListOwned(ctx context.Context, userID int64) ([]db.ApiKey, error)
+	DeleteOwned(ctx context.Context, id, userID int64) error
+	Touch(ctx context.Context, id int64) error
+}
+
+// CreateAPIKeyInput 创建 API key 的参数
+type CreateAPIKeyInput struct {
+	UserID    int64
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+// APIKeyService API key 的签发、吊销与校验。
+// key 形如 esk_<8 位 ID>_<随机串>，只在创建时返回一次，数据库只保存其 SHA-256。
+type APIKeyService struct {
+	keys   APIKeyStore
+	users  UserStore
+	scopes ScopeResolver
+
+	// touched key ID -> 上次写入 last_used_at 的时间
+	touched sync.Map
+}
+
+// NewAPIKeyService 创建 APIKeyService；scopes 用于限制 key 的权限不超过所属用户的角色
+func NewAPIKeyService(keys APIKeyStore, users UserStore, scopes ScopeResolver) *APIKeyService {
+	return &APIKeyService{keys: keys, users: users, scopes: scopes}
+}
+
+// Create 创建 API key，返回记录与明文 key（之后无法再次获取）；用户已不存在时返回 ErrUserNotFound
+func (s *APIKeyService) Create(ctx context.Context, in CreateAPIKeyInput) (db.ApiKey, string, error) {
+	user, err := s.users.GetByID(ctx, in.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		// 用户已删除但 access token 尚未过期
+		return db.ApiKey{}, "", ErrUserNotFound
+	}
+	if err != nil {
+		return db.ApiKey{}, "", err
+	}
+	granted := s.scopes(user.Role)
+	for _, scope := range in.Scopes {
+		if !utils.ScopeGranted(granted, scope) {
+			return db.ApiKey{}, "", ErrScopeNotGranted
+		}
+	}
+
+	id, err := shortcode.Generate(apiKeyIDLength)
+	if err != nil {
+		return db.ApiKey{}, "", err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return db.ApiKey{}, "", err
+	}
+	prefix := utils.APIKeyPrefix + id
+	raw := prefix + "_" + secret
+
+	var expiresAt sql.NullTime
+	if in.ExpiresAt != nil {
+		expiresAt = sql.NullTime{Time: *in.ExpiresAt, Valid: true}
+	}
+	key, err := s.keys.Create(ctx, db.CreateAPIKeyParams{
+		UserID:    in.UserID,
+		Name:      in.Name,
+		Prefix:    prefix,
+		KeyHash:   hashToken(raw),
+		Scopes:    in.Scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return db.ApiKey{}, "", err
+	}
+	return key, raw, nil
+}
+
+// List 列出用户的 API key
+func (s *APIKeyService) List(ctx context.Context, userID int64) ([]db.ApiKey, error) {
+	return s.keys.ListOwned(ctx, userID)
+}
+
+// Delete 吊销（删除）用户的 API key
+func (s *APIKeyService) Delete(ctx context.Context, userID, id int64) error {
+	err := s.keys.DeleteOwned(ctx, id, userID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	s.touched.Delete(id)
+	return err
+}
+
+// AuthenticateAPIKey 实现 utils.APIKeyAuthenticator。
+// 生效的 scope 为 key 的 scope 与用户当前角色权限的交集，角色降级后已有 key 的权限随之收窄。
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, raw string) (utils.Principal, bool, error) {
+	key, err := s.keys.GetByHash(ctx, hashToken(raw))
+	if errors.Is(err, repository.ErrNotFound) {
+		return utils.Principal{}, false, nil
+	}
+	if err != nil {
+		return utils.Principal{}, false, err
+	}
+	now := time.Now()
+	if key.ExpiresAt.Valid && !key.ExpiresAt.Time.After(now) {
+		return utils.Principal{}, false, nil
+	}
+
+	granted := s.scopes(key.Role)
+	scopes := make([]string, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		if utils.ScopeGranted(granted, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	if last, ok := s.touched.Load(key.ID); !ok || now.Sub(last.(time.Time)) >= touchInterval {
+		// last_used_at 只是参考信息，写入失败不影响认证；失败后同样等 touchInterval 再重试，避免数据库故障时每个请求都写
+		s.touched.Store(key.ID, now)
+		if err := s.keys.Touch(ctx, key.ID); err != nil {
+			requestid.LoggerFromContext(ctx).Warn("update api key last_used_at failed", "api_key_id", key.ID, "error", err)
+		}
+	}
+	return utils.Principal{
+		UserID:   int(key.UserID),
+		Email:    key.Email,
+		Role:     key.Role,
+		Plan:     key.Plan,
+		Scopes:   scopes,
+		APIKeyID: key.ID,
+	}, true, nil
+}
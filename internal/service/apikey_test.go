@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	db "echotest/internal/models"
+	"echotest/internal/repository"
+	"echotest/pkg/utils"
+)
+
+type fakeAPIKeyStore struct {
+	keys     map[string]db.ApiKey // hash -> key
+	users    *fakeUserStore
+	touches  int
+	touchErr error
+}
+
+func (f *fakeAPIKeyStore) Create(_ context.Context, arg db.CreateAPIKeyParams) (db.ApiKey, error) {
+	k := db.ApiKey{ID: int64(len(f.keys) + 1), UserID: arg.UserID, Name: arg.Name, Prefix: arg.Prefix,
+		KeyHash: arg.KeyHash, Scopes: arg.Scopes, ExpiresAt: arg.ExpiresAt, CreatedAt: time.Now()}
+	f.keys[arg.KeyHash] = k
+	return k, nil
+}
+
+func (f *fakeAPIKeyStore) GetByHash(ctx context.Context, hash string) (db.GetAPIKeyByHashRow, error) {
+	k, ok := f.keys[hash]
+	if !ok {
+		return db.GetAPIKeyByHashRow{}, repository.ErrNotFound
+	}
+	u, _ := f.users.GetByID(ctx, k.UserID)
+	return db.GetAPIKeyByHashRow{ID: k.ID, UserID: k.UserID, Scopes: k.Scopes, ExpiresAt: k.ExpiresAt, Email: u.Email, Role: u.Role}, nil
+}
+
+func (f *fakeAPIKeyStore) ListOwned(context.Context, int64) ([]db.ApiKey, error) { return nil, nil }
+
+func (f *fakeAPIKeyStore) DeleteOwned(_ context.Context, id, userID int64) error {
+	for h, k := range f.keys {
+		if k.ID == id && k.UserID == userID {
+			delete(f.keys, h)
+			return nil
+		}
+	}
+	return repository.ErrNotFound
+}
+
+func (f *fakeAPIKeyStore) Touch(context.Context, int64) error {
+	f.touches++
+	return f.touchErr
+}
+
+var testRoles = map[string][]string{"user": {"links:read", "links:write"}, "viewer": {"links:read"}}
+
+func newTestAPIKeys(t *testing.T) (*APIKeyService, *fakeAPIKeyStore, db.User) {
+	t.Helper()
+	users := &fakeUserStore{users: map[string]db.User{}}
+	user, _ := users.Create(context.Background(), "ci@example.com", "x")
+	user.Role = "user"
+	users.users[user.Email] = user
+	store := &fakeAPIKeyStore{keys: map[string]db.ApiKey{}, users: users}
+	return NewAPIKeyService(store, users, func(role string) []string { return testRoles[role] }), store, user
+}
+
+func TestAPIKeyService_CreateAndAuthenticate(t *testing.T) {
+	svc, store, user := newTestAPIKeys(t)
+	ctx := context.Background()
+
+	key, raw, err := svc.Create(ctx, CreateAPIKeyInput{UserID: user.ID, Name: "ci", Scopes: []string{"links:write"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(raw, key.Prefix+"_") || !strings.HasPrefix(raw, utils.APIKeyPrefix) {
+		t.Errorf("key %q 应以 %q 开头", raw, key.Prefix)
+	}
+	if key.KeyHash == raw || strings.Contains(key.KeyHash, raw) {
+		t.Error("不应保存明文 key")
+	}
+
+	p, ok, err := svc.AuthenticateAPIKey(ctx, raw)
+	if err != nil || !ok {
+		t.Fatalf("认证失败: %v %v", ok, err)
+	}
+	if p.UserID != int(user.ID) || p.Email != user.Email || p.APIKeyID != key.ID || len(p.Scopes) != 1 {
+		t.Errorf("身份不正确: %+v", p)
+	}
+	// 一分钟内重复使用只写一次 last_used_at
+	_, _, _ = svc.AuthenticateAPIKey(ctx, raw)
+	if store.touches != 1 {
+		t.Errorf("期望写入 1 次 last_used_at，得到 %d", store.touches)
+	}
+
+	if _, ok, _ := svc.AuthenticateAPIKey(ctx, raw+"x"); ok {
+		t.Error("错误的 key 不应通过")
+	}
+	if err := svc.Delete(ctx, user.ID, key.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := svc.AuthenticateAPIKey(ctx, raw); ok {
+		t.Error("删除后的 key 不应通过")
+	}
+}
+
+func TestAPIKeyService_ScopesLimitedByRole(t *testing.T) {
+	svc, store, user := newTestAPIKeys(t)
+	ctx := context.Background()
+
+	if _, _, err := svc.Create(ctx, CreateAPIKeyInput{UserID: user.ID, Name: "x", Scopes: []string{"admin:*"}}); !errors.Is(err, ErrScopeNotGranted) {
+		t.Errorf("超出角色权限应返回 ErrScopeNotGranted，得到 %v", err)
+	}
+
+	_, raw, err := svc.Create(ctx, CreateAPIKeyInput{UserID: user.ID, Name: "x", Scopes: []string{"links:read", "links:write"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 角色降级后，已有 key 的 scope 随之收窄
+	user.Role = "viewer"
+	store.users.users[user.Email] = user
+	p, _, _ := svc.AuthenticateAPIKey(ctx, raw)
+	if len(p.Scopes) != 1 || p.Scopes[0] != "links:read" {
+		t.Errorf("降级后只应保留 links:read，得到 %v", p.Scopes)
+	}
+}
+
+func TestAPIKeyService_Expired(t *testing.T) {
+	svc, _, user := newTestAPIKeys(t)
+	past := time.Now().Add(-time.Minute)
+	_, raw, err := svc.Create(context.Background(), CreateAPIKeyInput{UserID: user.ID, Name: "old", Scopes: []string{"links:read"}, ExpiresAt: &past})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := svc.AuthenticateAPIKey(context.Background(), raw); ok {
+		t.Error("过期的 key 不应通过")
+	}
+}
+
+func TestAPIKeyService_CreateForDeletedUser(t *testing.T) {
+	svc, store, user := newTestAPIKeys(t)
+	delete(store.users.users, user.Email)
+	if _, _, err := svc.Create(context.Background(), CreateAPIKeyInput{UserID: user.ID, Name: "x"}); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("用户已删除应返回 ErrUserNotFound，得到 %v", err)
+	}
+}
+
+func TestAPIKeyService_TouchFailureDoesNotFailAuthentication(t *testing.T) {
+	svc, store, user := newTestAPIKeys(t)
+	ctx := context.Background()
+	_, raw, err := svc.Create(ctx, CreateAPIKeyInput{UserID: user.ID, Name: "ci", Scopes: []string{"links:read"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.touchErr = errors.New("db down")
+	for range 2 {
+		if _, ok, err := svc.AuthenticateAPIKey(ctx, raw); err != nil || !ok {
+			t.Fatalf("写入 last_used_at 失败不应影响认证: %v %v", ok, err)
+		}
+	}
+}
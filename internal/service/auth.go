@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	db "echotest/internal/models"
+	"echotest/internal/repository"
+	"echotest/pkg/utils"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrEmailTaken 注册的邮箱已存在
+	ErrEmailTaken = errors.New("email already registered")
+	// ErrInvalidCredentials 邮箱或密码错误
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrUserNotFound 凭证有效但对应的用户已被删除
+	ErrUserNotFound = errors.New("user no longer exists")
+	// ErrInvalidRefreshToken refresh token 不存在、已过期或已吊销
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	// ErrRefreshTokenReused 已轮换的 refresh token 被再次使用，整个会话已被吊销
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+)
+
+// defaultRefreshTTL 未配置 refresh_duration 时的 refresh token 有效期
+const defaultRefreshTTL = 30 * 24 * time.Hour
+
+// dummyPasswordHash 邮箱不存在时用于比对的哈希，使其与密码错误耗时相同，避免通过响应时间探测已注册的邮箱
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, err := bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+	if err != nil {
+		panic("auth: generate dummy password hash: " + err.Error())
+	}
+	return hash
+})
+
+// UserStore 用户存储，由 repository.UserRepository 实现
+type UserStore interface {
+	Create(ctx context.Context, email, passwordHash string) (db.User, error)
+	GetByEmail(ctx context.Context, email string) (db.User, error)
+	GetByID(ctx context.Context, id int64) (db.User, error)
+}
+
+// TokenStore refresh token 与吊销名单存储，由 repository.TokenRepository 实现
+type TokenStore interface {
+	CreateRefresh(ctx context.Context, arg db.CreateRefreshTokenParams) (db.RefreshToken, error)
+	GetRefreshByHash(ctx context.Context, hash string) (db.RefreshToken, error)
+	RotateRefresh(ctx context.Context, oldID int64, next db.CreateRefreshTokenParams) (db.RefreshToken, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAccess(ctx context.Context, jti string, expiresAt time.Time) error
+	IsAccessRevoked(ctx context.Context, jti string) (bool, error)
+	PurgeExpiredAccess(ctx context.Context) error
+}
+
+// ScopeResolver 返回角色被授予的 scope，签发 access token 时写入 claims
+type ScopeResolver func(role string) []string
+
+// TokenPair 登录或刷新后返回给客户端的凭证
+type TokenPair struct {
+	AccessToken  string
+	ExpiresIn    time.Duration
+	RefreshToken string
+}
+
+// AuthService 登录、refresh token 轮换与吊销。
+// refresh token 为不透明随机串，数据库只保存其 SHA-256；同一次登录派生的 token 属于同一个 family，
+// 已轮换的 token 再次出现说明可能被盗用，此时吊销整个 family。
+type AuthService struct {
+	users      UserStore
+	tokens     TokenStore
+	jwt        *utils.JWTS
+	refreshTTL time.Duration
+	scopes     ScopeResolver
+
+	// revoked 已确认吊销的 jti -> 过期时间，避免对同一 token 重复查库
+	revoked sync.Map
+}
+
+// NewAuthService 创建 AuthService；refreshTTL 为 0 时使用 30 天
+func NewAuthService(users UserStore, tokens TokenStore, jwt *utils.JWTS, refreshTTL time.Duration, scopes ScopeResolver) *AuthService {
+	if refreshTTL <= 0 {
+		refreshTTL = defaultRefreshTTL
+	}
+	return &AuthService{users: users, tokens: tokens, jwt: jwt, refreshTTL: refreshTTL, scopes: scopes}
+}
+
+// Register 注册用户，密码以 bcrypt 保存
+func (s *AuthService) Register(ctx context.Context, email, password string) (db.User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return db.User{}, err
+	}
+	user, err := s.users.Create(ctx, normalizeEmail(email), string(hash))
+	if errors.Is(err, repository.ErrDuplicate) {
+		return db.User{}, ErrEmailTaken
+	}
+	return user, err
+}
+
+// Login 校验邮箱密码并开启新的 token family
+func (s *AuthService) Login(ctx context.Context, email, password string) (TokenPair, error) {
+	user, err := s.users.GetByEmail(ctx, normalizeEmail(email))
+	if errors.Is(err, repository.ErrNotFound) {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return TokenPair{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return TokenPair{}, ErrInvalidCredentials
+	}
+
+	familyID, err := randomToken(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	raw, params, err := s.newRefresh(user.ID, familyID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if _, err := s.tokens.CreateRefresh(ctx, params); err != nil {
+		return TokenPair{}, err
+	}
+	return s.issue(user, raw)
+}
+
+// Refresh 使用 refresh token 换取新的 access token 与 refresh token，旧 refresh token 立即失效
+func (s *AuthService) Refresh(ctx context.Context, rawRefresh string) (TokenPair, error) {
+	current, err := s.tokens.GetRefreshByHash(ctx, hashToken(rawRefresh))
+	if errors.Is(err, repository.ErrNotFound) {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if current.RevokedAt.Valid {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	if current.RotatedAt.Valid {
+		return TokenPair{}, s.reuseDetected(ctx, current.FamilyID)
+	}
+	if !current.ExpiresAt.After(time.Now()) {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	user, err := s.users.GetByID(ctx, current.UserID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	raw, params, err := s.newRefresh(user.ID, current.FamilyID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if _, err := s.tokens.RotateRefresh(ctx, current.ID, params); err != nil {
+		// 并发请求已抢先轮换同一个 token，同样视为重用
+		if errors.Is(err, repository.ErrStale) {
+			return TokenPair{}, s.reuseDetected(ctx, current.FamilyID)
+		}
+		return TokenPair{}, err
+	}
+	return s.issue(user, raw)
+}
+
+// Logout 吊销 refresh token 所在的 family，并把当前 access token 加入吊销名单。
+// rawRefresh 为空或不属于 userID 时只吊销 access token，不能借他人的 refresh token 结束其会话。
+func (s *AuthService) Logout(ctx context.Context, userID int64, rawRefresh, accessJTI string, accessExpiresAt time.Time) error {
+	if rawRefresh != "" {
+		token, err := s.tokens.GetRefreshByHash(ctx, hashToken(rawRefresh))
+		switch {
+		case err == nil && token.UserID != userID:
+			// 不属于调用方，按不存在处理
+		case err == nil:
+			if err := s.tokens.RevokeFamily(ctx, token.FamilyID); err != nil {
+				return err
+			}
+		case !errors.Is(err, repository.ErrNotFound):
+			return err
+		}
+	}
+	if accessJTI == "" {
+		return nil
+	}
+	if err := s.tokens.RevokeAccess(ctx, accessJTI, accessExpiresAt); err != nil {
+		return err
+	}
+	s.storeRevoked(accessJTI, accessExpiresAt)
+	// 顺带清理已过期的吊销记录，名单大小只与有效期内的登出次数有关
+	return s.tokens.PurgeExpiredAccess(ctx)
+}
+
+// storeRevoked 缓存已吊销的 jti，并与 PurgeExpiredAccess 一样清理已过期的条目，
+// 否则登出后不再出现的 jti 会一直留在缓存中
+func (s *AuthService) storeRevoked(jti string, expiresAt time.Time) {
+	now := time.Now()
+	s.revoked.Range(func(key, exp any) bool {
+		if !now.Before(exp.(time.Time)) {
+			s.revoked.Delete(key)
+		}
+		return true
+	})
+	s.revoked.Store(jti, expiresAt)
+}
+
+// IsRevoked 实现 utils.Denylist
+func (s *AuthService) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	if exp, ok := s.revoked.Load(jti); ok {
+		if time.Now().Before(exp.(time.Time)) {
+			return true, nil
+		}
+		s.revoked.Delete(jti)
+	}
+	return s.tokens.IsAccessRevoked(ctx, jti)
+}
+
+func (s *AuthService) reuseDetected(ctx context.Context, familyID string) error {
+	if err := s.tokens.RevokeFamily(ctx, familyID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+func (s *AuthService) newRefresh(userID int64, familyID string) (string, db.CreateRefreshTokenParams, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", db.CreateRefreshTokenParams{}, err
+	}
+	return raw, db.CreateRefreshTokenParams{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashToken(raw),
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}, nil
+}
+
+func (s *AuthService) issue(user db.User, rawRefresh string) (TokenPair, error) {
+	access, err := s.jwt.Generate(utils.Principal{
+		UserID: int(user.ID),
+		Email:  user.Email,
+		Role:   user.Role,
+		Plan:   user.Plan,
+		Scopes: s.scopes(user.Role),
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return TokenPair{AccessToken: access, ExpiresIn: s.jwt.TTL(), RefreshToken: rawRefresh}, nil
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	db "echotest/internal/models"
+	"echotest/internal/repository"
+	"echotest/pkg/jwks"
+	"echotest/pkg/utils"
+)
+
+type fakeUserStore struct {
+	users map[string]db.User
+}
+
+func (f *fakeUserStore) Create(_ context.Context, email, hash string) (db.User, error) {
+	if _, ok := f.users[email]; ok {
+		return db.User{}, repository.ErrDuplicate
+	}
+	u := db.User{ID: int64(len(f.users) + 1), Email: email, PasswordHash: hash}
+	f.users[email] = u
+	return u, nil
+}
+
+func (f *fakeUserStore) GetByEmail(_ context.Context, email string) (db.User, error) {
+	u, ok := f.users[email]
+	if !ok {
+		return db.User{}, repository.ErrNotFound
+	}
+	return u, nil
+}
+
+func (f *fakeUserStore) GetByID(_ context.Context, id int64) (db.User, error) {
+	for _, u := range f.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return db.User{}, repository.ErrNotFound
+}
+
+type fakeTokenStore struct {
+	refresh map[string]*db.RefreshToken // hash -> token
+	access  map[string]time.Time
+}
+
+func (f *fakeTokenStore) CreateRefresh(_ context.Context, arg db.CreateRefreshTokenParams) (db.RefreshToken, error) {
+	t := &db.RefreshToken{ID: int64(len(f.refresh) + 1), UserID: arg.UserID, FamilyID: arg.FamilyID, TokenHash: arg.TokenHash, ExpiresAt: arg.ExpiresAt}
+	f.refresh[arg.TokenHash] = t
+	return *t, nil
+}
+
+func (f *fakeTokenStore) GetRefreshByHash(_ context.Context, hash string) (db.RefreshToken, error) {
+	t, ok := f.refresh[hash]
+	if !ok {
+		return db.RefreshToken{}, repository.ErrNotFound
+	}
+	return *t, nil
+}
+
+func (f *fakeTokenStore) RotateRefresh(ctx context.Context, oldID int64, next db.CreateRefreshTokenParams) (db.RefreshToken, error) {
+	for _, t := range f.refresh {
+		if t.ID == oldID {
+			if t.RotatedAt.Valid || t.RevokedAt.Valid {
+				return db.RefreshToken{}, repository.ErrStale
+			}
+			t.RotatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+	}
+	return f.CreateRefresh(ctx, next)
+}
+
+func (f *fakeTokenStore) RevokeFamily(_ context.Context, familyID string) error {
+	for _, t := range f.refresh {
+		if t.FamilyID == familyID && !t.RevokedAt.Valid {
+			t.RevokedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		}
+	}
+	return nil
+}
+
+func (f *fakeTokenStore) RevokeAccess(_ context.Context, jti string, exp time.Time) error {
+	f.access[jti] = exp
+	return nil
+}
+
+func (f *fakeTokenStore) IsAccessRevoked(_ context.Context, jti string) (bool, error) {
+	_, ok := f.access[jti]
+	return ok, nil
+}
+
+func (f *fakeTokenStore) PurgeExpiredAccess(context.Context) error { return nil }
+
+func newTestAuth(t *testing.T) (*AuthService, *fakeTokenStore) {
+	t.Helper()
+	tokens := &fakeTokenStore{refresh: map[string]*db.RefreshToken{}, access: map[string]time.Time{}}
+	jwts := utils.NewJWT(jwks.NewHMAC([]byte("0123456789abcdef")), time.Minute)
+	svc := NewAuthService(&fakeUserStore{users: map[string]db.User{}}, tokens, jwts, time.Hour, func(string) []string { return nil })
+	if _, err := svc.Register(context.Background(), "User@Example.com", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	return svc, tokens
+}
+
+func TestAuthService_RefreshRotates(t *testing.T) {
+	svc, _ := newTestAuth(t)
+	ctx := context.Background()
+
+	pair, err := svc.Login(ctx, "user@example.com", "password123")
+	if err != nil {
+		t.Fatalf("登录失败: %v", err)
+	}
+	next, err := svc.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("刷新失败: %v", err)
+	}
+	if next.RefreshToken == pair.RefreshToken {
+		t.Error("刷新后应返回新的 refresh token")
+	}
+	if _, err := svc.Refresh(ctx, next.RefreshToken); err != nil {
+		t.Errorf("新 refresh token 应可继续使用: %v", err)
+	}
+}
+
+func TestAuthService_ReuseRevokesFamily(t *testing.T) {
+	svc, _ := newTestAuth(t)
+	ctx := context.Background()
+
+	pair, _ := svc.Login(ctx, "user@example.com", "password123")
+	next, err := svc.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 旧 token 被重放：检测到重用并吊销整个 family
+	if _, err := svc.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("期望 ErrRefreshTokenReused，得到 %v", err)
+	}
+	if _, err := svc.Refresh(ctx, next.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("family 被吊销后，最新的 token 也应失效，得到 %v", err)
+	}
+	// 其他登录会话不受影响
+	other, _ := svc.Login(ctx, "user@example.com", "password123")
+	if _, err := svc.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("其他 family 不应受影响: %v", err)
+	}
+}
+
+func TestAuthService_LogoutRevokesAccessToken(t *testing.T) {
+	svc, _ := newTestAuth(t)
+	ctx := context.Background()
+
+	pair, _ := svc.Login(ctx, "user@example.com", "password123")
+	if err := svc.Logout(ctx, 1, pair.RefreshToken, "jti-1", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _ := svc.IsRevoked(ctx, "jti-1"); !revoked {
+		t.Error("登出后 access token 应在吊销名单中")
+	}
+	if _, err := svc.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("登出后 refresh token 应失效，得到 %v", err)
+	}
+}
+
+func TestAuthService_RevokedCachePrunesExpired(t *testing.T) {
+	svc, _ := newTestAuth(t)
+	ctx := context.Background()
+
+	// 已过期的 jti 不会再被查询，只能在后续登出时清理
+	if err := svc.Logout(ctx, 1, "", "jti-expired", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Logout(ctx, 1, "", "jti-live", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := svc.revoked.Load("jti-expired"); ok {
+		t.Error("已过期的条目应在缓存新条目时被清理")
+	}
+	if _, ok := svc.revoked.Load("jti-live"); !ok {
+		t.Error("未过期的条目应保留")
+	}
+}
+
+func TestAuthService_LogoutIgnoresOtherUsersRefreshToken(t *testing.T) {
+	svc, _ := newTestAuth(t)
+	ctx := context.Background()
+
+	victim, _ := svc.Login(ctx, "user@example.com", "password123")
+	other, err := svc.Register(ctx, "other@example.com", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Logout(ctx, other.ID, victim.RefreshToken, "jti-2", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Refresh(ctx, victim.RefreshToken); err != nil {
+		t.Errorf("他人的 refresh token 不应被吊销: %v", err)
+	}
+	if revoked, _ := svc.IsRevoked(ctx, "jti-2"); !revoked {
+		t.Error("调用方自己的 access token 仍应被吊销")
+	}
+}
+
+func TestAuthService_LoginRejectsWrongPassword(t *testing.T) {
+	svc, _ := newTestAuth(t)
+	if _, err := svc.Login(context.Background(), "user@example.com", "wrong-password"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("期望 ErrInvalidCredentials，得到 %v", err)
+	}
+	if _, err := svc.Login(context.Background(), "nobody@example.com", "password123"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("未注册的邮箱期望 ErrInvalidCredentials，得到 %v", err)
+	}
+}
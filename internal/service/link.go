@@ -0,0 +1,167 @@
+// Package service 实现业务逻辑，处于 handler 与 repository 之间。
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	db "echotest/internal/models"
+	"echotest/internal/repository"
+	"echotest/pkg/shortcode"
+)
+
+var (
+	// ErrLinkNotFound 短链接不存在（或不属于当前用户）
+	ErrLinkNotFound = errors.New("link not found")
+	// ErrLinkExpired 短链接已过期
+	ErrLinkExpired = errors.New("link expired")
+	// ErrAliasTaken 自定义短码已被占用
+	ErrAliasTaken = errors.New("alias already taken")
+	// ErrCodeExhausted 多次生成的随机短码均发生冲突
+	ErrCodeExhausted = errors.New("failed to allocate a unique short code")
+)
+
+const (
+	// maxCodeAttempts 随机短码冲突时的最大重试次数
+	maxCodeAttempts = 5
+	// growCodeAfter 连续冲突达到该次数后加长短码，降低再次冲突的概率
+	growCodeAfter = 3
+)
+
+// LinkStore 短链接的存储接口，由 repository.LinkRepository 实现
+type LinkStore interface {
+	Create(ctx context.Context, arg db.CreateLinkParams) (db.Link, error)
+	GetByCode(ctx context.Context, code string) (db.Link, error)
+	GetOwned(ctx context.Context, code string, ownerID int64) (db.Link, error)
+	ListOwned(ctx context.Context, ownerID int64, limit, offset int32) ([]db.Link, error)
+	UpdateOwned(ctx context.Context, arg db.UpdateOwnerLinkParams) (db.Link, error)
+	DeleteOwned(ctx context.Context, code string, ownerID int64) error
+}
+
+// LinkService 短链接业务
+type LinkService struct {
+	store   LinkStore
+	codeLen int
+}
+
+// NewLinkService 创建 LinkService
+func NewLinkService(store LinkStore) *LinkService {
+	return &LinkService{store: store, codeLen: shortcode.DefaultLength}
+}
+
+// CreateLinkInput 创建短链接的参数；Alias 为空时自动生成短码
+type CreateLinkInput struct {
+	OwnerID   int64
+	TargetURL string
+	Alias     string
+	Permanent bool
+	ExpiresAt *time.Time
+}
+
+// Create 创建短链接。未指定 Alias 时生成随机短码，依赖数据库唯一约束检测冲突并重试。
+func (s *LinkService) Create(ctx context.Context, in CreateLinkInput) (db.Link, error) {
+	params := db.CreateLinkParams{
+		TargetUrl: in.TargetURL,
+		OwnerID:   in.OwnerID,
+		Permanent: in.Permanent,
+		ExpiresAt: nullTime(in.ExpiresAt),
+	}
+
+	if in.Alias != "" {
+		params.Code = in.Alias
+		link, err := s.store.Create(ctx, params)
+		if errors.Is(err, repository.ErrDuplicate) {
+			return db.Link{}, ErrAliasTaken
+		}
+		return link, err
+	}
+
+	length := s.codeLen
+	for attempt := 1; attempt <= maxCodeAttempts; attempt++ {
+		code, err := shortcode.Generate(length)
+		if err != nil {
+			return db.Link{}, err
+		}
+		params.Code = code
+		link, err := s.store.Create(ctx, params)
+		if !errors.Is(err, repository.ErrDuplicate) {
+			return link, err
+		}
+		if attempt >= growCodeAfter {
+			length++
+		}
+	}
+	return db.Link{}, ErrCodeExhausted
+}
+
+// Resolve 查询跳转目标，过期的短链接返回 ErrLinkExpired
+func (s *LinkService) Resolve(ctx context.Context, code string) (db.Link, error) {
+	link, err := s.store.GetByCode(ctx, code)
+	if err != nil {
+		return db.Link{}, mapNotFound(err)
+	}
+	if link.ExpiresAt.Valid && !link.ExpiresAt.Time.After(time.Now()) {
+		return db.Link{}, ErrLinkExpired
+	}
+	return link, nil
+}
+
+// Get 查询属于 ownerID 的短链接
+func (s *LinkService) Get(ctx context.Context, ownerID int64, code string) (db.Link, error) {
+	link, err := s.store.GetOwned(ctx, code, ownerID)
+	return link, mapNotFound(err)
+}
+
+// List 分页列出 ownerID 的短链接
+func (s *LinkService) List(ctx context.Context, ownerID int64, limit, offset int) ([]db.Link, error) {
+	links, err := s.store.ListOwned(ctx, ownerID, int32(limit), int32(offset))
+	if err != nil {
+		return nil, err
+	}
+	if links == nil {
+		links = []db.Link{}
+	}
+	return links, nil
+}
+
+// UpdateLinkInput 更新短链接的参数，整体替换目标地址、跳转类型与过期时间
+type UpdateLinkInput struct {
+	OwnerID   int64
+	Code      string
+	TargetURL string
+	Permanent bool
+	ExpiresAt *time.Time
+}
+
+// Update 更新属于 ownerID 的短链接
+func (s *LinkService) Update(ctx context.Context, in UpdateLinkInput) (db.Link, error) {
+	link, err := s.store.UpdateOwned(ctx, db.UpdateOwnerLinkParams{
+		Code:      in.Code,
+		OwnerID:   in.OwnerID,
+		TargetUrl: in.TargetURL,
+		Permanent: in.Permanent,
+		ExpiresAt: nullTime(in.ExpiresAt),
+	})
+	return link, mapNotFound(err)
+}
+
+// Delete 删除属于 ownerID 的短链接
+func (s *LinkService) Delete(ctx context.Context, ownerID int64, code string) error {
+	return mapNotFound(s.store.DeleteOwned(ctx, code, ownerID))
+}
+
+func mapNotFound(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrLinkNotFound
+	}
+	return err
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	db "echotest/internal/models"
+	"echotest/internal/repository"
+)
+
+// fakeLinkStore 内存实现，collide 指定前 N 次 Create 返回唯一约束冲突
+type fakeLinkStore struct {
+	LinkStore
+	collide int
+	codes   []string
+	links   map[string]db.Link
+}
+
+func (f *fakeLinkStore) Create(_ context.Context, arg db.CreateLinkParams) (db.Link, error) {
+	f.codes = append(f.codes, arg.Code)
+	if f.collide > 0 {
+		f.collide--
+		return db.Link{}, repository.ErrDuplicate
+	}
+	if _, ok := f.links[arg.Code]; ok {
+		return db.Link{}, repository.ErrDuplicate
+	}
+	link := db.Link{Code: arg.Code, TargetUrl: arg.TargetUrl, OwnerID: arg.OwnerID, ExpiresAt: arg.ExpiresAt}
+	f.links[arg.Code] = link
+	return link, nil
+}
+
+func (f *fakeLinkStore) GetByCode(_ context.Context, code string) (db.Link, error) {
+	link, ok := f.links[code]
+	if !ok {
+		return db.Link{}, repository.ErrNotFound
+	}
+	return link, nil
+}
+
+func newFakeStore(collide int) *fakeLinkStore {
+	return &fakeLinkStore{collide: collide, links: map[string]db.Link{}}
+}
+
+func TestLinkService_Create_RetriesOnCollision(t *testing.T) {
+	store := newFakeStore(3)
+	svc := NewLinkService(store)
+
+	link, err := svc.Create(context.Background(), CreateLinkInput{OwnerID: 1, TargetURL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("冲突后应重试成功: %v", err)
+	}
+	if len(store.codes) != 4 {
+		t.Fatalf("期望尝试 4 次，实际 %d 次", len(store.codes))
+	}
+	if len(link.Code) != 8 {
+		t.Errorf("连续冲突 %d 次后短码应加长到 8 位，得到 %q", growCodeAfter, link.Code)
+	}
+}
+
+func TestLinkService_Create_Exhausted(t *testing.T) {
+	svc := NewLinkService(newFakeStore(maxCodeAttempts))
+	_, err := svc.Create(context.Background(), CreateLinkInput{OwnerID: 1, TargetURL: "https://example.com"})
+	if !errors.Is(err, ErrCodeExhausted) {
+		t.Errorf("期望 ErrCodeExhausted，得到 %v", err)
+	}
+}
+
+func TestLinkService_Create_AliasTaken(t *testing.T) {
+	svc := NewLinkService(newFakeStore(0))
+	in := CreateLinkInput{OwnerID: 1, TargetURL: "https://example.com", Alias: "mylink"}
+	if _, err := svc.Create(context.Background(), in); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Create(context.Background(), in); !errors.Is(err, ErrAliasTaken) {
+		t.Errorf("重复别名期望 ErrAliasTaken，得到 %v", err)
+	}
+}
+
+func TestLinkService_Resolve(t *testing.T) {
+	store := newFakeStore(0)
+	store.links["live"] = db.Link{Code: "live", TargetUrl: "https://example.com"}
+	store.links["old"] = db.Link{Code: "old", ExpiresAt: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true}}
+	svc := NewLinkService(store)
+
+	if _, err := svc.Resolve(context.Background(), "live"); err != nil {
+		t.Errorf("live: %v", err)
+	}
+	if _, err := svc.Resolve(context.Background(), "old"); !errors.Is(err, ErrLinkExpired) {
+		t.Errorf("old: 期望 ErrLinkExpired，得到 %v", err)
+	}
+	if _, err := svc.Resolve(context.Background(), "none"); !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("none: 期望 ErrLinkNotFound，得到 %v", err)
+	}
+}
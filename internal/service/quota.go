@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"echotest/pkg/utils"
+)
+
+// UsageStore 用量计数存储，由 repository.UsageRepository 实现
+type UsageStore interface {
+	Increment(ctx context.Context, subject, quota string, periodStart time.Time, limit int64) (int64, bool, error)
+	Decrement(ctx context.Context, subject, quota string, periodStart time.Time) error
+	Get(ctx context.Context, subject, quota string, periodStart time.Time) (int64, error)
+}
+
+// QuotaService 按自然日、自然月（UTC）计数的用量配额，实现 utils.QuotaCounter。
+// 计数以 (对象, 配额, 周期开始时间) 为键保存，新周期自动从 0 开始，不需要定时清零。
+type QuotaService struct {
+	store UsageStore
+	now   func() time.Time
+}
+
+// NewQuotaService 创建 QuotaService
+func NewQuotaService(store UsageStore) *QuotaService {
+	return &QuotaService{store: store, now: time.Now}
+}
+
+// Consume 实现 utils.QuotaCounter：用量未达到硬上限时原子地加一
+func (s *QuotaService) Consume(ctx context.Context, q utils.Quota) (utils.QuotaUsage, bool, error) {
+	u, err := s.usage(q)
+	if err != nil {
+		return utils.QuotaUsage{}, false, err
+	}
+	if q.Limit > 0 {
+		used, ok, err := s.store.Increment(ctx, q.Subject, q.Name, u.PeriodStart, q.Limit)
+		if err != nil || ok {
+			u.Used = used
+			return u, ok, err
+		}
+	}
+	// 已达到上限：读出当前用量用于错误提示（上限调低后用量可能大于上限）
+	u.Used, err = s.store.Get(ctx, q.Subject, q.Name, u.PeriodStart)
+	return u, false, err
+}
+
+// Refund 实现 utils.QuotaCounter：撤销一次计数
+func (s *QuotaService) Refund(ctx context.Context, u utils.QuotaUsage) error {
+	return s.store.Decrement(ctx, u.Subject, u.Name, u.PeriodStart)
+}
+
+// Usage 返回各项配额在当前周期内的用量
+func (s *QuotaService) Usage(ctx context.Context, quotas []utils.Quota) ([]utils.QuotaUsage, error) {
+	items := make([]utils.QuotaUsage, 0, len(quotas))
+	for _, q := range quotas {
+		u, err := s.usage(q)
+		if err != nil {
+			return nil, err
+		}
+		if u.Used, err = s.store.Get(ctx, q.Subject, q.Name, u.PeriodStart); err != nil {
+			return nil, err
+		}
+		items = append(items, u)
+	}
+	return items, nil
+}
+
+// usage 计算 q 当前所在的周期
+func (s *QuotaService) usage(q utils.Quota) (utils.QuotaUsage, error) {
+	start, reset, err := periodBounds(q.Period, s.now())
+	if err != nil {
+		return utils.QuotaUsage{}, err
+	}
+	return utils.QuotaUsage{Quota: q, PeriodStart: start, Reset: reset}, nil
+}
+
+// periodBounds 返回 now 所在的 UTC 自然日或自然月的起止时间
+func periodBounds(period string, now time.Time) (start, end time.Time, err error) {
+	now = now.UTC()
+	switch period {
+	case "day":
+		start = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 0, 1), nil
+	case "month":
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0), nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("unknown quota period %q", period)
+	}
+}
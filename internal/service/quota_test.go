@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"echotest/pkg/utils"
+)
+
+// fakeUsageStore 内存计数，键为 subject/quota/周期开始时间
+type fakeUsageStore struct {
+	used map[string]int64
+}
+
+func usageKey(subject, quota string, start time.Time) string {
+	return subject + "/" + quota + "/" + start.Format(time.RFC3339)
+}
+
+func (f *fakeUsageStore) Increment(_ context.Context, subject, quota string, start time.Time, limit int64) (int64, bool, error) {
+	k := usageKey(subject, quota, start)
+	if f.used[k] >= limit {
+		return 0, false, nil
+	}
+	f.used[k]++
+	return f.used[k], true, nil
+}
+
+func (f *fakeUsageStore) Decrement(_ context.Context, subject, quota string, start time.Time) error {
+	if k := usageKey(subject, quota, start); f.used[k] > 0 {
+		f.used[k]--
+	}
+	return nil
+}
+
+func (f *fakeUsageStore) Get(_ context.Context, subject, quota string, start time.Time) (int64, error) {
+	return f.used[usageKey(subject, quota, start)], nil
+}
+
+func TestPeriodBounds(t *testing.T) {
+	now := time.Date(2026, 12, 31, 23, 30, 0, 0, time.FixedZone("CST", 8*3600)) // UTC 15:30
+	start, end, err := periodBounds("day", now)
+	if err != nil || !start.Equal(time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("day: %v %v %v", start, end, err)
+	}
+	start, end, err = periodBounds("month", now)
+	if err != nil || !start.Equal(time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC)) || !end.Equal(time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("month: %v %v %v", start, end, err)
+	}
+	if _, _, err := periodBounds("week", now); err == nil {
+		t.Error("未知周期应返回错误")
+	}
+}
+
+func TestQuotaService_ConsumeAndReset(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	svc := NewQuotaService(&fakeUsageStore{used: map[string]int64{}})
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+	q := utils.Quota{Name: "api_calls", Period: "day", Subject: "user:1", Limit: 2}
+
+	for i := 1; i <= 2; i++ {
+		u, ok, err := svc.Consume(ctx, q)
+		if err != nil || !ok || u.Used != int64(i) {
+			t.Fatalf("第 %d 次: 期望允许且用量为 %d，得到 %v %+v %v", i, i, ok, u, err)
+		}
+	}
+	u, ok, err := svc.Consume(ctx, q)
+	if err != nil || ok || u.Used != 2 || !u.Reset.Equal(time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("超出上限应拒绝并给出重置时间，得到 %v %+v %v", ok, u, err)
+	}
+
+	if err := svc.Refund(ctx, u); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := svc.Consume(ctx, q); !ok {
+		t.Error("撤销一次后应再允许一次")
+	}
+
+	// 进入新的一天后重新计数
+	now = now.Add(24 * time.Hour)
+	usage, err := svc.Usage(ctx, []utils.Quota{q})
+	if err != nil || len(usage) != 1 || usage[0].Used != 0 {
+		t.Errorf("新周期用量应为 0，得到 %+v %v", usage, err)
+	}
+
+	// 上限为 0 表示不允许使用
+	if _, ok, _ := svc.Consume(ctx, utils.Quota{Name: "x", Period: "month", Subject: "user:1"}); ok {
+		t.Error("上限为 0 时应拒绝")
+	}
+}
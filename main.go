@@ -1,33 +1,109 @@
 package main
 
 import (
+	"context"
+	"echotest/config"
+	"echotest/database"
 	"echotest/internal/app"
+	"echotest/pkg/utils"
 	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"text/tabwriter"
 
 	"github.com/alecthomas/kingpin/v2"
 )
 
 var (
 	configpath = kingpin.Flag("config", "config file path").Short('f').String()
+
+	serveCmd = kingpin.Command("serve", "start http server").Default()
+
+	migrateCmd       = kingpin.Command("migrate", "run embedded database migrations")
+	migrateUpCmd     = migrateCmd.Command("up", "apply all pending migrations")
+	migrateDownCmd   = migrateCmd.Command("down", "roll back the latest migrations")
+	migrateDownSteps = migrateDownCmd.Arg("steps", "number of migrations to roll back").Default("1").Int()
+	migrateStatusCmd = migrateCmd.Command("status", "show migration status")
+	migrateGotoCmd   = migrateCmd.Command("goto", "migrate up or down to the given version")
+	migrateGotoVer   = migrateGotoCmd.Arg("version", "target version, 0 rolls back everything").Required().Uint64()
+
+	configCmd      = kingpin.Command("config", "config file tools")
+	configCheckCmd = configCmd.Command("check", "validate config file without starting the server")
+
+	openapiCmd     = kingpin.Command("openapi", "OpenAPI document tools")
+	openapiDumpCmd = openapiCmd.Command("dump", "print the OpenAPI 3.1 document generated from registered routes")
+	openapiOutput  = openapiDumpCmd.Flag("output", "write to file instead of stdout").Short('o').String()
 )
 
 func main() {
 	kingpin.Version("0.0.0")
-	kingpin.Parse()
+	cmd := kingpin.Parse()
 
-	configPath := *configpath
-	if configPath == "" {
-		log.Println("use default config.yaml")
-		exePath, err := os.Executable()
-		if err != nil {
-			panic(fmt.Sprintf("获取程序路径失败: %v", err))
+	configPath := resolveConfigPath(*configpath)
+
+	switch cmd {
+	case serveCmd.FullCommand():
+		serve(configPath)
+	case migrateUpCmd.FullCommand(), migrateDownCmd.FullCommand(),
+		migrateStatusCmd.FullCommand(), migrateGotoCmd.FullCommand():
+		if err := migrate(configPath, cmd); err != nil {
+			log.Fatalf("migrate failed: %v", err)
 		}
-		configPath = filepath.Join(filepath.Dir(exePath), "config", "config.yaml")
+	case configCheckCmd.FullCommand():
+		checkConfig(configPath)
+	case openapiDumpCmd.FullCommand():
+		if err := dumpOpenAPI(configPath, *openapiOutput); err != nil {
+			log.Fatalf("openapi dump failed: %v", err)
+		}
+	}
+}
+
+// dumpOpenAPI 离线输出 OpenAPI 文档，与运行中服务的 /openapi.json 内容相同，可用于生成客户端或在 CI 中比对
+func dumpOpenAPI(configPath, output string) error {
+	b, err := app.OpenAPI(configPath)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(output, b, 0644)
+}
+
+// checkConfig 离线校验配置文件：不连接数据库、不启动服务，失败时列出全部错误并以非 0 退出。
+// 同时会加载 jwt.keys 引用的 PEM 文件，确认密钥可用。
+func checkConfig(configPath string) {
+	cfg, err := config.NewConfig(configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if _, err := utils.NewKeySet(*cfg.JWT, filepath.Dir(configPath)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println("配置校验通过:", configPath)
+}
+
+// resolveConfigPath 未指定 -f 时使用程序所在目录下的 config/config.yaml
+func resolveConfigPath(configPath string) string {
+	if configPath != "" {
+		return configPath
+	}
+	log.Println("use default config.yaml")
+	exePath, err := os.Executable()
+	if err != nil {
+		panic(fmt.Sprintf("获取程序路径失败: %v", err))
 	}
+	return filepath.Join(filepath.Dir(exePath), "config", "config.yaml")
+}
 
+func serve(configPath string) {
 	a, err := app.InitApp(configPath)
 	if err != nil {
 		panic(err)
@@ -38,3 +114,60 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// migrate 只加载配置并连接数据库，不启动 HTTP 服务与中间件
+func migrate(configPath, cmd string) error {
+	cfg, err := config.NewConfig(configPath)
+	if err != nil {
+		return err
+	}
+	if cfg.Database == nil {
+		return fmt.Errorf("配置文件缺少 database 配置")
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	logger := slog.Default()
+	db, err := database.NewDB(ctx, *cfg.Database, logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m, err := database.NewMigrator(db, logger)
+	if err != nil {
+		return err
+	}
+
+	switch cmd {
+	case migrateUpCmd.FullCommand():
+		return m.Up(ctx)
+	case migrateDownCmd.FullCommand():
+		return m.Down(ctx, *migrateDownSteps)
+	case migrateGotoCmd.FullCommand():
+		return m.Goto(ctx, *migrateGotoVer)
+	case migrateStatusCmd.FullCommand():
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range statuses {
+			state, at := "pending", ""
+			if s.Applied {
+				state, at = "applied", s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Missing {
+				state = "missing file"
+			}
+			if s.Modified {
+				state = "modified"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, at)
+		}
+		return w.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+// Package jwks 管理 JWT 签名密钥集合：按 kid 选择校验密钥，并以 JWK Set（RFC 7517）格式发布公钥。
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrUnknownKey token 头中的 kid 不在密钥集合中
+var ErrUnknownKey = errors.New("jwks: unknown key id")
+
+// Spec 一把密钥的配置，PrivateKeyFile 与 PublicKeyFile 至少提供一个
+type Spec struct {
+	ID             string
+	Algorithm      string // RS256、ES256 或 EdDSA
+	PrivateKeyFile string
+	PublicKeyFile  string
+}
+
+// Key 密钥集合中的一把密钥；只用于校验的密钥 private 为 nil
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+// Set JWT 密钥集合：一把签发密钥加若干校验密钥，创建后只读，可并发使用
+type Set struct {
+	signing *Key
+	keys    map[string]*Key
+	methods []string
+}
+
+// NewHMAC 创建只含一把 HS256 密钥的集合，签发的 token 不带 kid，兼容旧配置
+func NewHMAC(secret []byte) *Set {
+	k := &Key{Method: jwt.SigningMethodHS256, private: secret, public: secret}
+	return &Set{signing: k, keys: map[string]*Key{"": k}, methods: []string{k.Method.Alg()}}
+}
+
+// Load 从 PEM 文件加载密钥集合，signingID 为签发密钥的 kid
+func Load(specs []Spec, signingID string) (*Set, error) {
+	s := &Set{keys: make(map[string]*Key, len(specs))}
+	seen := map[string]bool{}
+	for _, spec := range specs {
+		k, err := loadKey(spec)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: load key %q: %w", spec.ID, err)
+		}
+		if _, dup := s.keys[k.ID]; dup {
+			return nil, fmt.Errorf("jwks: duplicate key id %q", k.ID)
+		}
+		s.keys[k.ID] = k
+		if alg := k.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			s.methods = append(s.methods, alg)
+		}
+		if k.ID == signingID {
+			s.signing = k
+		}
+	}
+	if s.signing == nil {
+		return nil, fmt.Errorf("jwks: signing key %q not found", signingID)
+	}
+	if s.signing.private == nil {
+		return nil, fmt.Errorf("jwks: signing key %q has no private key", signingID)
+	}
+	return s, nil
+}
+
+// Sign 使用签发密钥签名，并在头中写入 kid
+func (s *Set) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.Method, claims)
+	if s.signing.ID != "" {
+		token.Header["kid"] = s.signing.ID
+	}
+	return token.SignedString(s.signing.private)
+}
+
+// Keyfunc 实现 jwt.Keyfunc：按 kid 选择校验密钥，并要求 token 的算法与密钥一致，防止算法混淆。
+// 没有 kid 的 token 使用签发密钥校验。
+func (s *Set) Keyfunc(t *jwt.Token) (any, error) {
+	kid, _ := t.Header["kid"].(string)
+	k, ok := s.keys[kid]
+	if !ok {
+		if kid != "" {
+			return nil, fmt.Errorf("%w %q", ErrUnknownKey, kid)
+		}
+		k = s.signing
+	}
+	if t.Method.Alg() != k.Method.Alg() {
+		return nil, fmt.Errorf("jwks: key %q does not accept %s", k.ID, t.Method.Alg())
+	}
+	return k.public, nil
+}
+
+// Methods 返回集合中用到的全部算法，用于 jwt.WithValidMethods
+func (s *Set) Methods() []string {
+	return s.methods
+}
+
+// JWK 一把公钥的 JSON Web Key 表示
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	Crv string `json:"crv,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKSet /.well-known/jwks.json 的响应体
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 返回全部非对称公钥；HMAC 密钥不会被发布
+func (s *Set) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range s.keys {
+		if jwk, ok := toJWK(k); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+func toJWK(k *Key) (JWK, bool) {
+	jwk := JWK{Use: "sig", Alg: k.Method.Alg(), Kid: k.ID}
+	switch pub := k.public.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = b64(pub.N.Bytes())
+		jwk.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = b64(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = b64(pub)
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func loadKey(spec Spec) (*Key, error) {
+	if spec.ID == "" {
+		return nil, errors.New("empty key id")
+	}
+	k := &Key{ID: spec.ID}
+	var err error
+	switch spec.Algorithm {
+	case "RS256":
+		k.Method = jwt.SigningMethodRS256
+		err = k.loadPEM(spec, rsaParser)
+	case "ES256":
+		k.Method = jwt.SigningMethodES256
+		err = k.loadPEM(spec, ecParser)
+	case "EdDSA":
+		k.Method = jwt.SigningMethodEdDSA
+		err = k.loadPEM(spec, edParser)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", spec.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// parser 某一算法的 PEM 解析函数；pub 为私钥推导公钥
+type parser struct {
+	private func([]byte) (crypto.PrivateKey, error)
+	public  func([]byte) (crypto.PublicKey, error)
+	pub     func(crypto.PrivateKey) crypto.PublicKey
+}
+
+var (
+	rsaParser = parser{
+		private: func(b []byte) (crypto.PrivateKey, error) { return jwt.ParseRSAPrivateKeyFromPEM(b) },
+		public:  func(b []byte) (crypto.PublicKey, error) { return jwt.ParseRSAPublicKeyFromPEM(b) },
+		pub:     func(k crypto.PrivateKey) crypto.PublicKey { return &k.(*rsa.PrivateKey).PublicKey },
+	}
+	ecParser = parser{
+		private: func(b []byte) (crypto.PrivateKey, error) {
+			k, err := jwt.ParseECPrivateKeyFromPEM(b)
+			if err == nil && k.Curve != elliptic.P256() {
+				return nil, errors.New("ES256 requires a P-256 key")
+			}
+			return k, err
+		},
+		public: func(b []byte) (crypto.PublicKey, error) {
+			k, err := jwt.ParseECPublicKeyFromPEM(b)
+			if err == nil && k.Curve != elliptic.P256() {
+				return nil, errors.New("ES256 requires a P-256 key")
+			}
+			return k, err
+		},
+		pub: func(k crypto.PrivateKey) crypto.PublicKey { return &k.(*ecdsa.PrivateKey).PublicKey },
+	}
+	edParser = parser{
+		private: jwt.ParseEdPrivateKeyFromPEM,
+		public:  jwt.ParseEdPublicKeyFromPEM,
+		pub:     func(k crypto.PrivateKey) crypto.PublicKey { return k.(ed25519.PrivateKey).Public() },
+	}
+)
+
+func (k *Key) loadPEM(spec Spec, p parser) error {
+	if spec.PrivateKeyFile != "" {
+		b, err := os.ReadFile(spec.PrivateKeyFile)
+		if err != nil {
+			return err
+		}
+		if k.private, err = p.private(b); err != nil {
+			return fmt.Errorf("parse %s: %w", spec.PrivateKeyFile, err)
+		}
+		k.public = p.pub(k.private)
+	}
+	if spec.PublicKeyFile != "" {
+		b, err := os.ReadFile(spec.PublicKeyFile)
+		if err != nil {
+			return err
+		}
+		pub, err := p.public(b)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", spec.PublicKeyFile, err)
+		}
+		if k.public != nil && !k.public.(interface{ Equal(crypto.PublicKey) bool }).Equal(pub) {
+			return errors.New("public key does not match private key")
+		}
+		k.public = pub
+	}
+	if k.public == nil {
+		return errors.New("neither private_key_file nor public_key_file is set")
+	}
+	return nil
+}
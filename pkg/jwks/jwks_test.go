@@ -0,0 +1,183 @@
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// writeKey 生成密钥对，把私钥（PKCS#8）与公钥（PKIX）写入临时目录
+func writeKey(t *testing.T, dir, kid string, priv crypto.Signer) (privFile, pubFile string) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		t.Fatal(err)
+	}
+	privFile = filepath.Join(dir, kid+".pem")
+	pubFile = filepath.Join(dir, kid+".pub.pem")
+	if err := os.WriteFile(privFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pubFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return privFile, pubFile
+}
+
+func newSigners(t *testing.T) map[string]crypto.Signer {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]crypto.Signer{"RS256": rsaKey, "ES256": ecKey, "EdDSA": edKey}
+}
+
+func TestSet_SignAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	for alg, signer := range newSigners(t) {
+		t.Run(alg, func(t *testing.T) {
+			privFile, _ := writeKey(t, dir, alg, signer)
+			set, err := Load([]Spec{{ID: alg, Algorithm: alg, PrivateKeyFile: privFile}}, alg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			raw, err := set.Sign(jwt.RegisteredClaims{Subject: "1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := jwt.Parse(raw, set.Keyfunc, jwt.WithValidMethods(set.Methods()))
+			if err != nil {
+				t.Fatalf("校验失败: %v", err)
+			}
+			if token.Header["kid"] != alg {
+				t.Errorf("kid 期望 %q，得到 %v", alg, token.Header["kid"])
+			}
+		})
+	}
+}
+
+// 轮换：旧密钥只保留公钥，旧 token 仍可校验，新 token 使用新 kid
+func TestSet_RotationOverlap(t *testing.T) {
+	dir := t.TempDir()
+	signers := newSigners(t)
+	oldPriv, oldPub := writeKey(t, dir, "2026-04", signers["ES256"])
+	newPriv, _ := writeKey(t, dir, "2026-10", signers["EdDSA"])
+
+	before, err := Load([]Spec{{ID: "2026-04", Algorithm: "ES256", PrivateKeyFile: oldPriv}}, "2026-04")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, _ := before.Sign(jwt.RegisteredClaims{Subject: "1"})
+
+	after, err := Load([]Spec{
+		{ID: "2026-04", Algorithm: "ES256", PublicKeyFile: oldPub},
+		{ID: "2026-10", Algorithm: "EdDSA", PrivateKeyFile: newPriv},
+	}, "2026-10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(oldToken, after.Keyfunc, jwt.WithValidMethods(after.Methods())); err != nil {
+		t.Errorf("重叠期内旧 token 应可校验: %v", err)
+	}
+	newToken, _ := after.Sign(jwt.RegisteredClaims{Subject: "1"})
+	parsed, err := jwt.Parse(newToken, after.Keyfunc, jwt.WithValidMethods(after.Methods()))
+	if err != nil || parsed.Header["kid"] != "2026-10" {
+		t.Errorf("新 token 应使用新 kid，得到 %v, %v", parsed.Header["kid"], err)
+	}
+
+	if got := len(after.JWKS().Keys); got != 2 {
+		t.Errorf("JWKS 应发布 2 把公钥，得到 %d", got)
+	}
+}
+
+func TestSet_RejectsUnknownKidAndAlgConfusion(t *testing.T) {
+	dir := t.TempDir()
+	signer := newSigners(t)["RS256"]
+	privFile, _ := writeKey(t, dir, "rsa", signer)
+	set, err := Load([]Spec{{ID: "rsa", Algorithm: "RS256", PrivateKeyFile: privFile}}, "rsa")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{})
+	unknown.Header["kid"] = "other"
+	raw, _ := unknown.SignedString(signer)
+	if _, err := jwt.Parse(raw, set.Keyfunc); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("未知 kid 应返回 ErrUnknownKey，得到 %v", err)
+	}
+
+	// 以公钥内容作为 HMAC 密钥伪造的 token 必须被拒绝
+	pubDER, _ := x509.MarshalPKIXPublicKey(signer.Public())
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{})
+	forged.Header["kid"] = "rsa"
+	raw, _ = forged.SignedString(pubDER)
+	if _, err := jwt.Parse(raw, set.Keyfunc, jwt.WithValidMethods(set.Methods())); err == nil {
+		t.Error("算法与密钥不匹配的 token 应被拒绝")
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	dir := t.TempDir()
+	signers := newSigners(t)
+	_, rsaPub := writeKey(t, dir, "rsa", signers["RS256"])
+	ecPriv, _ := writeKey(t, dir, "ec", signers["ES256"])
+	otherEC, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, otherPub := writeKey(t, dir, "ec-other", otherEC)
+
+	cases := map[string]struct {
+		specs   []Spec
+		signing string
+	}{
+		"签发密钥只有公钥":  {[]Spec{{ID: "a", Algorithm: "RS256", PublicKeyFile: rsaPub}}, "a"},
+		"签发密钥不存在":   {[]Spec{{ID: "a", Algorithm: "ES256", PrivateKeyFile: ecPriv}}, "b"},
+		"算法与密钥类型不符": {[]Spec{{ID: "a", Algorithm: "RS256", PrivateKeyFile: ecPriv}}, "a"},
+		"公私钥不匹配":    {[]Spec{{ID: "a", Algorithm: "ES256", PrivateKeyFile: ecPriv, PublicKeyFile: otherPub}}, "a"},
+		"kid 重复": {[]Spec{
+			{ID: "a", Algorithm: "ES256", PrivateKeyFile: ecPriv},
+			{ID: "a", Algorithm: "ES256", PrivateKeyFile: ecPriv},
+		}, "a"},
+	}
+	for name, tc := range cases {
+		if _, err := Load(tc.specs, tc.signing); err == nil {
+			t.Errorf("%s: 期望返回错误", name)
+		}
+	}
+}
+
+func TestNewHMAC_NotPublished(t *testing.T) {
+	set := NewHMAC([]byte("0123456789abcdef"))
+	raw, err := set.Sign(jwt.RegisteredClaims{Subject: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := jwt.Parse(raw, set.Keyfunc, jwt.WithValidMethods(set.Methods())); err != nil {
+		t.Errorf("HS256 token 应可校验: %v", err)
+	}
+	if keys := set.JWKS().Keys; len(keys) != 0 {
+		t.Errorf("对称密钥不应出现在 JWKS 中: %v", keys)
+	}
+}
@@ -0,0 +1,325 @@
+// Package loadshed 提供自适应并发限制与过载保护：根据观测到的延迟动态调整允许同时处理的请求数，
+// 超出时请求按优先级短暂排队，仍无空位则返回 503，保证健康检查等关键请求最后才被丢弃。
+package loadshed
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"echotest/pkg/problem"
+
+	"github.com/labstack/echo/v5"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Config 各字段的默认值
+const (
+	DefaultInitialLimit = 100
+	DefaultMinLimit     = 10
+	DefaultMaxLimit     = 1000
+	DefaultTolerance    = 1.5
+	DefaultQueueTimeout = 50 * time.Millisecond
+	DefaultMaxQueue     = 100
+	DefaultReserve      = 0.1
+)
+
+const (
+	// shortWindow、longWindow 短期、长期延迟均值的样本窗口
+	shortWindow = 10
+	longWindow  = 600
+	// smoothing 每个样本对并发上限的调整幅度
+	smoothing = 0.2
+)
+
+// ErrOverloaded 排队已满或排队超时，请求被丢弃
+var ErrOverloaded = errors.New("loadshed: server overloaded")
+
+// Priority 请求优先级，数值越大越晚被丢弃
+type Priority int
+
+const (
+	// Normal 普通 API 请求，最先被丢弃
+	Normal Priority = iota
+	// High 如短链接跳转
+	High
+	// Critical 如健康检查，最后被丢弃
+	Critical
+
+	numPriorities = 3
+)
+
+func (p Priority) String() string {
+	switch p {
+	case Critical:
+		return "critical"
+	case High:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// Config 自适应并发限制参数，零值使用默认值
+type Config struct {
+	// InitialLimit 初始并发上限
+	InitialLimit int
+	// MinLimit、MaxLimit 并发上限的调整范围
+	MinLimit int
+	MaxLimit int
+	// Tolerance 短期延迟超过长期延迟的多少倍时开始收缩上限，默认 1.5
+	Tolerance float64
+	// QueueTimeout 没有空位时最多排队等待多久，超时返回 503
+	QueueTimeout time.Duration
+	// MaxQueue 每个优先级最多排队的请求数，排满后新请求直接返回 503
+	MaxQueue int
+	// Reserve 为高优先级保留的比例：Normal 最多占用上限的 1-2*Reserve，High 最多 1-Reserve，Critical 可占满
+	Reserve float64
+}
+
+// waiter 排队中的请求
+type waiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// Limiter 按梯度算法（参考 Netflix concurrency-limits 的 Gradient2）自适应调整的并发限制器。
+// 每个请求结束后用其处理耗时更新短期、长期延迟均值：短期延迟明显升高说明开始排队，按比例收缩上限；
+// 否则上限以 sqrt(limit) 的步长缓慢增长，直到延迟开始升高。
+type Limiter struct {
+	mu       sync.Mutex
+	cfg      Config
+	limit    float64
+	inflight int
+	shortRTT float64 // 秒
+	longRTT  float64
+	queues   [numPriorities]*list.List
+	rejected [numPriorities]uint64
+	// canceled 排队期间调用方取消（如客户端断开），不计入 rejected
+	canceled [numPriorities]uint64
+
+	now func() time.Time
+}
+
+// New 根据配置创建限制器
+func New(cfg Config) *Limiter {
+	if cfg.MinLimit <= 0 {
+		cfg.MinLimit = DefaultMinLimit
+	}
+	if cfg.MaxLimit <= 0 {
+		cfg.MaxLimit = max(DefaultMaxLimit, cfg.MinLimit)
+	}
+	if cfg.InitialLimit <= 0 {
+		cfg.InitialLimit = DefaultInitialLimit
+	}
+	cfg.InitialLimit = min(max(cfg.InitialLimit, cfg.MinLimit), cfg.MaxLimit)
+	if cfg.Tolerance < 1 {
+		cfg.Tolerance = DefaultTolerance
+	}
+	if cfg.QueueTimeout <= 0 {
+		cfg.QueueTimeout = DefaultQueueTimeout
+	}
+	if cfg.MaxQueue <= 0 {
+		cfg.MaxQueue = DefaultMaxQueue
+	}
+	if cfg.Reserve <= 0 || cfg.Reserve >= 0.5 {
+		cfg.Reserve = DefaultReserve
+	}
+	l := &Limiter{cfg: cfg, limit: float64(cfg.InitialLimit), now: time.Now}
+	for i := range l.queues {
+		l.queues[i] = list.New()
+	}
+	return l
+}
+
+// Limit 返回当前并发上限
+func (l *Limiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// Inflight 返回正在处理的请求数
+func (l *Limiter) Inflight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
+
+// capacity 优先级 p 最多可占用的并发数
+func (l *Limiter) capacity(p Priority) int {
+	share := 1 - float64(Critical-p)*l.cfg.Reserve
+	return max(int(l.limit*share), 1)
+}
+
+// admittable 优先级 p 的新请求可以直接处理：没有同级或更高优先级在排队，且未超出该优先级可占用的并发数
+func (l *Limiter) admittable(p Priority) bool {
+	for q := p; q < numPriorities; q++ {
+		if l.queues[q].Len() > 0 {
+			return false
+		}
+	}
+	return l.inflight < l.capacity(p)
+}
+
+// Acquire 为优先级 p 的请求申请一个并发名额，没有空位时最多排队 QueueTimeout；
+// 成功时返回 release，请求处理完后必须调用一次，其耗时用于调整并发上限。
+// 排队期间 ctx 被取消时返回 ctx.Err()，不算作丢弃
+func (l *Limiter) Acquire(ctx context.Context, p Priority) (release func(), err error) {
+	p = min(max(p, Normal), Critical)
+	l.mu.Lock()
+	if l.admittable(p) {
+		l.inflight++
+		l.mu.Unlock()
+		return l.releaser(), nil
+	}
+	q := l.queues[p]
+	if q.Len() >= l.cfg.MaxQueue {
+		l.rejected[p]++
+		l.mu.Unlock()
+		return nil, ErrOverloaded
+	}
+	w := &waiter{ready: make(chan struct{})}
+	e := q.PushBack(w)
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.cfg.QueueTimeout)
+	defer timer.Stop()
+	err = ErrOverloaded
+	select {
+	case <-w.ready:
+		return l.releaser(), nil
+	case <-timer.C:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if w.granted { // 超时的同时刚好轮到
+		return l.releaser(), nil
+	}
+	q.Remove(e)
+	if err == ErrOverloaded {
+		l.rejected[p]++
+	} else {
+		l.canceled[p]++
+	}
+	return nil, err
+}
+
+func (l *Limiter) releaser() func() {
+	start := l.now()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			rtt := l.now().Sub(start)
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			l.update(rtt, l.inflight)
+			l.inflight--
+			l.dispatch()
+		})
+	}
+}
+
+// update 用一个样本更新延迟均值与并发上限，inflight 为样本结束时的并发数
+func (l *Limiter) update(rtt time.Duration, inflight int) {
+	r := max(rtt.Seconds(), 1e-6)
+	if l.longRTT == 0 {
+		l.shortRTT, l.longRTT = r, r
+	}
+	l.shortRTT = ewma(l.shortRTT, r, shortWindow)
+	l.longRTT = ewma(l.longRTT, r, longWindow)
+	// 负载回落后长期均值远高于短期均值，加速回落，避免之后长时间无法察觉延迟升高
+	if l.longRTT/l.shortRTT > 2 {
+		l.longRTT *= 0.95
+	}
+	// 并发远未用满时延迟不反映容量，不调整
+	if float64(inflight) < l.limit/2 {
+		return
+	}
+	gradient := min(max(l.cfg.Tolerance*l.longRTT/l.shortRTT, 0.5), 1)
+	next := l.limit*gradient + math.Sqrt(l.limit)
+	l.limit = l.limit*(1-smoothing) + next*smoothing
+	l.limit = min(max(l.limit, float64(l.cfg.MinLimit)), float64(l.cfg.MaxLimit))
+}
+
+// dispatch 按优先级从高到低放行排队的请求
+func (l *Limiter) dispatch() {
+	for p := Critical; p >= Normal; p-- {
+		q := l.queues[p]
+		for q.Len() > 0 && l.inflight < l.capacity(p) {
+			w := q.Remove(q.Front()).(*waiter)
+			w.granted = true
+			l.inflight++
+			close(w.ready)
+		}
+	}
+}
+
+func ewma(avg, sample float64, window int) float64 {
+	alpha := 2 / float64(window+1)
+	return avg*(1-alpha) + sample*alpha
+}
+
+// Middleware 返回 Echo 中间件，classify 决定请求的优先级；被丢弃的请求返回 503 与 Retry-After
+func (l *Limiter) Middleware(classify func(*echo.Context) Priority) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			release, err := l.Acquire(c.Request().Context(), classify(c))
+			if err != nil {
+				c.Response().Header().Set("Retry-After", "1")
+				return problem.New(http.StatusServiceUnavailable, "server overloaded")
+			}
+			defer release()
+			return next(c)
+		}
+	}
+}
+
+var (
+	limitDesc    = prometheus.NewDesc("loadshed_limit", "Current adaptive concurrency limit.", nil, nil)
+	inflightDesc = prometheus.NewDesc("loadshed_inflight", "Requests currently being handled.", nil, nil)
+	queuedDesc   = prometheus.NewDesc("loadshed_queued", "Requests waiting for a concurrency slot.", []string{"priority"}, nil)
+	rejectedDesc = prometheus.NewDesc("loadshed_rejected_total", "Requests shed with 503.", []string{"priority"}, nil)
+	canceledDesc = prometheus.NewDesc("loadshed_canceled_total", "Requests canceled by the caller while queued.", []string{"priority"}, nil)
+	rttDesc      = prometheus.NewDesc("loadshed_latency_seconds", "Smoothed request latency used to adjust the limit.", []string{"window"}, nil)
+)
+
+// Collector 返回导出限制器状态的 Prometheus collector
+func (l *Limiter) Collector() prometheus.Collector {
+	return collector{l}
+}
+
+type collector struct {
+	l *Limiter
+}
+
+func (c collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- limitDesc
+	ch <- inflightDesc
+	ch <- queuedDesc
+	ch <- rejectedDesc
+	ch <- canceledDesc
+	ch <- rttDesc
+}
+
+func (c collector) Collect(ch chan<- prometheus.Metric) {
+	l := c.l
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ch <- prometheus.MustNewConstMetric(limitDesc, prometheus.GaugeValue, l.limit)
+	ch <- prometheus.MustNewConstMetric(inflightDesc, prometheus.GaugeValue, float64(l.inflight))
+	for p := Normal; p <= Critical; p++ {
+		ch <- prometheus.MustNewConstMetric(queuedDesc, prometheus.GaugeValue, float64(l.queues[p].Len()), p.String())
+		ch <- prometheus.MustNewConstMetric(rejectedDesc, prometheus.CounterValue, float64(l.rejected[p]), p.String())
+		ch <- prometheus.MustNewConstMetric(canceledDesc, prometheus.CounterValue, float64(l.canceled[p]), p.String())
+	}
+	ch <- prometheus.MustNewConstMetric(rttDesc, prometheus.GaugeValue, l.shortRTT, "short")
+	ch <- prometheus.MustNewConstMetric(rttDesc, prometheus.GaugeValue, l.longRTT, "long")
+}
@@ -0,0 +1,166 @@
+package loadshed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v5"
+)
+
+func fixed(limit int) *Limiter {
+	return New(Config{InitialLimit: limit, MinLimit: limit, MaxLimit: limit, QueueTimeout: 20 * time.Millisecond})
+}
+
+func TestLimiter_PriorityReserve(t *testing.T) {
+	// 上限 10：Normal 最多 8、High 最多 9、Critical 可占满
+	l := fixed(10)
+	ctx := context.Background()
+	for i := 0; i < 8; i++ {
+		if _, err := l.Acquire(ctx, Normal); err != nil {
+			t.Fatalf("第 %d 个 Normal 请求应放行: %v", i+1, err)
+		}
+	}
+	if _, err := l.Acquire(ctx, Normal); !errors.Is(err, ErrOverloaded) {
+		t.Errorf("Normal 超出份额后排队超时应返回 ErrOverloaded，得到 %v", err)
+	}
+	if _, err := l.Acquire(ctx, High); err != nil {
+		t.Errorf("High 可使用预留份额: %v", err)
+	}
+	if _, err := l.Acquire(ctx, Critical); err != nil {
+		t.Errorf("Critical 可占满上限: %v", err)
+	}
+	if _, err := l.Acquire(ctx, Critical); !errors.Is(err, ErrOverloaded) {
+		t.Errorf("占满后 Critical 也应被丢弃，得到 %v", err)
+	}
+}
+
+func TestLimiter_QueueServesHigherPriorityFirst(t *testing.T) {
+	l := New(Config{InitialLimit: 10, MinLimit: 10, MaxLimit: 10, QueueTimeout: time.Second})
+	ctx := context.Background()
+	var releases []func()
+	for _, p := range []Priority{Normal, Normal, Normal, Normal, Normal, Normal, Normal, Normal, High, Critical} {
+		release, err := l.Acquire(ctx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		releases = append(releases, release)
+	}
+
+	got := make(chan Priority, 2)
+	for _, p := range []Priority{Normal, Critical} {
+		go func() {
+			if release, err := l.Acquire(ctx, p); err == nil {
+				got <- p
+				release()
+			}
+		}()
+	}
+	// 等两个请求都进入队列
+	for l.queued() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	releases[0]()
+	if p := <-got; p != Critical {
+		t.Errorf("空出一个名额时应先放行 Critical，得到 %v", p)
+	}
+	for _, release := range releases[1:] {
+		release()
+	}
+	if p := <-got; p != Normal {
+		t.Errorf("之后应放行 Normal，得到 %v", p)
+	}
+}
+
+func (l *Limiter) queued() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for _, q := range l.queues {
+		n += q.Len()
+	}
+	return n
+}
+
+func TestLimiter_CanceledWhileQueued(t *testing.T) {
+	l := New(Config{InitialLimit: 10, MinLimit: 10, MaxLimit: 10, QueueTimeout: time.Second})
+	for i := 0; i < 8; i++ {
+		if _, err := l.Acquire(context.Background(), Normal); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := l.Acquire(ctx, Normal)
+		done <- err
+	}()
+	for l.queued() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("排队中取消应返回 context.Canceled，得到 %v", err)
+	}
+	if l.rejected[Normal] != 0 || l.canceled[Normal] != 1 {
+		t.Errorf("取消应单独计数，rejected %d，canceled %d", l.rejected[Normal], l.canceled[Normal])
+	}
+}
+
+func TestLimiter_AdaptsToLatency(t *testing.T) {
+	l := New(Config{InitialLimit: 50, MinLimit: 10, MaxLimit: 500})
+
+	// 延迟稳定时上限逐步增长
+	for i := 0; i < 200; i++ {
+		l.update(10*time.Millisecond, int(l.limit))
+	}
+	grown := l.Limit()
+	if grown <= 50 {
+		t.Fatalf("延迟稳定时上限应增长，得到 %d", grown)
+	}
+
+	// 延迟升高（开始排队）后上限收缩
+	for i := 0; i < 50; i++ {
+		l.update(100*time.Millisecond, int(l.limit))
+	}
+	if shrunk := l.Limit(); shrunk >= grown/2 {
+		t.Errorf("延迟升高 10 倍后上限应明显收缩，从 %d 到 %d", grown, shrunk)
+	}
+
+	// 并发远未用满时不调整
+	before := l.Limit()
+	for i := 0; i < 50; i++ {
+		l.update(10*time.Millisecond, 1)
+	}
+	if l.Limit() != before {
+		t.Errorf("低并发时不应调整上限，从 %d 到 %d", before, l.Limit())
+	}
+}
+
+func TestLimiter_Middleware_Returns503(t *testing.T) {
+	l := fixed(1)
+	e := echo.New()
+	e.GET("/test", func(c *echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	}, l.Middleware(func(*echo.Context) Priority { return Normal }))
+
+	release, err := l.Acquire(context.Background(), Critical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("没有空位时期望 503 与 Retry-After，得到 %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	release()
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test", nil))
+	if rec.Code != http.StatusOK || l.Inflight() != 0 {
+		t.Errorf("释放后应放行并归还名额，得到 %d，inflight %d", rec.Code, l.Inflight())
+	}
+}
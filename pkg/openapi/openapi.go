@@ -0,0 +1,317 @@
+// Package openapi 根据注册的 Echo 路由生成 OpenAPI 3.1 文档：注册路由时附带接口说明与请求、响应类型，
+// 请求与响应的 schema 通过反射结构体的 json 与 validate tag 生成，文档不会与代码脱节。
+//
+//	docs.Add(g.POST("/links", h.Create), openapi.Op("创建短链接").Body(createLinkRequest{}).Returns(201, linkResponse{}))
+package openapi
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v5"
+)
+
+// Operation 一个接口的说明，由 Op 创建并链式设置
+type Operation struct {
+	id          string
+	summary     string
+	description string
+	tags        []string
+	params      any
+	body        any
+	responses   []response
+	errors      []int
+	security    []string
+	deprecated  bool
+}
+
+type response struct {
+	status      int
+	body        any
+	description string
+}
+
+// Op 创建接口说明
+func Op(summary string) *Operation {
+	return &Operation{summary: summary}
+}
+
+// ID 设置 operationId，默认取路由名，未命名时由方法与路径生成，如 get_api_links_code
+func (o *Operation) ID(id string) *Operation {
+	o.id = id
+	return o
+}
+
+// Describe 设置详细说明
+func (o *Operation) Describe(description string) *Operation {
+	o.description = description
+	return o
+}
+
+// Tags 设置分组标签
+func (o *Operation) Tags(tags ...string) *Operation {
+	o.tags = append(o.tags, tags...)
+	return o
+}
+
+// Params 设置路径、查询与请求头参数，取结构体的 param、query、header tag
+func (o *Operation) Params(v any) *Operation {
+	o.params = v
+	return o
+}
+
+// Body 设置 JSON 请求体；结构体同时包含 param 等参数字段时也会据此生成参数
+func (o *Operation) Body(v any) *Operation {
+	o.body = v
+	if o.params == nil {
+		o.params = v
+	}
+	return o
+}
+
+// Returns 添加一个成功响应，v 为 nil 时没有响应体
+func (o *Operation) Returns(status int, v any) *Operation {
+	o.responses = append(o.responses, response{status: status, body: v})
+	return o
+}
+
+// ReturnsDescribed 与 Returns 相同，但自定义响应说明（默认为状态码的标准描述）
+func (o *Operation) ReturnsDescribed(status int, v any, description string) *Operation {
+	o.responses = append(o.responses, response{status: status, body: v, description: description})
+	return o
+}
+
+// Errors 列出该接口可能返回的错误状态码，响应体为 Document.ErrorBody 设置的类型
+func (o *Operation) Errors(statuses ...int) *Operation {
+	o.errors = append(o.errors, statuses...)
+	return o
+}
+
+// Secure 设置认证方式，多个时满足其一即可；名称须已通过 Document.SecurityScheme 注册
+func (o *Operation) Secure(schemes ...string) *Operation {
+	o.security = append(o.security, schemes...)
+	return o
+}
+
+// Deprecated 标记接口已废弃
+func (o *Operation) Deprecated() *Operation {
+	o.deprecated = true
+	return o
+}
+
+type route struct {
+	info echo.RouteInfo
+	op   *Operation
+}
+
+// Document 收集带说明的路由并生成 OpenAPI 文档，可并发使用
+type Document struct {
+	mu        sync.Mutex
+	info      Info
+	servers   []Server
+	routes    []route
+	rules     map[string]RuleFunc
+	schemes   map[string]SecurityScheme
+	errorType string
+	errorBody any
+	// cached 最近一次生成的 JSON，Add 后失效
+	cached []byte
+}
+
+// New 创建文档，已内置 go-playground/validator 常用规则到 schema 约束的映射
+func New(info Info) *Document {
+	return &Document{info: info, rules: maps.Clone(builtinRules), schemes: map[string]SecurityScheme{}}
+}
+
+// Server 添加服务地址
+func (d *Document) Server(url string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.servers = append(d.servers, Server{URL: url})
+	d.cached = nil
+}
+
+// SecurityScheme 注册认证方式，供 Operation.Secure 引用
+func (d *Document) SecurityScheme(name string, s SecurityScheme) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.schemes[name] = s
+	d.cached = nil
+}
+
+// Rule 注册自定义 validate 规则对 schema 的影响
+func (d *Document) Rule(tag string, fn RuleFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.rules[tag] = fn
+	d.cached = nil
+}
+
+// ErrorBody 设置错误响应的媒体类型与结构，每个接口都会带上 default 错误响应
+func (d *Document) ErrorBody(contentType string, v any) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.errorType, d.errorBody = contentType, v
+	d.cached = nil
+}
+
+// Add 为路由附加接口说明并原样返回路由，便于与注册语句写在一起
+func (d *Document) Add(r echo.RouteInfo, op *Operation) echo.RouteInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.routes = append(d.routes, route{info: r, op: op})
+	d.cached = nil
+	return r
+}
+
+// Spec 生成文档
+func (d *Document) Spec() *Spec {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.build()
+}
+
+func (d *Document) build() *Spec {
+	// 先生成一遍找出同时用作请求体与响应的结构体，再为其请求体版本取不同的组件名
+	probe := newGenerator(d.rules, nil)
+	d.generate(probe)
+	return d.generate(newGenerator(d.rules, probe.sharedTypes()))
+}
+
+func (d *Document) generate(g *generator) *Spec {
+	spec := &Spec{
+		OpenAPI: Version,
+		Info:    d.info,
+		Servers: d.servers,
+		Paths:   map[string]PathItem{},
+	}
+	var errorSchema *Schema
+	if d.errorBody != nil {
+		errorSchema = g.schema(reflectType(d.errorBody), true)
+	}
+	for _, r := range d.routes {
+		path := Path(r.info.Path)
+		item, ok := spec.Paths[path]
+		if !ok {
+			item = PathItem{}
+			spec.Paths[path] = item
+		}
+		item[strings.ToLower(r.info.Method)] = d.operation(g, r, errorSchema)
+	}
+	spec.Components.Schemas = g.schemas
+	if len(d.schemes) > 0 {
+		spec.Components.SecuritySchemes = maps.Clone(d.schemes)
+	}
+	return spec
+}
+
+func (d *Document) operation(g *generator, r route, errorSchema *Schema) *OperationObject {
+	op := r.op
+	out := &OperationObject{
+		OperationID: op.id,
+		Summary:     op.summary,
+		Description: op.description,
+		Tags:        op.tags,
+		Parameters:  g.parameters(op.params, r.info.Parameters),
+		Responses:   map[string]Response{},
+		Deprecated:  op.deprecated,
+	}
+	if out.OperationID == "" {
+		out.OperationID = operationID(r.info)
+	}
+	if op.body != nil {
+		s := g.schema(reflectType(op.body), false)
+		out.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{echo.MIMEApplicationJSON: {Schema: s}}}
+	}
+	for _, resp := range op.responses {
+		res := Response{Description: resp.description}
+		if res.Description == "" {
+			res.Description = http.StatusText(resp.status)
+		}
+		if resp.body != nil {
+			res.Content = map[string]MediaType{echo.MIMEApplicationJSON: {Schema: g.schema(reflectType(resp.body), true)}}
+		}
+		out.Responses[strconv.Itoa(resp.status)] = res
+	}
+	if errorSchema != nil {
+		content := map[string]MediaType{d.errorType: {Schema: errorSchema}}
+		for _, status := range op.errors {
+			out.Responses[strconv.Itoa(status)] = Response{Description: http.StatusText(status), Content: content}
+		}
+		out.Responses["default"] = Response{Description: "Error", Content: content}
+	}
+	for _, name := range op.security {
+		out.Security = append(out.Security, map[string][]string{name: {}})
+	}
+	return out
+}
+
+// JSON 返回缩进格式的文档，结果会被缓存直到再次 Add
+func (d *Document) JSON() ([]byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cached != nil {
+		return d.cached, nil
+	}
+	b, err := json.MarshalIndent(d.build(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	d.cached = append(b, '\n')
+	return d.cached, nil
+}
+
+// Handler 返回输出文档的 handler
+func (d *Document) Handler() echo.HandlerFunc {
+	return func(c *echo.Context) error {
+		b, err := d.JSON()
+		if err != nil {
+			return err
+		}
+		return c.JSONBlob(http.StatusOK, b)
+	}
+}
+
+var pathParam = regexp.MustCompile(`:([^/]+)`)
+
+// WildcardParam Echo 路由末尾 * 在文档中的参数名，OpenAPI 的路径参数必须是合法名称
+const WildcardParam = "wildcard"
+
+// Path 把 Echo 路由路径转换为 OpenAPI 路径，如 /api/links/:code -> /api/links/{code}，/static/* -> /static/{wildcard}
+func Path(p string) string {
+	p = pathParam.ReplaceAllString(p, "{$1}")
+	if strings.HasSuffix(p, "*") {
+		p = strings.TrimSuffix(p, "*") + "{" + WildcardParam + "}"
+	}
+	return p
+}
+
+// pathParamName 路径参数在文档中的名称，Echo 的 * 参数映射为 WildcardParam
+func pathParamName(name string) string {
+	if name == "*" {
+		return WildcardParam
+	}
+	return name
+}
+
+// operationID 使用路由名（echo.Route.Name）；未命名的路由由方法与路径生成，如 GET /api/links/:code -> get_api_links_code
+func operationID(r echo.RouteInfo) string {
+	if r.Name != "" && r.Name != r.Method+":"+r.Path {
+		return r.Name
+	}
+	var b strings.Builder
+	b.WriteString(strings.ToLower(r.Method))
+	for _, seg := range strings.FieldsFunc(r.Path, func(r rune) bool { return !isIdentRune(r) }) {
+		b.WriteString("_" + seg)
+	}
+	return b.String()
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
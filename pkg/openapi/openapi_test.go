@@ -0,0 +1,281 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v5"
+)
+
+type base struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type tag struct {
+	Name   string `json:"name"`
+	Parent *tag   `json:"parent,omitempty"`
+}
+
+type itemResponse struct {
+	base
+	Title    string     `json:"title"`
+	Note     *string    `json:"note"`
+	Tags     []tag      `json:"tags"`
+	Owner    *tag       `json:"owner"`
+	DeleteAt *time.Time `json:"delete_at,omitempty"`
+	Secret   string     `json:"-"`
+}
+
+type createItemRequest struct {
+	Title    string            `json:"title" validate:"required,min=3,max=64"`
+	Kind     string            `json:"kind" validate:"omitempty,oneof=book 'music'"`
+	Count    int               `json:"count" validate:"gte=1,lte=10"`
+	Emails   []string          `json:"emails" validate:"max=5,dive,required,email"`
+	Labels   map[string]string `json:"labels" validate:"dive,max=16"`
+	ExpireAt *time.Time        `json:"expire_at" validate:"omitempty,in_future"`
+}
+
+type updateItemRequest struct {
+	ID    int64  `param:"id" validate:"gt=0"`
+	Title string `json:"title" validate:"required"`
+}
+
+type listItemsRequest struct {
+	Limit  int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor string `query:"cursor"`
+	Trace  string `header:"X-Trace" validate:"required"`
+}
+
+func newTestDocument(t *testing.T) (*Document, *echo.Echo) {
+	t.Helper()
+	d := New(Info{Title: "test", Version: "1.0.0"})
+	d.Rule("in_future", Describe("须晚于当前时间"))
+	d.SecurityScheme("bearerAuth", SecurityScheme{Type: "http", Scheme: "bearer"})
+	d.ErrorBody("application/problem+json", struct {
+		Title string `json:"title"`
+	}{})
+	e := echo.New()
+	noop := func(*echo.Context) error { return nil }
+	d.Add(e.POST("/items", noop), Op("创建").Tags("items").Body(createItemRequest{}).
+		Returns(http.StatusCreated, itemResponse{}).Errors(http.StatusBadRequest).Secure("bearerAuth"))
+	d.Add(e.GET("/items", noop), Op("列表").Params(listItemsRequest{}).Returns(http.StatusOK, []itemResponse{}))
+	d.Add(e.PUT("/items/:id", noop), Op("修改").Body(updateItemRequest{}).Returns(http.StatusOK, itemResponse{}))
+	d.Add(e.DELETE("/items/:id/tags/:name", noop), Op("删除标签").ID("deleteItemTag").Returns(http.StatusNoContent, nil))
+	return d, e
+}
+
+func TestSchema_FromTags(t *testing.T) {
+	d, _ := newTestDocument(t)
+	spec := d.Spec()
+
+	req := spec.Components.Schemas["CreateItemRequest"]
+	if req == nil {
+		t.Fatalf("请求体应放入 components，得到 %v", spec.Components.Schemas)
+	}
+	if !reflect.DeepEqual(req.Required, []string{"title"}) {
+		t.Errorf("请求体只有 validate:required 的字段必填，得到 %v", req.Required)
+	}
+	title := req.Properties["title"]
+	if *title.MinLength != 3 || *title.MaxLength != 64 {
+		t.Errorf("min/max 应映射为字符串长度，得到 %+v", title)
+	}
+	if kind := req.Properties["kind"]; !reflect.DeepEqual(kind.Enum, []any{"book", "music"}) {
+		t.Errorf("oneof 应映射为 enum，得到 %v", kind.Enum)
+	}
+	if count := req.Properties["count"]; *count.Minimum != 1 || *count.Maximum != 10 {
+		t.Errorf("gte/lte 应映射为数值范围，得到 %+v", count)
+	}
+	emails := req.Properties["emails"]
+	if *emails.MaxItems != 5 || emails.Items.Format != "email" {
+		t.Errorf("dive 之前的规则作用于数组、之后作用于元素，得到 %+v / %+v", emails, emails.Items)
+	}
+	if labels := req.Properties["labels"]; *labels.AdditionalProperties.MaxLength != 16 {
+		t.Errorf("map 的 dive 应作用于值，得到 %+v", labels.AdditionalProperties)
+	}
+	expire := req.Properties["expire_at"]
+	if !reflect.DeepEqual(expire.Type, Types{"string", "null"}) || expire.Format != "date-time" || expire.Description != "须晚于当前时间" {
+		t.Errorf("指针时间字段应可为 null 并应用自定义规则，得到 %+v", expire)
+	}
+
+	resp := spec.Components.Schemas["ItemResponse"]
+	for _, name := range []string{"id", "created_at", "title", "note", "tags", "owner"} {
+		if !slices.Contains(resp.Required, name) {
+			t.Errorf("响应中未标记 omitempty 的字段 %s 应必有，得到 %v", name, resp.Required)
+		}
+	}
+	if slices.Contains(resp.Required, "delete_at") || resp.Properties["secret"] != nil || resp.Properties["Secret"] != nil {
+		t.Errorf("omitempty 字段不应必有，json:\"-\" 字段应忽略，得到 %+v", resp)
+	}
+	if got := resp.Properties["delete_at"].Type; !reflect.DeepEqual(got, Types{"string"}) {
+		t.Errorf("响应中 omitempty 的指针字段省略而不是 null，得到 %v", got)
+	}
+	if owner := resp.Properties["owner"]; len(owner.AnyOf) != 2 || owner.AnyOf[0].Ref != "#/components/schemas/Tag" {
+		t.Errorf("可为 null 的结构体引用应为 anyOf [$ref, null]，得到 %+v", owner)
+	}
+	if parent := spec.Components.Schemas["Tag"].Properties["parent"]; parent.Ref != "#/components/schemas/Tag" {
+		t.Errorf("递归类型应引用自身，得到 %+v", parent)
+	}
+
+	update := spec.Components.Schemas["UpdateItemRequest"]
+	if _, ok := update.Properties["ID"]; ok {
+		t.Errorf("路径参数字段不属于请求体，得到 %v", update.Properties)
+	}
+}
+
+func TestOperation_Parameters(t *testing.T) {
+	d, _ := newTestDocument(t)
+	spec := d.Spec()
+
+	list := spec.Paths["/items"]["get"]
+	want := []Parameter{
+		{Name: "limit", In: "query"},
+		{Name: "cursor", In: "query"},
+		{Name: "X-Trace", In: "header", Required: true},
+	}
+	if len(list.Parameters) != len(want) {
+		t.Fatalf("期望 %d 个参数，得到 %+v", len(want), list.Parameters)
+	}
+	for i, p := range list.Parameters {
+		if p.Name != want[i].Name || p.In != want[i].In || p.Required != want[i].Required {
+			t.Errorf("参数 %d: 期望 %+v，得到 %+v", i, want[i], p)
+		}
+	}
+	if s := list.Parameters[0].Schema; !s.Type.Is("integer") || *s.Minimum != 1 || *s.Maximum != 100 {
+		t.Errorf("查询参数应应用 validate 规则，得到 %+v", s)
+	}
+	if item := list.Responses["200"].Content["application/json"].Schema; !item.Type.Is("array") || item.Items.Ref != "#/components/schemas/ItemResponse" {
+		t.Errorf("切片响应应为数组，得到 %+v", item)
+	}
+
+	update := spec.Paths["/items/{id}"]["put"]
+	if len(update.Parameters) != 1 || update.Parameters[0].In != "path" || !update.Parameters[0].Required ||
+		!update.Parameters[0].Schema.Type.Is("integer") || *update.Parameters[0].Schema.ExclusiveMinimum != 0 {
+		t.Errorf("请求体结构体中的 param 字段应生成路径参数，得到 %+v", update.Parameters)
+	}
+
+	del := spec.Paths["/items/{id}/tags/{name}"]["delete"]
+	if del.OperationID != "deleteItemTag" {
+		t.Errorf("应使用自定义 operationId，得到 %q", del.OperationID)
+	}
+	if len(del.Parameters) != 2 || del.Parameters[0].Name != "id" || del.Parameters[1].Name != "name" || !del.Parameters[1].Schema.Type.Is("string") {
+		t.Errorf("未声明的路径参数应按字符串生成，得到 %+v", del.Parameters)
+	}
+	if res, ok := del.Responses["204"]; !ok || res.Content != nil || res.Description != "No Content" {
+		t.Errorf("无响应体的响应不应有 content，得到 %+v", res)
+	}
+}
+
+func TestOperation_ResponsesAndSecurity(t *testing.T) {
+	d, _ := newTestDocument(t)
+	op := d.Spec().Paths["/items"]["post"]
+
+	if op.OperationID != "post_items" {
+		t.Errorf("未命名路由的 operationId 应由方法与路径生成，得到 %q", op.OperationID)
+	}
+	for _, status := range []string{"201", "400", "default"} {
+		if _, ok := op.Responses[status]; !ok {
+			t.Errorf("缺少 %s 响应，得到 %v", status, op.Responses)
+		}
+	}
+	if _, ok := op.Responses["400"].Content["application/problem+json"]; !ok {
+		t.Errorf("错误响应应使用 ErrorBody 的媒体类型，得到 %+v", op.Responses["400"])
+	}
+	if !reflect.DeepEqual(op.Security, []map[string][]string{{"bearerAuth": {}}}) {
+		t.Errorf("应带认证要求，得到 %v", op.Security)
+	}
+	if !op.RequestBody.Required || op.RequestBody.Content["application/json"].Schema.Ref != "#/components/schemas/CreateItemRequest" {
+		t.Errorf("请求体应引用 components，得到 %+v", op.RequestBody)
+	}
+}
+
+func TestPath(t *testing.T) {
+	cases := map[string]string{
+		"/api/links":             "/api/links",
+		"/api/links/:code":       "/api/links/{code}",
+		"/users/:id/keys/:kid":   "/users/{id}/keys/{kid}",
+		"/static/*":              "/static/{wildcard}",
+		"/.well-known/jwks.json": "/.well-known/jwks.json",
+	}
+	for in, want := range cases {
+		if got := Path(in); got != want {
+			t.Errorf("Path(%q): 期望 %q，得到 %q", in, want, got)
+		}
+	}
+}
+
+func TestSchema_SharedTypeComponents(t *testing.T) {
+	d := New(Info{Title: "test", Version: "1.0.0"})
+	e := echo.New()
+	noop := func(*echo.Context) error { return nil }
+	// 响应先于请求体注册，组件名不应随注册顺序变化
+	d.Add(e.GET("/tags/:name", noop), Op("查看").Returns(http.StatusOK, tag{}))
+	d.Add(e.PUT("/tags/:name", noop), Op("修改").Body(tag{}).Returns(http.StatusOK, tag{}))
+	spec := d.Spec()
+
+	out, in := spec.Components.Schemas["Tag"], spec.Components.Schemas["TagInput"]
+	if out == nil || in == nil {
+		t.Fatalf("同时用作请求体与响应的类型应生成两个组件，得到 %v", spec.Components.Schemas)
+	}
+	if !reflect.DeepEqual(out.Required, []string{"name"}) || len(in.Required) != 0 {
+		t.Errorf("响应版本按 omitempty、请求体版本按 validate 判断必填，得到 %v / %v", out.Required, in.Required)
+	}
+	if parent := in.Properties["parent"]; parent.AnyOf[0].Ref != "#/components/schemas/TagInput" {
+		t.Errorf("请求体版本的递归引用应指向自身，得到 %+v", parent)
+	}
+	put := spec.Paths["/tags/{name}"]["put"]
+	if ref := put.RequestBody.Content["application/json"].Schema.Ref; ref != "#/components/schemas/TagInput" {
+		t.Errorf("请求体应引用 TagInput，得到 %q", ref)
+	}
+	if ref := put.Responses["200"].Content["application/json"].Schema.Ref; ref != "#/components/schemas/Tag" {
+		t.Errorf("响应应引用 Tag，得到 %q", ref)
+	}
+}
+
+func TestOperation_WildcardParameter(t *testing.T) {
+	d := New(Info{Title: "test", Version: "1.0.0"})
+	e := echo.New()
+	d.Add(e.GET("/static/*", func(*echo.Context) error { return nil }), Op("静态文件"))
+
+	op, ok := d.Spec().Paths["/static/{wildcard}"]["get"]
+	if !ok {
+		t.Fatalf("通配路由应映射为具名参数，得到 %v", d.Spec().Paths)
+	}
+	if len(op.Parameters) != 1 || op.Parameters[0].Name != WildcardParam || op.Parameters[0].In != "path" || !op.Parameters[0].Required {
+		t.Errorf("应生成与路径一致的路径参数，得到 %+v", op.Parameters)
+	}
+}
+
+func TestDocument_Handler(t *testing.T) {
+	d, e := newTestDocument(t)
+	e.GET("/openapi.json", d.Handler())
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("期望 200，得到 %d", rec.Code)
+	}
+	var spec Spec
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("文档不是合法 JSON: %v", err)
+	}
+	if spec.OpenAPI != Version || spec.Info.Title != "test" || len(spec.Paths) != 3 {
+		t.Errorf("文档内容不正确: %s", rec.Body.String())
+	}
+	if got := spec.Components.Schemas["CreateItemRequest"].Properties["expire_at"].Type; !reflect.DeepEqual(got, Types{"string", "null"}) {
+		t.Errorf("可为 null 的类型应输出为数组，得到 %v", got)
+	}
+
+	// 缓存在添加路由后失效
+	first, _ := d.JSON()
+	d.Add(e.GET("/ping", func(*echo.Context) error { return nil }), Op("ping"))
+	second, _ := d.JSON()
+	if string(first) == string(second) {
+		t.Error("添加路由后文档应更新")
+	}
+}
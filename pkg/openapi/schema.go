@@ -0,0 +1,382 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// RuleFunc 把 validate tag 中的一条规则反映到 schema 上，param 为 = 后的参数
+type RuleFunc func(s *Schema, param string)
+
+var (
+	timeType    = reflect.TypeFor[time.Time]()
+	rawJSONType = reflect.TypeFor[json.RawMessage]()
+)
+
+// builtinRules go-playground/validator 内置规则到 JSON Schema 约束的映射；未列出的规则不影响文档
+var builtinRules = map[string]RuleFunc{
+	"min": func(s *Schema, p string) { bound(s, p, &s.MinLength, &s.MinItems, &s.Minimum) },
+	"max": func(s *Schema, p string) { bound(s, p, &s.MaxLength, &s.MaxItems, &s.Maximum) },
+	"gte": func(s *Schema, p string) { bound(s, p, &s.MinLength, &s.MinItems, &s.Minimum) },
+	"lte": func(s *Schema, p string) { bound(s, p, &s.MaxLength, &s.MaxItems, &s.Maximum) },
+	"gt":  func(s *Schema, p string) { exclusive(s, p, &s.ExclusiveMinimum, &s.MinLength, &s.MinItems, 1) },
+	"lt":  func(s *Schema, p string) { exclusive(s, p, &s.ExclusiveMaximum, &s.MaxLength, &s.MaxItems, -1) },
+	"len": func(s *Schema, p string) {
+		bound(s, p, &s.MinLength, &s.MinItems, nil)
+		bound(s, p, &s.MaxLength, &s.MaxItems, nil)
+	},
+	"oneof":     oneOf,
+	"email":     Format("email"),
+	"url":       Format("uri"),
+	"uri":       Format("uri"),
+	"http_url":  Format("uri"),
+	"hostname":  Format("hostname"),
+	"ip":        Format("ip"),
+	"ipv4":      Format("ipv4"),
+	"ipv6":      Format("ipv6"),
+	"uuid":      Format("uuid"),
+	"uuid4":     Format("uuid"),
+	"alpha":     Pattern(`^[a-zA-Z]+$`),
+	"alphanum":  Pattern(`^[a-zA-Z0-9]+$`),
+	"numeric":   Pattern(`^[-+]?[0-9]+(?:\.[0-9]+)?$`),
+	"lowercase": Pattern(`^[^A-Z]*$`),
+	"uppercase": Pattern(`^[^a-z]*$`),
+}
+
+// Format 返回设置 format 的 RuleFunc
+func Format(format string) RuleFunc {
+	return func(s *Schema, _ string) { s.Format = format }
+}
+
+// Pattern 返回设置 pattern 的 RuleFunc
+func Pattern(pattern string) RuleFunc {
+	return func(s *Schema, _ string) { s.Pattern = pattern }
+}
+
+// Describe 返回追加说明的 RuleFunc，用于无法用 JSON Schema 表达的规则
+func Describe(text string) RuleFunc {
+	return func(s *Schema, _ string) {
+		if s.Description != "" {
+			s.Description += "; "
+		}
+		s.Description += text
+	}
+}
+
+// bound 按 schema 类型把 min/max 类规则映射为长度、元素个数或数值范围
+func bound(s *Schema, param string, length, items **int, number **float64) {
+	switch {
+	case s.Type.Is("string"):
+		if n, err := strconv.Atoi(param); err == nil {
+			*length = &n
+		}
+	case s.Type.Is("array"):
+		if n, err := strconv.Atoi(param); err == nil {
+			*items = &n
+		}
+	case (s.Type.Is("integer") || s.Type.Is("number")) && number != nil:
+		if f, err := strconv.ParseFloat(param, 64); err == nil {
+			*number = &f
+		}
+	}
+}
+
+// exclusive gt/lt：数值为开区间，字符串与数组换算为闭区间的长度
+func exclusive(s *Schema, param string, number **float64, length, items **int, delta int) {
+	if s.Type.Is("integer") || s.Type.Is("number") {
+		if f, err := strconv.ParseFloat(param, 64); err == nil {
+			*number = &f
+		}
+		return
+	}
+	if n, err := strconv.Atoi(param); err == nil {
+		bound(s, strconv.Itoa(n+delta), length, items, nil)
+	}
+}
+
+func oneOf(s *Schema, param string) {
+	for _, v := range strings.Fields(param) {
+		if s.Type.Is("integer") || s.Type.Is("number") {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				s.Enum = append(s.Enum, f)
+				continue
+			}
+		}
+		s.Enum = append(s.Enum, strings.Trim(v, "'"))
+	}
+}
+
+// inputSuffix 同时用作请求体与响应的结构体，其请求体版本的组件名后缀
+const inputSuffix = "Input"
+
+// componentKey 同一结构体作为请求体与响应时必填字段不同，分别生成组件
+type componentKey struct {
+	t        reflect.Type
+	response bool
+}
+
+// generator 通过反射生成 schema，具名结构体放入 components 并以 $ref 引用
+type generator struct {
+	rules   map[string]RuleFunc
+	schemas map[string]*Schema
+	names   map[componentKey]string
+	// shared 同时用作请求体与响应的结构体，见 sharedTypes
+	shared map[reflect.Type]bool
+}
+
+func newGenerator(rules map[string]RuleFunc, shared map[reflect.Type]bool) *generator {
+	return &generator{rules: rules, schemas: map[string]*Schema{}, names: map[componentKey]string{}, shared: shared}
+}
+
+// sharedTypes 返回已生成的组件中同时用作请求体与响应的结构体
+func (g *generator) sharedTypes() map[reflect.Type]bool {
+	shared := map[reflect.Type]bool{}
+	for k := range g.names {
+		if _, ok := g.names[componentKey{t: k.t, response: !k.response}]; ok {
+			shared[k.t] = true
+		}
+	}
+	return shared
+}
+
+// schema 返回类型 t 的 schema；response 为 true 时未标记 omitempty 的字段视为必有，
+// 否则（请求体）只有 validate:"required" 的字段是必填的
+func (g *generator) schema(t reflect.Type, response bool) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: Types{"string"}, Format: "date-time"}
+	case t == rawJSONType:
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: Types{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: Types{"integer"}, Format: "int32"}
+	case reflect.Int64:
+		return &Schema{Type: Types{"integer"}, Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		zero := 0.0
+		return &Schema{Type: Types{"integer"}, Minimum: &zero}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{"number"}}
+	case reflect.String:
+		return &Schema{Type: Types{"string"}}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: Types{"string"}, Format: "byte"}
+		}
+		return &Schema{Type: Types{"array"}, Items: g.schema(t.Elem(), response)}
+	case reflect.Map:
+		return &Schema{Type: Types{"object"}, AdditionalProperties: g.schema(t.Elem(), response)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t, response)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.component(t, response)}
+	default:
+		// interface 等无法确定结构的类型
+		return &Schema{}
+	}
+}
+
+// component 注册具名结构体并返回其名称；先占位再生成字段，支持递归类型。
+// 同时用作请求体与响应的结构体，请求体版本的名称加 inputSuffix，如 Tag 与 TagInput
+func (g *generator) component(t reflect.Type, response bool) string {
+	key := componentKey{t: t, response: response}
+	if name, ok := g.names[key]; ok {
+		return name
+	}
+	var suffix []string
+	if !response && g.shared[t] {
+		suffix = []string{inputSuffix}
+	}
+	name := typeName(t, "", suffix...)
+	if _, taken := g.schemas[name]; taken {
+		pkg := t.PkgPath()
+		name = typeName(t, pkg[strings.LastIndex(pkg, "/")+1:], suffix...)
+	}
+	s := &Schema{}
+	g.names[key] = name
+	g.schemas[name] = s
+	*s = *g.object(t, response)
+	return name
+}
+
+// typeName 组件名：prefix、类型名与 suffix 依次拼接，各部分首字母大写，泛型参数等非法字符替换为 _
+func typeName(t reflect.Type, prefix string, suffix ...string) string {
+	var b strings.Builder
+	for _, part := range append([]string{prefix, t.Name()}, suffix...) {
+		for i, r := range part {
+			switch {
+			case i == 0:
+				b.WriteRune(unicode.ToUpper(r))
+			case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+				b.WriteRune(r)
+			default:
+				b.WriteRune('_')
+			}
+		}
+	}
+	return b.String()
+}
+
+func (g *generator) object(t reflect.Type, response bool) *Schema {
+	s := &Schema{Type: Types{"object"}, Properties: map[string]*Schema{}}
+	g.fields(t, response, s)
+	return s
+}
+
+// fields 按 encoding/json 的规则收集字段：内嵌结构体的字段提升到外层，json:"-" 与未导出字段忽略；
+// 没有 json tag 但有 param/query/header tag 的字段来自路径或查询参数，不属于请求体
+func (g *generator) fields(t reflect.Type, response bool, s *Schema) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.fields(ft, response, s)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			if f.Tag.Get("param") != "" || f.Tag.Get("query") != "" || f.Tag.Get("header") != "" {
+				continue
+			}
+			name = f.Name
+		}
+		omitempty := strings.Contains(opts, "omitempty")
+		fs, required := g.field(f, response, omitempty)
+		s.Properties[name] = fs
+		if required {
+			s.Required = append(s.Required, name)
+		}
+	}
+}
+
+// field 生成字段的 schema 并应用 validate tag；指针字段可以为 null（响应中标记了 omitempty 的除外）
+func (g *generator) field(f reflect.StructField, response, omitempty bool) (*Schema, bool) {
+	s := g.schema(f.Type, response)
+	required := g.apply(s, f.Tag.Get("validate"))
+	if response {
+		required = !omitempty
+	}
+	if f.Type.Kind() == reflect.Pointer && !(response && omitempty) {
+		s = nullable(s)
+	}
+	return s, required
+}
+
+// apply 把 validate tag 中的规则应用到 schema，dive 之后的规则作用于数组元素或 map 的值；返回是否必填
+func (g *generator) apply(s *Schema, tag string) (required bool) {
+	target := s
+	for _, rule := range strings.Split(tag, ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch {
+		case name == "dive":
+			switch {
+			case target.Items != nil:
+				target = target.Items
+			case target.AdditionalProperties != nil:
+				target = target.AdditionalProperties
+			default:
+				return required
+			}
+			continue
+		case name == "required":
+			required = required || target == s
+			continue
+		case strings.Contains(rule, "|"):
+			// 或规则无法准确表达，忽略
+			continue
+		}
+		if fn, ok := g.rules[name]; ok {
+			fn(target, param)
+		}
+	}
+	return required
+}
+
+func reflectType(v any) reflect.Type {
+	return reflect.TypeOf(v)
+}
+
+func nullable(s *Schema) *Schema {
+	switch {
+	case s.Ref != "":
+		return &Schema{AnyOf: []*Schema{s, {Type: Types{"null"}}}}
+	case len(s.Type) > 0 && !s.Type.Is("null"):
+		s.Type = append(s.Type, "null")
+	}
+	return s
+}
+
+// parameters 从结构体的 param、query、header tag 生成参数；pathParams 为路由中的路径参数，未在结构体中声明的按字符串处理
+func (g *generator) parameters(v any, pathParams []string) []Parameter {
+	var params []Parameter
+	declared := map[string]bool{}
+	if v != nil {
+		t := reflect.TypeOf(v)
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		params = g.structParameters(t, declared)
+	}
+	var path []Parameter
+	for _, name := range pathParams {
+		name = pathParamName(name)
+		if declared[name] {
+			continue
+		}
+		p := Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: Types{"string"}}}
+		if name == WildcardParam {
+			p.Description = "路由中 * 匹配的剩余路径，可包含 /"
+		}
+		path = append(path, p)
+	}
+	return append(path, params...)
+}
+
+func (g *generator) structParameters(t reflect.Type, declared map[string]bool) []Parameter {
+	var params []Parameter
+	for i := range t.NumField() {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			params = append(params, g.structParameters(f.Type, declared)...)
+			continue
+		}
+		for _, loc := range [...]struct{ tag, in string }{{"param", "path"}, {"query", "query"}, {"header", "header"}} {
+			name, _, _ := strings.Cut(f.Tag.Get(loc.tag), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			s := g.schema(f.Type, false)
+			required := g.apply(s, f.Tag.Get("validate"))
+			if loc.in == "path" {
+				name = pathParamName(name)
+				required = true
+				declared[name] = true
+			}
+			params = append(params, Parameter{Name: name, In: loc.in, Required: required, Schema: s})
+			break
+		}
+	}
+	return params
+}
@@ -0,0 +1,117 @@
+package problem
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"echotest/pkg/requestid"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v5"
+)
+
+// FieldTranslator 把字段校验错误翻译为可读消息，由 echo.Validator 实现时 HTTPErrorHandler 据此填写 errors[].message
+type FieldTranslator interface {
+	TranslateField(fe validator.FieldError, acceptLanguage string) string
+}
+
+// HTTPErrorHandler 返回把任意错误输出为 problem+json 的 echo.HTTPErrorHandler，reg 为 nil 时使用 Default。
+// instance 取请求路径，request_id 取 X-Request-Id；5xx 错误记录日志，响应中不暴露内部错误信息。
+// 校验错误的字段消息按请求的 Accept-Language 翻译（需 e.Validator 实现 FieldTranslator）。
+func HTTPErrorHandler(reg *Registry) echo.HTTPErrorHandler {
+	if reg == nil {
+		reg = Default
+	}
+	return func(c *echo.Context, err error) {
+		if r, _ := echo.UnwrapResponse(c.Response()); r != nil && r.Committed {
+			return
+		}
+		p := From(err, reg)
+		translateFields(c, p, err)
+		p.Instance = c.Request().URL.Path
+		p.RequestID = requestid.GetRequestID(c)
+		if p.Status >= http.StatusInternalServerError {
+			c.Logger().Error("request failed", "status", p.Status, "request_id", p.RequestID, "error", err)
+		}
+
+		var werr error
+		if c.Request().Method == http.MethodHead {
+			werr = c.NoContent(p.Status)
+		} else {
+			c.Response().Header().Set(echo.HeaderContentType, ContentType)
+			werr = c.JSON(p.Status, p)
+		}
+		if werr != nil {
+			c.Logger().Error("failed to send error response", "error", werr)
+		}
+	}
+}
+
+// From 把错误转换为 problem，依次尝试：*Problem、Provider、validator.ValidationErrors、
+// 注册表中的领域错误、echo.HTTPError 与其他 echo.HTTPStatusCoder，其余一律为 500
+func From(err error, reg *Registry) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		cp := *p
+		return &cp
+	}
+	var pv Provider
+	if errors.As(err, &pv) {
+		return pv.Problem()
+	}
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		return Validation(verrs)
+	}
+	if reg != nil {
+		if e, ok := reg.Lookup(err); ok {
+			return Typed(e.Status, e.Slug, e.Title, err.Error())
+		}
+	}
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		detail := he.Message
+		if detail == http.StatusText(he.Code) {
+			detail = ""
+		}
+		return New(he.Code, detail)
+	}
+	var sc echo.HTTPStatusCoder
+	if errors.As(err, &sc) && sc.StatusCode() != 0 {
+		return New(sc.StatusCode(), "")
+	}
+	return New(http.StatusInternalServerError, "")
+}
+
+// Validation 把校验错误转换为 400 problem，errors 中逐项列出字段、规则与参数
+func Validation(errs validator.ValidationErrors) *Problem {
+	p := Typed(http.StatusBadRequest, "validation-failed", "Validation failed", "one or more fields are invalid")
+	p.Errors = make([]FieldError, len(errs))
+	for i, fe := range errs {
+		p.Errors[i] = FieldError{Field: fieldPath(fe.Namespace()), Rule: fe.Tag(), Param: fe.Param()}
+	}
+	return p
+}
+
+// translateFields 为 From 转换出的校验错误逐项填写 message
+func translateFields(c *echo.Context, p *Problem, err error) {
+	tr, ok := c.Echo().Validator.(FieldTranslator)
+	var verrs validator.ValidationErrors
+	if !ok || len(p.Errors) == 0 || !errors.As(err, &verrs) || len(verrs) != len(p.Errors) {
+		return
+	}
+	c.Response().Header().Add("Vary", "Accept-Language")
+	lang := c.Request().Header.Get("Accept-Language")
+	for i, fe := range verrs {
+		p.Errors[i].Message = tr.TranslateField(fe, lang)
+	}
+}
+
+// fieldPath 去掉命名空间开头的结构体名，如 createLinkRequest.Items[0].Name -> Items[0].Name
+func fieldPath(namespace string) string {
+	if _, rest, ok := strings.Cut(namespace, "."); ok {
+		return rest
+	}
+	return namespace
+}
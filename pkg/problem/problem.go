@@ -0,0 +1,144 @@
+// Package problem 按 RFC 9457 输出 application/problem+json 错误响应，
+// 并提供领域错误到 HTTP 状态码的注册表，使各处返回的错误都有统一的结构。
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"sync"
+)
+
+// ContentType problem 响应的媒体类型
+const ContentType = "application/problem+json"
+
+// TypePrefix 本服务定义的 problem type 的前缀；未定义具体类型时 type 为 about:blank
+const TypePrefix = "/problems/"
+
+// Problem RFC 9457 problem details。实现了 error、echo.HTTPStatusCoder 与 json.Marshaler，
+// 可以直接作为 handler 或中间件的错误返回。
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// RequestID 请求 ID，便于据此检索日志
+	RequestID string `json:"request_id,omitempty"`
+	// Errors 请求参数校验失败的字段
+	Errors []FieldError `json:"errors,omitempty"`
+	// Extensions 附加字段，与标准字段平铺输出
+	Extensions map[string]any `json:"-"`
+}
+
+// FieldError 一个字段的校验失败
+type FieldError struct {
+	// Field 字段路径，如 target_url、items[0].name
+	Field string `json:"field"`
+	// Rule 未通过的规则，如 required、max
+	Rule string `json:"rule"`
+	// Param 规则参数，如 max=100 中的 100
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// New 创建 type 为 about:blank 的 problem，title 取状态码的标准描述
+func New(status int, detail string) *Problem {
+	return &Problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+// Typed 创建本服务定义类型的 problem，slug 如 link-not-found
+func Typed(status int, slug, title, detail string) *Problem {
+	return &Problem{Type: TypePrefix + slug, Title: title, Status: status, Detail: detail}
+}
+
+// With 添加一个扩展字段并返回 p 本身
+func (p *Problem) With(key string, value any) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = map[string]any{}
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return p.Title
+	}
+	return p.Title + ": " + p.Detail
+}
+
+func (p *Problem) StatusCode() int {
+	return p.Status
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	type body Problem
+	if len(p.Extensions) == 0 {
+		return json.Marshal((*body)(p))
+	}
+	// 扩展字段与标准字段平铺，标准字段优先
+	out := maps.Clone(p.Extensions)
+	out["type"], out["title"], out["status"] = p.Type, p.Title, p.Status
+	for key, v := range map[string]string{"detail": p.Detail, "instance": p.Instance, "request_id": p.RequestID} {
+		if v != "" {
+			out[key] = v
+		} else {
+			delete(out, key)
+		}
+	}
+	if len(p.Errors) > 0 {
+		out["errors"] = p.Errors
+	} else {
+		delete(out, "errors")
+	}
+	return json.Marshal(out)
+}
+
+// Provider 携带额外信息的错误类型（如缺少哪些权限）实现该接口，自行转换为 problem
+type Provider interface {
+	Problem() *Problem
+}
+
+// Entry 注册表中的一个领域错误
+type Entry struct {
+	Err    error
+	Status int
+	// Slug problem type 的名称，如 link-not-found
+	Slug  string
+	Title string
+}
+
+// Registry 领域错误到 problem 的映射，按 errors.Is 匹配，先注册的优先
+type Registry struct {
+	mu      sync.RWMutex
+	entries []Entry
+}
+
+// Default 默认注册表，HTTPErrorHandler 未指定注册表时使用
+var Default = &Registry{}
+
+// Register 注册领域错误；detail 取错误本身的描述
+func (r *Registry) Register(err error, status int, slug, title string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, Entry{Err: err, Status: status, Slug: slug, Title: title})
+}
+
+// Lookup 返回与 err 匹配的注册项
+func (r *Registry) Lookup(err error) (Entry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, e := range r.entries {
+		if errors.Is(err, e.Err) {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Register 在 Default 中注册领域错误
+func Register(err error, status int, slug, title string) {
+	Default.Register(err, status, slug, title)
+}
@@ -0,0 +1,142 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v5"
+)
+
+var errThingNotFound = errors.New("thing not found")
+
+// serve 用 HTTPErrorHandler 处理 handler 返回的错误，返回响应与解析后的 body
+func serve(t *testing.T, reg *Registry, method string, h echo.HandlerFunc) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler(reg)
+	e.Any("/things/:id", h)
+	req := httptest.NewRequest(method, "/things/1", nil)
+	req.Header.Set(echo.HeaderXRequestID, "req-1")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	var body map[string]any
+	if method != http.MethodHead {
+		if ct := rec.Header().Get(echo.HeaderContentType); ct != ContentType {
+			t.Errorf("Content-Type 应为 %s，得到 %q", ContentType, ct)
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("响应不是合法 JSON: %v, %s", err, rec.Body.String())
+		}
+		if body["instance"] != "/things/1" || body["request_id"] != "req-1" {
+			t.Errorf("应带 instance 与 request_id，得到 %s", rec.Body.String())
+		}
+	}
+	return rec, body
+}
+
+func TestHTTPErrorHandler_RegisteredError(t *testing.T) {
+	reg := &Registry{}
+	reg.Register(errThingNotFound, http.StatusNotFound, "thing-not-found", "Thing not found")
+
+	rec, body := serve(t, reg, http.MethodGet, func(*echo.Context) error {
+		return fmt.Errorf("load thing: %w", errThingNotFound)
+	})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("期望 404，得到 %d", rec.Code)
+	}
+	if body["type"] != "/problems/thing-not-found" || body["title"] != "Thing not found" || body["status"] != float64(404) {
+		t.Errorf("包装后的领域错误应按注册表映射，得到 %s", rec.Body.String())
+	}
+}
+
+func TestHTTPErrorHandler_ValidationError(t *testing.T) {
+	type item struct {
+		Name string `validate:"required"`
+	}
+	type request struct {
+		URL   string `validate:"required,url"`
+		Items []item `validate:"dive"`
+		Size  int    `validate:"max=10"`
+	}
+	err := validator.New().Struct(request{URL: "x", Items: []item{{}}, Size: 11})
+
+	rec, body := serve(t, nil, http.MethodPost, func(*echo.Context) error { return err })
+	if rec.Code != http.StatusBadRequest || body["type"] != "/problems/validation-failed" {
+		t.Fatalf("期望 400 validation-failed，得到 %d %s", rec.Code, rec.Body.String())
+	}
+	var p struct {
+		Errors []FieldError `json:"errors"`
+	}
+	_ = json.Unmarshal(rec.Body.Bytes(), &p)
+	want := []FieldError{
+		{Field: "URL", Rule: "url"},
+		{Field: "Items[0].Name", Rule: "required"},
+		{Field: "Size", Rule: "max", Param: "10"},
+	}
+	if len(p.Errors) != len(want) {
+		t.Fatalf("期望 %d 个字段错误，得到 %s", len(want), rec.Body.String())
+	}
+	for i, w := range want {
+		if p.Errors[i] != w {
+			t.Errorf("字段错误 %d: 期望 %+v，得到 %+v", i, w, p.Errors[i])
+		}
+	}
+}
+
+func TestHTTPErrorHandler_HTTPError(t *testing.T) {
+	// echo-jwt 返回的是包装了原因的 *echo.HTTPError
+	jwtErr := &echo.HTTPError{Code: http.StatusUnauthorized, Message: "missing or malformed jwt"}
+	rec, body := serve(t, nil, http.MethodGet, func(*echo.Context) error {
+		return jwtErr.Wrap(errors.New("missing value in request header"))
+	})
+	if rec.Code != http.StatusUnauthorized || body["type"] != "about:blank" || body["title"] != "Unauthorized" {
+		t.Fatalf("期望 401 about:blank，得到 %d %s", rec.Code, rec.Body.String())
+	}
+	if body["detail"] != "missing or malformed jwt" {
+		t.Errorf("detail 应为 HTTPError 的消息，得到 %v", body["detail"])
+	}
+
+	// 消息与状态码描述相同时不重复输出
+	rec, body = serve(t, nil, http.MethodGet, func(*echo.Context) error { return echo.ErrNotFound })
+	if rec.Code != http.StatusNotFound || body["detail"] != nil {
+		t.Errorf("期望 404 且无 detail，得到 %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHTTPErrorHandler_InternalError(t *testing.T) {
+	rec, body := serve(t, nil, http.MethodGet, func(*echo.Context) error {
+		return errors.New("pq: connection refused")
+	})
+	if rec.Code != http.StatusInternalServerError || body["title"] != "Internal Server Error" {
+		t.Fatalf("期望 500，得到 %d %s", rec.Code, rec.Body.String())
+	}
+	if body["detail"] != nil {
+		t.Errorf("不应暴露内部错误信息，得到 %v", body["detail"])
+	}
+
+	rec, _ = serve(t, nil, http.MethodHead, func(*echo.Context) error { return errors.New("boom") })
+	if rec.Code != http.StatusInternalServerError || rec.Body.Len() != 0 {
+		t.Errorf("HEAD 请求应只返回状态码，得到 %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestProblem_MarshalExtensions(t *testing.T) {
+	p := Typed(http.StatusTooManyRequests, "quota-exceeded", "Quota exceeded", "quota exceeded").
+		With("quota", "created").
+		With("status", 200) // 不能覆盖标准字段
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]any
+	_ = json.Unmarshal(b, &body)
+	if body["quota"] != "created" || body["status"] != float64(429) || body["detail"] != "quota exceeded" {
+		t.Errorf("扩展字段应平铺输出且不覆盖标准字段，得到 %s", b)
+	}
+}
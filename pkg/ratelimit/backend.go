@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Backend 限流状态的存储后端。Limiter 默认使用进程内的令牌桶（Memory）；
+// 多实例部署时可通过 SetBackend 换成共享后端（如 Postgres），使各实例共用同一份配额。
+type Backend interface {
+	// Take 为 key 消耗一个配额；limit 为该 key 当前的速率与容量，后端应以它为准
+	Take(ctx context.Context, key string, limit Limit) (Decision, error)
+}
+
+// Limit 一个 key 的限流额度
+type Limit struct {
+	// Rate 每秒生成的配额数
+	Rate float64
+	// Burst 最大突发数（桶容量）
+	Burst int
+}
+
+// Decision 一次限流判断的结果，用于生成 RateLimit-* 响应头
+type Decision struct {
+	Allowed bool
+	// Limit 配额（桶容量 burst）
+	Limit int
+	// Remaining 本次请求后剩余的令牌数
+	Remaining int
+	// Reset 令牌桶回满所需时间
+	Reset time.Duration
+	// RetryAfter 被拒绝时，下一个令牌生成所需时间；允许时为 0
+	RetryAfter time.Duration
+	// Window 从空桶回满的时间，即 RateLimit-Policy 中的 w
+	Window time.Duration
+}
+
+// refill 以速率 r 生成 n 个令牌所需的时间
+func refill(n, r float64) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return time.Duration(n / r * float64(time.Second))
+}
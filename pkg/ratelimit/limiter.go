@@ -1,13 +1,27 @@
-// Package ratelimit 提供基于令牌桶的接口限流工具，支持按 IP/用户等维度限流，并提供 Echo 中间件。
+// Package ratelimit 提供接口限流工具，支持按 IP/用户等维度限流，并提供 Echo 中间件。
+// 限流状态默认保存在进程内的令牌桶中，也可存放在 Postgres 中供多个实例共享。
 package ratelimit
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
+	"echotest/pkg/problem"
+
 	"github.com/labstack/echo/v5"
-	"golang.org/x/time/rate"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// DefaultMaxKeys 默认最多跟踪的 key 数量
+	DefaultMaxKeys = 100000
+	// DefaultIdleTTL 默认空闲多久后回收 key
+	DefaultIdleTTL = 10 * time.Minute
 )
 
 // Config 限流配置
@@ -18,12 +32,24 @@ type Config struct {
 	Burst int
 	// KeyFunc 从请求中提取限流 key，默认按 RealIP
 	KeyFunc func(*echo.Context) string
+	// MaxKeys 最多同时跟踪的 key 数量，超出时淘汰最久未使用的 key；默认 DefaultMaxKeys
+	MaxKeys int
+	// IdleTTL key 超过该时长没有请求即被回收，默认 DefaultIdleTTL。
+	// 空闲期间令牌桶早已回满，回收后重新创建的桶与原来等价，不会放松限流。
+	IdleTTL time.Duration
+	// Prefix 写入共享后端时加在 key 前的前缀，用于区分共用同一后端的多个限流器
+	Prefix string
 }
 
-// Limiter 按 key 维度的限流器（如按 IP）
+// Limiter 按 key 维度的限流器（如按 IP）。
+// 默认只使用进程内的令牌桶（Memory）；SetBackend 设置共享后端后以后端的判断为准，
+// 后端出错（如数据库不可用）时退回本地令牌桶，限流降级为按实例生效而不是放行或拒绝全部请求。
 type Limiter struct {
-	cfg      Config
-	limiters sync.Map // key string -> *rate.Limiter
+	mu      sync.Mutex
+	cfg     Config
+	backend Backend
+
+	local *Memory
 }
 
 // New 根据配置创建限流器
@@ -36,35 +62,119 @@ func New(cfg Config) *Limiter {
 	if cfg.Burst <= 0 {
 		cfg.Burst = 1
 	}
-	return &Limiter{cfg: cfg}
+	return &Limiter{cfg: cfg, local: NewMemory(cfg.MaxKeys, cfg.IdleTTL)}
+}
+
+// SetLimit 运行时调整速率与突发容量，已存在的 key 在下一次请求时生效
+func (l *Limiter) SetLimit(r float64, burst int) {
+	if burst <= 0 {
+		burst = 1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cfg.Rate, l.cfg.Burst = r, burst
+}
+
+// SetBackend 设置共享后端，nil 表示只使用本地令牌桶
+func (l *Limiter) SetBackend(b Backend) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.backend = b
+}
+
+func (l *Limiter) state() (Limit, Backend) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return Limit{Rate: l.cfg.Rate, Burst: l.cfg.Burst}, l.backend
+}
+
+// Len 返回本地令牌桶当前跟踪的 key 数量
+func (l *Limiter) Len() int {
+	return l.local.Len()
+}
+
+// Sweep 回收本地空闲超过 IdleTTL 的 key，返回回收数量
+func (l *Limiter) Sweep() int {
+	return l.local.Sweep()
+}
+
+// Run 每隔 IdleTTL/2 执行一次 Sweep，阻塞直到 ctx 取消
+func (l *Limiter) Run(ctx context.Context) {
+	l.local.Run(ctx)
+}
+
+// Collector 返回上报本地 key 数量的 Prometheus gauge（ratelimit_tracked_keys{limiter="name"}）
+func (l *Limiter) Collector(name string) prometheus.Collector {
+	return l.local.Collector(name)
 }
 
-// Allow 判断该 key 是否在限流内，true 表示允许
+// Allow 按本地令牌桶判断该 key 是否在限流内，true 表示允许
 func (l *Limiter) Allow(key string) bool {
-	lim, _ := l.limiters.LoadOrStore(key, rate.NewLimiter(rate.Limit(l.cfg.Rate), l.cfg.Burst))
-	return lim.(*rate.Limiter).Allow()
+	limit, _ := l.state()
+	return l.local.get(key, limit).Allow()
 }
 
-// Reserve 预留一个令牌，返回需等待的时长；d > 0 表示需等待
+// Reserve 在本地令牌桶中预留一个令牌，返回需等待的时长；d > 0 表示需等待
 func (l *Limiter) Reserve(key string) (wait time.Duration, ok bool) {
-	lim, _ := l.limiters.LoadOrStore(key, rate.NewLimiter(rate.Limit(l.cfg.Rate), l.cfg.Burst))
-	r := lim.(*rate.Limiter).Reserve()
+	limit, _ := l.state()
+	r := l.local.get(key, limit).Reserve()
 	if !r.OK() {
 		return 0, false
 	}
 	return r.Delay(), true
 }
 
-// Middleware 返回 Echo 限流中间件：超限时返回 429，并设置 Retry-After
+// Check 为 key 消耗一个配额。设置了共享后端时以后端为准，key 加上 Config.Prefix 以区分不同限流器；
+// 后端出错时退回本地令牌桶
+func (l *Limiter) Check(ctx context.Context, key string) Decision {
+	limit, backend := l.state()
+	if backend != nil {
+		if d, err := backend.Take(ctx, l.cfg.Prefix+key, limit); err == nil {
+			return d
+		}
+	}
+	d, _ := l.local.Take(ctx, key, limit)
+	return d
+}
+
+// Take 为 key 消耗一个令牌并设置 RateLimit-* 响应头；超限时设置 Retry-After 并返回 false，
+// err 为 429 problem，调用方原样返回即可。供需要自行决定 key 的中间件使用。
+func (l *Limiter) Take(c *echo.Context, key string) (allowed bool, err error) {
+	d := l.Check(c.Request().Context(), key)
+	SetHeaders(c.Response().Header(), d)
+	if d.Allowed {
+		return true, nil
+	}
+	c.Response().Header().Set("Retry-After", strconv.FormatInt(max(ceilSeconds(d.RetryAfter), 1), 10))
+	return false, problem.New(http.StatusTooManyRequests, "rate limit exceeded")
+}
+
+// SetHeaders 按 IETF RateLimit header fields 草案写入 RateLimit-Limit、RateLimit-Remaining、
+// RateLimit-Reset（秒）与 RateLimit-Policy（如 20;w=2）。
+// 一个请求经过多个限流器时，保留剩余配额最少的那一组，客户端据此退避即可满足所有限制。
+func SetHeaders(h http.Header, d Decision) {
+	if prev := h.Get("RateLimit-Remaining"); prev != "" {
+		if n, err := strconv.Atoi(prev); err == nil && n < d.Remaining {
+			return
+		}
+	}
+	h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+	h.Set("RateLimit-Reset", strconv.FormatInt(ceilSeconds(d.Reset), 10))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", d.Limit, max(ceilSeconds(d.Window), 1)))
+}
+
+// ceilSeconds 向上取整到秒；Retry-After 与 RateLimit-Reset 只接受整数秒，向下取整会让客户端过早重试
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
+
+// Middleware 返回 Echo 限流中间件：每个响应都带 RateLimit-* 头，超限时返回 429，并按令牌生成时间设置 Retry-After
 func (l *Limiter) Middleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c *echo.Context) error {
-			key := l.cfg.KeyFunc(c)
-			if !l.Allow(key) {
-				c.Response().Header().Set("Retry-After", "1")
-				return c.JSON(http.StatusTooManyRequests, map[string]string{
-					"message": "rate limit exceeded",
-				})
+			if ok, err := l.Take(c, l.cfg.KeyFunc(c)); !ok {
+				return err
 			}
 			return next(c)
 		}
@@ -1,9 +1,12 @@
 package ratelimit
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v5"
 )
@@ -89,3 +92,118 @@ func TestLimiter_Middleware_DifferentIPsIndependent(t *testing.T) {
 		t.Errorf("IP1 第 2 次: 期望 429，得到 %d", rec1b.Code)
 	}
 }
+
+func TestLimiter_EvictsLeastRecentlyUsed(t *testing.T) {
+	l := New(Config{Rate: 1, Burst: 1, MaxKeys: 2})
+	l.Allow("a")
+	l.Allow("b")
+	l.Allow("a") // a 变为最近使用
+	l.Allow("c") // 淘汰 b
+	if n := l.Len(); n != 2 {
+		t.Fatalf("期望跟踪 2 个 key，得到 %d", n)
+	}
+	if l.Allow("a") {
+		t.Error("a 未被淘汰，令牌应已用完")
+	}
+	if !l.Allow("b") {
+		t.Error("b 已被淘汰，重新创建后应允许")
+	}
+}
+
+func TestLimiter_SweepIdleKeys(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := New(Config{Rate: 1, Burst: 1, IdleTTL: time.Minute})
+	l.local.now = func() time.Time { return now }
+
+	l.Allow("old")
+	now = now.Add(45 * time.Second)
+	l.Allow("recent")
+	now = now.Add(30 * time.Second)
+
+	if n := l.Sweep(); n != 1 {
+		t.Errorf("期望回收 1 个 key，得到 %d", n)
+	}
+	if n := l.Len(); n != 1 {
+		t.Errorf("期望剩余 1 个 key，得到 %d", n)
+	}
+}
+
+// 大量不同 key（如轮换 IPv6 地址）涌入时，key 数量与堆内存都应保持平稳
+func TestLimiter_MemoryFlatUnderKeyFlood(t *testing.T) {
+	const maxKeys = 1000
+	l := New(Config{Rate: 1, Burst: 1, MaxKeys: maxKeys})
+	flood := func(from, n int) {
+		for i := from; i < from+n; i++ {
+			l.Allow(fmt.Sprintf("2001:db8::%x", i))
+		}
+	}
+	heap := func() uint64 {
+		runtime.GC()
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		return m.HeapAlloc
+	}
+
+	flood(0, 10*maxKeys)
+	before := heap()
+	flood(10*maxKeys, 200*maxKeys)
+	after := heap()
+
+	if n := l.Len(); n != maxKeys {
+		t.Errorf("期望跟踪 %d 个 key，得到 %d", maxKeys, n)
+	}
+	// 再涌入 20 万个 key 后堆增长不应超过 1MB（不淘汰时约增长数十 MB）
+	if after > before && after-before > 1<<20 {
+		t.Errorf("堆内存增长 %d 字节，未保持平稳", after-before)
+	}
+}
+
+func TestLimiter_Middleware_RateLimitHeaders(t *testing.T) {
+	// 每 2 秒 1 个令牌，突发 2
+	now := time.Unix(1000, 0)
+	lim := New(Config{Rate: 0.5, Burst: 2})
+	lim.local.now = func() time.Time { return now }
+	e := echo.New()
+	e.GET("/test", func(c *echo.Context) error {
+		return c.String(http.StatusOK, "ok")
+	}, lim.Middleware())
+
+	serve := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.RemoteAddr = "10.0.0.1:80"
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve()
+	want := map[string]string{
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "1",
+		"RateLimit-Reset":     "2",
+		"RateLimit-Policy":    "2;w=4",
+	}
+	for k, v := range want {
+		if got := rec.Header().Get(k); got != v {
+			t.Errorf("首次请求 %s: 期望 %q，得到 %q", k, v, got)
+		}
+	}
+
+	serve()
+	rec = serve()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("第 3 次请求: 期望 429，得到 %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After 应为下一个令牌的生成时间 2 秒，得到 %q", got)
+	}
+	if got := rec.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("RateLimit-Remaining: 期望 \"0\"，得到 %q", got)
+	}
+
+	// 1.5 秒后仍需等待 0.5 秒，向上取整为 1
+	now = now.Add(1500 * time.Millisecond)
+	if got := serve().Header().Get("Retry-After"); got != "1" {
+		t.Errorf("1.5 秒后 Retry-After 应为 1，得到 %q", got)
+	}
+}
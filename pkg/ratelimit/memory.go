@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+// entry LRU 链表中的一个 key
+type entry struct {
+	key      string
+	lim      *rate.Limiter
+	lastSeen time.Time
+}
+
+// Memory 进程内的令牌桶后端，也是 Limiter 的默认后端与共享后端不可用时的兜底。
+// key 保存在容量有限的 LRU 中：超出 maxKeys 时淘汰最久未使用的 key，Run 定期回收空闲超过 idleTTL 的 key，
+// 因此不断更换 IPv6 地址的客户端也无法让内存无限增长。
+type Memory struct {
+	mu      sync.Mutex
+	maxKeys int
+	idleTTL time.Duration
+	items   map[string]*list.Element
+	lru     *list.List // 表头为最近使用
+
+	now func() time.Time
+}
+
+// NewMemory 创建进程内后端；maxKeys、idleTTL 不大于 0 时使用 DefaultMaxKeys、DefaultIdleTTL
+func NewMemory(maxKeys int, idleTTL time.Duration) *Memory {
+	if maxKeys <= 0 {
+		maxKeys = DefaultMaxKeys
+	}
+	if idleTTL <= 0 {
+		idleTTL = DefaultIdleTTL
+	}
+	return &Memory{maxKeys: maxKeys, idleTTL: idleTTL, items: make(map[string]*list.Element), lru: list.New(), now: time.Now}
+}
+
+// Take 实现 Backend：为 key 消耗一个令牌，并根据令牌桶当前状态计算剩余配额与等待时间
+func (m *Memory) Take(_ context.Context, key string, limit Limit) (Decision, error) {
+	lim := m.get(key, limit)
+	now := m.now()
+	allowed := lim.AllowN(now, 1)
+	tokens := lim.TokensAt(now)
+	r, burst := float64(lim.Limit()), lim.Burst()
+
+	d := Decision{Allowed: allowed, Limit: burst, Remaining: max(int(tokens), 0)}
+	if r > 0 {
+		d.Reset = refill(float64(burst)-tokens, r)
+		d.Window = refill(float64(burst), r)
+		if !allowed {
+			d.RetryAfter = refill(1-tokens, r)
+		}
+	}
+	return d, nil
+}
+
+// get 返回 key 对应的令牌桶，不存在时按 limit 创建，额度变化时原地调整；已满时先淘汰最久未使用的 key
+func (m *Memory) get(key string, limit Limit) *rate.Limiter {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if e, ok := m.items[key]; ok {
+		ent := e.Value.(*entry)
+		ent.lastSeen = now
+		m.lru.MoveToFront(e)
+		if ent.lim.Limit() != rate.Limit(limit.Rate) {
+			ent.lim.SetLimitAt(now, rate.Limit(limit.Rate))
+		}
+		if ent.lim.Burst() != limit.Burst {
+			ent.lim.SetBurstAt(now, limit.Burst)
+		}
+		return ent.lim
+	}
+	for m.lru.Len() >= m.maxKeys {
+		m.remove(m.lru.Back())
+	}
+	ent := &entry{key: key, lim: rate.NewLimiter(rate.Limit(limit.Rate), limit.Burst), lastSeen: now}
+	m.items[key] = m.lru.PushFront(ent)
+	return ent.lim
+}
+
+func (m *Memory) remove(e *list.Element) {
+	m.lru.Remove(e)
+	delete(m.items, e.Value.(*entry).key)
+}
+
+// Len 返回当前跟踪的 key 数量
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
+
+// Sweep 回收空闲超过 idleTTL 的 key，返回回收数量
+func (m *Memory) Sweep() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	deadline := m.now().Add(-m.idleTTL)
+	n := 0
+	// 链表按最近使用排序，从表尾开始遇到未过期的 key 即可停止
+	for e := m.lru.Back(); e != nil && e.Value.(*entry).lastSeen.Before(deadline); e = m.lru.Back() {
+		m.remove(e)
+		n++
+	}
+	return n
+}
+
+// Run 每隔 idleTTL/2 执行一次 Sweep，阻塞直到 ctx 取消
+func (m *Memory) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.idleTTL / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Sweep()
+		}
+	}
+}
+
+// Collector 返回上报当前 key 数量的 Prometheus gauge（ratelimit_tracked_keys{limiter="name"}）
+func (m *Memory) Collector(name string) prometheus.Collector {
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "ratelimit_tracked_keys",
+		Help:        "Number of keys currently tracked by the rate limiter.",
+		ConstLabels: prometheus.Labels{"limiter": name},
+	}, func() float64 { return float64(m.Len()) })
+}
@@ -0,0 +1,289 @@
+package ratelimit
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	defaultPGBatchWindow     = 2 * time.Millisecond
+	defaultPGBatchSize       = 100
+	defaultPGQueueSize       = 10000
+	defaultPGTimeout         = 100 * time.Millisecond
+	defaultPGCooldown        = 5 * time.Second
+	defaultPGCleanupInterval = time.Minute
+	// pgCleanupTimeout 单次清理过期记录的超时时间
+	pgCleanupTimeout = 10 * time.Second
+	// pgEpsilon 数据库时间精度为微秒，计算剩余配额时允许的误差
+	pgEpsilon = time.Microsecond
+)
+
+// ErrUnavailable 共享后端暂不可用（未启动、已停止、排队已满或处于故障冷却期），调用方应退回本地限流
+var ErrUnavailable = errors.New("ratelimit: backend unavailable")
+
+// PostgresConfig Postgres 后端的批量与容错参数，零值使用默认值
+type PostgresConfig struct {
+	// BatchWindow 收到第一个请求后最多再等待多久以合并更多请求，默认 2ms
+	BatchWindow time.Duration
+	// BatchSize 单次往返最多合并的请求数，默认 100
+	BatchSize int
+	// QueueSize 等待发送的请求队列容量，写满后新请求直接退回本地限流，默认 10000
+	QueueSize int
+	// Timeout 单次往返的超时时间，默认 100ms
+	Timeout time.Duration
+	// Cooldown 往返失败后多久内不再访问数据库、直接退回本地限流，默认 5s
+	Cooldown time.Duration
+	// CleanupInterval 清理已回满（等价于不存在）的记录的间隔，默认 1 分钟
+	CleanupInterval time.Duration
+}
+
+// Postgres 在 Postgres 中以 GCRA（通用信元速率算法）原子地扣减配额的共享后端，多个实例共用同一份配额。
+// 每个 key 只存一个理论到达时间（TAT），判断与更新在 ratelimit_take 函数内加行锁完成（见迁移 000008）。
+// 并发请求先进入队列，由 Run 按 BatchWindow/BatchSize 合并成一次往返，同一批次内 key 与限额都相同的请求合并计数。
+type Postgres struct {
+	db     *sql.DB
+	cfg    PostgresConfig
+	logger *slog.Logger
+
+	reqs      chan *pgRequest
+	done      chan struct{}
+	downUntil atomic.Int64 // 故障冷却截止时间（UnixNano）
+}
+
+type pgRequest struct {
+	key   string
+	limit Limit
+	res   chan pgResult
+}
+
+type pgResult struct {
+	d   Decision
+	err error
+}
+
+// NewPostgres 创建 Postgres 后端，需调用 Run 启动批量发送协程；logger 为 nil 时不打印日志
+func NewPostgres(db *sql.DB, cfg PostgresConfig, logger *slog.Logger) *Postgres {
+	if cfg.BatchWindow <= 0 {
+		cfg.BatchWindow = defaultPGBatchWindow
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultPGBatchSize
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultPGQueueSize
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultPGTimeout
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = defaultPGCooldown
+	}
+	if cfg.CleanupInterval <= 0 {
+		cfg.CleanupInterval = defaultPGCleanupInterval
+	}
+	return &Postgres{
+		db:     db,
+		cfg:    cfg,
+		logger: logger,
+		reqs:   make(chan *pgRequest, cfg.QueueSize),
+		done:   make(chan struct{}),
+	}
+}
+
+// Take 实现 Backend。rate 为 0（只有突发容量、不再补充）时 GCRA 无法表示，返回 ErrUnavailable
+func (p *Postgres) Take(ctx context.Context, key string, limit Limit) (Decision, error) {
+	if limit.Rate <= 0 || time.Now().UnixNano() < p.downUntil.Load() {
+		return Decision{}, ErrUnavailable
+	}
+	req := &pgRequest{key: key, limit: limit, res: make(chan pgResult, 1)}
+	select {
+	case p.reqs <- req:
+	case <-p.done:
+		return Decision{}, ErrUnavailable
+	default:
+		return Decision{}, ErrUnavailable
+	}
+	select {
+	case r := <-req.res:
+		return r.d, r.err
+	case <-p.done:
+		return Decision{}, ErrUnavailable
+	case <-ctx.Done():
+		return Decision{}, ctx.Err()
+	}
+}
+
+// Run 合并队列中的请求批量发往数据库，并定期清理过期记录，阻塞直到 ctx 取消
+func (p *Postgres) Run(ctx context.Context) {
+	defer close(p.done)
+	cleanup := time.NewTicker(p.cfg.CleanupInterval)
+	defer cleanup.Stop()
+
+	batch := make([]*pgRequest, 0, p.cfg.BatchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-cleanup.C:
+			p.cleanup(ctx)
+		case req := <-p.reqs:
+			batch = append(batch[:0], req)
+			timer := time.NewTimer(p.cfg.BatchWindow)
+		collect:
+			for len(batch) < p.cfg.BatchSize {
+				select {
+				case req := <-p.reqs:
+					batch = append(batch, req)
+				case <-timer.C:
+					break collect
+				case <-ctx.Done():
+					break collect
+				}
+			}
+			timer.Stop()
+			p.flush(ctx, batch)
+		}
+	}
+}
+
+// pgGroup 同一批次内 key 与限额都相同的请求。热加载修改限额后，同一批次中可能出现同一个 key 的新旧两种限额，
+// 它们分成两组依次扣减，各自按请求携带的限额判断
+type pgGroup struct {
+	key   string
+	limit Limit
+	reqs  []*pgRequest
+}
+
+// flush 把一批请求按 key 与限额合并后调用 ratelimit_take；按 key 排序后再加锁，避免多个实例的批次互相死锁
+func (p *Postgres) flush(ctx context.Context, batch []*pgRequest) {
+	type groupKey struct {
+		key   string
+		limit Limit
+	}
+	index := make(map[groupKey]*pgGroup, len(batch))
+	groups := make([]*pgGroup, 0, len(batch))
+	for _, req := range batch {
+		k := groupKey{req.key, req.limit}
+		g, ok := index[k]
+		if !ok {
+			g = &pgGroup{key: req.key, limit: req.limit}
+			index[k] = g
+			groups = append(groups, g)
+		}
+		g.reqs = append(g.reqs, req)
+	}
+	slices.SortFunc(groups, func(a, b *pgGroup) int {
+		return cmp.Or(
+			strings.Compare(a.key, b.key),
+			cmp.Compare(a.limit.Rate, b.limit.Rate),
+			cmp.Compare(a.limit.Burst, b.limit.Burst),
+		)
+	})
+
+	err := p.take(ctx, groups)
+	if err == nil {
+		return
+	}
+	if p.downUntil.Swap(time.Now().Add(p.cfg.Cooldown).UnixNano()) < time.Now().UnixNano() && p.logger != nil {
+		p.logger.Warn("rate limit backend unavailable, falling back to local limiter",
+			"error", err, "cooldown", p.cfg.Cooldown)
+	}
+	for _, req := range batch {
+		req.res <- pgResult{err: err}
+	}
+}
+
+// take 调用 ratelimit_take，函数按传入顺序逐组扣减并按同样的顺序返回结果
+func (p *Postgres) take(ctx context.Context, groups []*pgGroup) error {
+	keys := make([]string, len(groups))
+	counts := make([]int64, len(groups))
+	intervals := make([]float64, len(groups))
+	tolerances := make([]float64, len(groups))
+	for i, g := range groups {
+		keys[i] = g.key
+		counts[i] = int64(len(g.reqs))
+		intervals[i] = 1 / g.limit.Rate
+		tolerances[i] = float64(g.limit.Burst) / g.limit.Rate
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.Timeout)
+	defer cancel()
+	rows, err := p.db.QueryContext(ctx,
+		"SELECT rl_key, rl_allowed, rl_tat, rl_now FROM ratelimit_take($1, $2, $3, $4)",
+		pq.Array(keys), pq.Array(counts), pq.Array(intervals), pq.Array(tolerances))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	results := make([][]Decision, 0, len(groups))
+	for rows.Next() {
+		var (
+			key      string
+			allowed  int
+			tat, now time.Time
+		)
+		if err := rows.Scan(&key, &allowed, &tat, &now); err != nil {
+			return err
+		}
+		if len(results) >= len(groups) || groups[len(results)].key != key {
+			return fmt.Errorf("ratelimit: unexpected key %q from ratelimit_take", key)
+		}
+		g := groups[len(results)]
+		ds := make([]Decision, len(g.reqs))
+		for i := range ds {
+			ds[i] = gcraDecision(i, allowed, tat, now, g.limit)
+		}
+		results = append(results, ds)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(results) != len(groups) {
+		return fmt.Errorf("ratelimit: ratelimit_take returned %d of %d keys", len(results), len(groups))
+	}
+	// 全部结果就绪后再回复，出错时调用方统一退回本地限流
+	for i, ds := range results {
+		for j, req := range groups[i].reqs {
+			req.res <- pgResult{d: ds[j]}
+		}
+	}
+	return nil
+}
+
+// gcraDecision 计算批次内同一 key 的第 i 个（从 0 开始）请求的结果。
+// tat 为批次开始前的理论到达时间（已不早于 now），前 allowed 个请求被允许，每个允许的请求把 tat 推后一个发放间隔 T；
+// tat - now 即桶回满所需时间，Burst*T 为窗口
+func gcraDecision(i, allowed int, tat, now time.Time, limit Limit) Decision {
+	interval := refill(1, limit.Rate)
+	window := refill(float64(limit.Burst), limit.Rate)
+	d := Decision{Limit: limit.Burst, Window: window}
+	if i < allowed {
+		d.Allowed = true
+		d.Reset = tat.Add(time.Duration(i+1) * interval).Sub(now)
+		d.Remaining = max(int((window-d.Reset+pgEpsilon)/interval), 0)
+		return d
+	}
+	d.Reset = tat.Add(time.Duration(allowed) * interval).Sub(now)
+	d.RetryAfter = max(d.Reset+interval-window, 0)
+	return d
+}
+
+// cleanup 删除 tat 早于当前时间的记录：这些 key 的配额已回满，与不存在等价
+func (p *Postgres) cleanup(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, pgCleanupTimeout)
+	defer cancel()
+	if _, err := p.db.ExecContext(ctx, "DELETE FROM rate_limits WHERE tat < now()"); err != nil && p.logger != nil {
+		p.logger.Error("failed to clean up rate limit keys", "error", err)
+	}
+}
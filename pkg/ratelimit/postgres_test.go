@@ -0,0 +1,185 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"echotest/database"
+)
+
+// 设置 ECHOTEST_PG_DSN（如 postgres://postgres@localhost/echotest_test?sslmode=disable）后针对本地 Postgres 运行，否则跳过
+func testPostgres(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("ECHOTEST_PG_DSN")
+	if dsn == "" {
+		t.Skip("未设置 ECHOTEST_PG_DSN，跳过 Postgres 测试")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	m, err := database.NewMigrator(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(context.Background()); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+	return db
+}
+
+func runPostgres(t *testing.T, db *sql.DB, cfg PostgresConfig) *Postgres {
+	t.Helper()
+	p := NewPostgres(db, cfg, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	go p.Run(ctx)
+	t.Cleanup(func() {
+		cancel()
+		<-p.done
+	})
+	return p
+}
+
+func TestGCRADecision(t *testing.T) {
+	now := time.Unix(1000, 0)
+	limit := Limit{Rate: 0.5, Burst: 2} // 与令牌桶测试相同：每 2 秒 1 个，突发 2
+
+	d := gcraDecision(0, 2, now, now, limit)
+	if !d.Allowed || d.Remaining != 1 || d.Reset != 2*time.Second || d.Window != 4*time.Second {
+		t.Errorf("首个请求结果不正确: %+v", d)
+	}
+	d = gcraDecision(1, 2, now, now, limit)
+	if !d.Allowed || d.Remaining != 0 || d.Reset != 4*time.Second {
+		t.Errorf("第 2 个请求结果不正确: %+v", d)
+	}
+	d = gcraDecision(2, 2, now, now, limit)
+	if d.Allowed || d.RetryAfter != 2*time.Second {
+		t.Errorf("第 3 个请求应被拒绝并等待 2 秒: %+v", d)
+	}
+	// 桶已空 1.5 秒后：还需 0.5 秒
+	d = gcraDecision(0, 0, now.Add(4*time.Second), now.Add(1500*time.Millisecond), limit)
+	if d.Allowed || d.RetryAfter != 500*time.Millisecond {
+		t.Errorf("1.5 秒后应等待 0.5 秒: %+v", d)
+	}
+}
+
+func TestLimiter_FallsBackWhenBackendUnavailable(t *testing.T) {
+	// 端口 1 上没有数据库，连接会立即失败
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 user=x dbname=x sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	p := runPostgres(t, db, PostgresConfig{Cooldown: time.Hour})
+
+	l := New(Config{Rate: 10, Burst: 2})
+	l.SetBackend(p)
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if d := l.Check(ctx, "k"); !d.Allowed {
+			t.Fatalf("请求 %d: 后端不可用时应按本地令牌桶放行", i+1)
+		}
+	}
+	if d := l.Check(ctx, "k"); d.Allowed {
+		t.Error("第 3 次请求: 本地令牌桶应已用完")
+	}
+	// 冷却期内不再访问数据库
+	if _, err := p.Take(ctx, "k", Limit{Rate: 10, Burst: 2}); err != ErrUnavailable {
+		t.Errorf("冷却期内应直接返回 ErrUnavailable，得到 %v", err)
+	}
+}
+
+func TestPostgres_Take(t *testing.T) {
+	db := testPostgres(t)
+	p := runPostgres(t, db, PostgresConfig{})
+	ctx := context.Background()
+	key := fmt.Sprintf("test:%d", time.Now().UnixNano())
+	limit := Limit{Rate: 0.5, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		d, err := p.Take(ctx, key, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !d.Allowed || d.Remaining != 1-i {
+			t.Errorf("请求 %d: 期望允许且剩余 %d，得到 %+v", i+1, 1-i, d)
+		}
+	}
+	d, err := p.Take(ctx, key, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Allowed || d.RetryAfter <= 0 || d.RetryAfter > 2*time.Second {
+		t.Errorf("第 3 次请求应被拒绝并在 2 秒内可重试，得到 %+v", d)
+	}
+}
+
+// 多个 goroutine（模拟多个实例）并发请求同一个 key，允许的总数不能超过 burst
+func TestPostgres_ConcurrentTakeIsAtomic(t *testing.T) {
+	db := testPostgres(t)
+	a := runPostgres(t, db, PostgresConfig{})
+	b := runPostgres(t, db, PostgresConfig{})
+	key := fmt.Sprintf("test:%d", time.Now().UnixNano())
+	limit := Limit{Rate: 0.001, Burst: 10}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for i := 0; i < 50; i++ {
+		backend := a
+		if i%2 == 1 {
+			backend = b
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d, err := backend.Take(context.Background(), key, limit)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if d.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != limit.Burst {
+		t.Errorf("期望共允许 %d 个请求，得到 %d", limit.Burst, allowed)
+	}
+}
+
+// 热加载修改限额后，同一批次中同一个 key 的请求各自按携带的限额判断
+func TestPostgres_BatchWithDifferentLimits(t *testing.T) {
+	db := testPostgres(t)
+	p := runPostgres(t, db, PostgresConfig{BatchWindow: 50 * time.Millisecond})
+	key := fmt.Sprintf("test:%d", time.Now().UnixNano())
+	limits := []Limit{{Rate: 0.001, Burst: 1}, {Rate: 0.001, Burst: 5}, {Rate: 0.001, Burst: 1}, {Rate: 0.001, Burst: 5}}
+
+	var wg sync.WaitGroup
+	for _, limit := range limits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d, err := p.Take(context.Background(), key, limit)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if d.Limit != limit.Burst {
+				t.Errorf("应按请求的限额 %d 判断，得到 %+v", limit.Burst, d)
+			}
+		}()
+	}
+	wg.Wait()
+}
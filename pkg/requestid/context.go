@@ -0,0 +1,135 @@
+package requestid
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v5"
+	"go.uber.org/zap"
+)
+
+// Fields 请求级的日志字段，由 Middleware 写入 request context。
+// UserID 在认证通过后由 SetUserID 补充，之后的日志自动带上
+type Fields struct {
+	RequestID string
+	// Route 匹配的路由路径，如 /api/links/:code
+	Route string
+	// TraceID 调用方传入的链路追踪 ID，见 traceID
+	TraceID string
+	userID  atomic.Int64
+}
+
+// UserID 认证通过的用户 ID，未认证时为 0
+func (f *Fields) UserID() int64 {
+	return f.userID.Load()
+}
+
+// ZapFields 转为 zap 字段，空值不输出
+func (f *Fields) ZapFields() []zap.Field {
+	fields := make([]zap.Field, 0, 4)
+	fields = append(fields, zap.String("request_id", f.RequestID))
+	if f.Route != "" {
+		fields = append(fields, zap.String("route", f.Route))
+	}
+	if id := f.UserID(); id != 0 {
+		fields = append(fields, zap.Int64("user_id", id))
+	}
+	if f.TraceID != "" {
+		fields = append(fields, zap.String("trace_id", f.TraceID))
+	}
+	return fields
+}
+
+type ctxKey struct{}
+
+type scope struct {
+	fields *Fields
+	logger *slog.Logger
+}
+
+// FieldsFromContext 返回 Middleware 写入的请求字段，不在请求中时为 nil
+func FieldsFromContext(ctx context.Context) *Fields {
+	if s, ok := ctx.Value(ctxKey{}).(*scope); ok {
+		return s.fields
+	}
+	return nil
+}
+
+// LoggerFromContext 返回请求级 logger，不在请求中时返回 slog.Default()。
+// service、repository 等只拿得到 context.Context 的代码也可以直接用 slog.InfoContext(ctx, ...)
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if s, ok := ctx.Value(ctxKey{}).(*scope); ok {
+		return s.logger
+	}
+	return slog.Default()
+}
+
+// ContextFields 从 ctx 提取请求字段，供 slog handler 自动附加（utils.ZapHandler.WithContextFields）
+func ContextFields(ctx context.Context) []zap.Field {
+	if f := FieldsFromContext(ctx); f != nil {
+		return f.ZapFields()
+	}
+	return nil
+}
+
+// SetUserID 认证通过后记录用户 ID，由认证中间件调用
+func SetUserID(ctx context.Context, id int64) {
+	if f := FieldsFromContext(ctx); f != nil {
+		f.userID.Store(id)
+	}
+}
+
+// Middleware 为每个请求创建请求字段与 logger，写入 request context 并替换 c.Logger()，
+// 之后无论用 c.Logger()、LoggerFromContext 还是 slog.InfoContext(ctx, ...) 都会带上 request_id 等字段。
+// 须放在 Echo 的 RequestID 中间件之后，字段由 echo.Logger 的 handler 从 ctx 中提取
+func Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			req := c.Request()
+			s := &scope{fields: &Fields{RequestID: GetRequestID(c), Route: c.Path(), TraceID: traceID(req)}}
+			ctx := context.WithValue(req.Context(), ctxKey{}, s)
+			s.logger = slog.New(boundHandler{Handler: c.Logger().Handler(), ctx: ctx})
+			c.SetRequest(req.WithContext(ctx))
+			c.SetLogger(s.logger)
+			return next(c)
+		}
+	}
+}
+
+// boundHandler 绑定请求的 context：调用方没有传入请求 ctx（如 logger.Info）时使用绑定的 ctx
+type boundHandler struct {
+	slog.Handler
+	ctx context.Context
+}
+
+func (h boundHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx == nil || FieldsFromContext(ctx) == nil {
+		ctx = h.ctx
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h boundHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return boundHandler{Handler: h.Handler.WithAttrs(attrs), ctx: h.ctx}
+}
+
+func (h boundHandler) WithGroup(name string) slog.Handler {
+	return boundHandler{Handler: h.Handler.WithGroup(name), ctx: h.ctx}
+}
+
+// traceID 从请求头解析链路追踪 ID：W3C traceparent、Jaeger uber-trace-id 或 B3 X-B3-TraceId
+func traceID(r *http.Request) string {
+	if tp := r.Header.Get("traceparent"); tp != "" {
+		if parts := strings.Split(tp, "-"); len(parts) == 4 && len(parts[1]) == 32 {
+			return parts[1]
+		}
+	}
+	if ut := r.Header.Get("uber-trace-id"); ut != "" {
+		id, _, _ := strings.Cut(ut, ":")
+		return id
+	}
+	return r.Header.Get("X-B3-TraceId")
+}
@@ -40,9 +40,12 @@ func LogWithRequestID(c *echo.Context, logger *zap.Logger, level string, msg str
 	}
 }
 
-// Logger 返回一个在每条日志中自动附带当前请求 request_id 的 logger 封装，用于在链路中打点。
+// Logger 返回一个在每条日志中自动附带当前请求 request_id（经过 Middleware 时还有 route、user_id、trace_id）的 zap logger，
+// 用于在链路中打点；使用 slog 时直接用 c.Logger() 即可。
 // 用法: requestid.Logger(c, utils.Log).Info("step", zap.String("detail", "xxx"))
 func Logger(c *echo.Context, base *zap.Logger) *zap.Logger {
-	id := GetRequestID(c)
-	return base.With(zap.String("request_id", id))
+	if fields := ContextFields(c.Request().Context()); fields != nil {
+		return base.With(fields...)
+	}
+	return base.With(zap.String("request_id", GetRequestID(c)))
 }
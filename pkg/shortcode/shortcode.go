@@ -0,0 +1,40 @@
+// Package shortcode 生成短链接使用的 base62 随机短码。
+package shortcode
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// Alphabet base62 字符表
+const Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// DefaultLength 默认短码长度，62^7 ≈ 3.5 万亿种组合
+const DefaultLength = 7
+
+// maxByte 为 62 的整数倍，超出部分丢弃，避免取模带来的分布偏差
+const maxByte = 256 - 256%len(Alphabet)
+
+// Generate 使用 crypto/rand 生成长度为 n 的 base62 短码
+func Generate(n int) (string, error) {
+	if n <= 0 {
+		return "", fmt.Errorf("shortcode: invalid length %d", n)
+	}
+	out := make([]byte, 0, n)
+	buf := make([]byte, n+n/2)
+	for len(out) < n {
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("shortcode: read random: %w", err)
+		}
+		for _, b := range buf {
+			if int(b) >= maxByte {
+				continue
+			}
+			out = append(out, Alphabet[int(b)%len(Alphabet)])
+			if len(out) == n {
+				break
+			}
+		}
+	}
+	return string(out), nil
+}
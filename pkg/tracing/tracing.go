@@ -12,6 +12,8 @@ package tracing
 import (
 	"github.com/labstack/echo-contrib/jaegertracing"
 	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"github.com/opentracing/opentracing-go"
 )
 
 // Skipper 用于跳过不需要追踪的请求（如 /metrics、/health）
@@ -20,7 +22,7 @@ type Skipper func(c *echo.Context) bool
 // Register 为 Echo 注册 Jaeger 链路追踪中间件，并为每个请求创建根 span。
 // skipper 为 nil 时不跳过任何 URL；返回的 close 应在程序退出时调用（如 defer close()）。
 func Register(e *echo.Echo, skipper Skipper) (close func()) {
-	c := jaegertracing.New(e, skipper)
+	c := jaegertracing.New(e, middleware.Skipper(skipper))
 	return func() { c.Close() }
 }
 
@@ -33,6 +35,6 @@ func TraceFunction(c *echo.Context, fn interface{}, args ...interface{}) {
 // CreateChildSpan 创建子 span，用于在 Handler 内打点（LogEvent、SetTag、SetBaggageItem 等）。
 // 使用完毕后须调用 span.Finish()。
 // 用法: sp := tracing.CreateChildSpan(c, "db.query"); defer sp.Finish(); sp.SetTag("query", "SELECT ...")
-func CreateChildSpan(c *echo.Context, operationName string) *jaegertracing.Span {
+func CreateChildSpan(c *echo.Context, operationName string) opentracing.Span {
 	return jaegertracing.CreateChildSpan(c, operationName)
 }
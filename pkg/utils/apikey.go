@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"context"
+	"echotest/pkg/requestid"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+)
+
+// APIKeyPrefix API key 的固定前缀，便于在 Authorization 头中与 JWT 区分，也便于密钥扫描工具识别
+const APIKeyPrefix = "esk_"
+
+// Principal 认证通过后的调用方身份，JWT 与 API key 两种凭证写入 context 的内容一致
+type Principal struct {
+	UserID int
+	Email  string
+	Role   string
+	// Plan 订阅套餐，限流策略按套餐选择额度
+	Plan   string
+	Scopes []string
+	// APIKeyID 通过 API key 认证时为 key 的 ID，JWT 认证时为 0
+	APIKeyID int64
+}
+
+// APIKeyAuthenticator 校验 API key；key 不存在、已过期时返回 ok=false
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, rawKey string) (p Principal, ok bool, err error)
+}
+
+// Authenticate 同时接受 JWT 与 API key 的认证中间件。
+// 请求带 X-API-Key 头，或 Authorization: Bearer 后是 esk_ 开头的 key 时按 API key 校验，否则交给 jwtAuth。
+func Authenticate(jwtAuth echo.MiddlewareFunc, keys APIKeyAuthenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		viaJWT := jwtAuth(next)
+		return func(c *echo.Context) error {
+			raw := apiKeyFromRequest(c.Request())
+			if raw == "" {
+				return viaJWT(c)
+			}
+			p, ok, err := keys.AuthenticateAPIKey(c.Request().Context(), raw)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired api key")
+			}
+			setPrincipal(c, p)
+			return next(c)
+		}
+	}
+}
+
+func apiKeyFromRequest(r *http.Request) string {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get(echo.HeaderAuthorization), "Bearer "); ok && strings.HasPrefix(token, APIKeyPrefix) {
+		return token
+	}
+	return ""
+}
+
+// setPrincipal 写入 email、userID、role、plan、scopes，API key 认证时额外写入 apiKeyID
+func setPrincipal(c *echo.Context, p Principal) {
+	c.Set("email", p.Email)
+	c.Set("userID", p.UserID)
+	requestid.SetUserID(c.Request().Context(), int64(p.UserID))
+	c.Set("role", p.Role)
+	c.Set("plan", p.Plan)
+	c.Set("scopes", p.Scopes)
+	if p.APIKeyID != 0 {
+		c.Set("apiKeyID", p.APIKeyID)
+	}
+}
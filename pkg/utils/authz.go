@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"echotest/config"
+	"echotest/pkg/problem"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/labstack/echo/v5"
+)
+
+// ForbiddenError 权限不足。实现了 problem.Provider，
+// 作为 error 返回时输出为 403 problem，扩展字段告知调用方缺少哪些权限。
+type ForbiddenError struct {
+	Message string
+	// MissingScopes 缺少的 scope
+	MissingScopes []string
+	// RequiredRoles 允许访问的角色（满足其一即可），当前角色不在其中时返回
+	RequiredRoles []string
+	// Role 当前角色
+	Role string
+}
+
+func (e *ForbiddenError) Error() string {
+	var parts []string
+	if len(e.MissingScopes) > 0 {
+		parts = append(parts, "missing scopes: "+strings.Join(e.MissingScopes, ", "))
+	}
+	if len(e.RequiredRoles) > 0 {
+		parts = append(parts, "requires role: "+strings.Join(e.RequiredRoles, " | "))
+	}
+	return e.Message + " (" + strings.Join(parts, "; ") + ")"
+}
+
+func (e *ForbiddenError) Problem() *problem.Problem {
+	p := problem.Typed(http.StatusForbidden, "forbidden", "Forbidden", e.Message)
+	if len(e.MissingScopes) > 0 {
+		p.With("missing_scopes", e.MissingScopes)
+	}
+	if len(e.RequiredRoles) > 0 {
+		p.With("required_roles", e.RequiredRoles)
+	}
+	if e.Role != "" {
+		p.With("role", e.Role)
+	}
+	return p
+}
+
+// RequireScopes 要求当前凭证具备全部 scope，须放在 JWT 等认证中间件之后
+func RequireScopes(scopes ...string) echo.MiddlewareFunc {
+	return guard(func(*echo.Context) ([]string, []string) { return nil, scopes })
+}
+
+// RequireRole 要求当前用户是给定角色之一，须放在 JWT 等认证中间件之后
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return guard(func(*echo.Context) ([]string, []string) { return roles, nil })
+}
+
+// Policy 按配置 authz.policies[group] 检查权限，每次请求读取最新配置，修改后无需重启。
+// 与请求方法匹配的规则全部需要满足；没有匹配规则时只要求已登录。
+func Policy(mgr *config.Manager, group string) echo.MiddlewareFunc {
+	return guard(func(c *echo.Context) (roles, scopes []string) {
+		method := c.Request().Method
+		for _, rule := range mgr.Current().Policy(group) {
+			if len(rule.Methods) > 0 && !slices.Contains(rule.Methods, method) {
+				continue
+			}
+			roles = append(roles, rule.Roles...)
+			scopes = append(scopes, rule.Scopes...)
+		}
+		return roles, scopes
+	})
+}
+
+// guard 授权中间件的公共逻辑：required 返回本次请求要求的角色（其一）与 scope（全部）
+func guard(required func(c *echo.Context) (roles, scopes []string)) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			if id, ok := c.Get("userID").(int); !ok || id <= 0 {
+				return echo.ErrUnauthorized
+			}
+			roles, scopes := required(c)
+			if err := authorize(c, roles, scopes); err != nil {
+				return err
+			}
+			return next(c)
+		}
+	}
+}
+
+// authorize 检查 context 中的 role、scopes，不满足时返回 *ForbiddenError
+func authorize(c *echo.Context, roles, scopes []string) error {
+	role, _ := c.Get("role").(string)
+	granted, _ := c.Get("scopes").([]string)
+
+	var missing []string
+	for _, s := range scopes {
+		if !ScopeGranted(granted, s) && !slices.Contains(missing, s) {
+			missing = append(missing, s)
+		}
+	}
+	roleDenied := len(roles) > 0 && !slices.Contains(roles, role)
+	if len(missing) == 0 && !roleDenied {
+		return nil
+	}
+	err := &ForbiddenError{Message: "insufficient permissions", MissingScopes: missing, Role: role}
+	if roleDenied {
+		err.RequiredRoles = roles
+	}
+	return err
+}
+
+// ScopeGranted 判断已授予的 scope 是否覆盖 required：
+// 完全相同、"*"，或 "links:*" 这样的前缀通配
+func ScopeGranted(granted []string, required string) bool {
+	for _, g := range granted {
+		if g == required || g == "*" {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(g, "*"); ok && strings.HasPrefix(required, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"echotest/pkg/problem"
+
+	"github.com/labstack/echo/v5"
+)
+
+// forbiddenBody 403 problem 中的权限扩展字段
+type forbiddenBody struct {
+	MissingScopes []string `json:"missing_scopes"`
+	RequiredRoles []string `json:"required_roles"`
+	Role          string   `json:"role"`
+}
+
+// fakeAuth 模拟 JWT 中间件写入的 context
+func fakeAuth(role string, scopes ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			c.Set("userID", 1)
+			c.Set("role", role)
+			c.Set("scopes", scopes)
+			return next(c)
+		}
+	}
+}
+
+func serve(t *testing.T, mw ...echo.MiddlewareFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler(problem.Default)
+	e.GET("/", func(c *echo.Context) error { return c.NoContent(http.StatusOK) }, mw...)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	return rec
+}
+
+func TestRequireScopes(t *testing.T) {
+	if rec := serve(t, fakeAuth("user", "links:*"), RequireScopes("links:write")); rec.Code != http.StatusOK {
+		t.Errorf("links:* 应覆盖 links:write，得到 %d", rec.Code)
+	}
+
+	rec := serve(t, fakeAuth("user", "links:read"), RequireScopes("links:read", "links:write"))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("期望 403，得到 %d", rec.Code)
+	}
+	var body forbiddenBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.MissingScopes) != 1 || body.MissingScopes[0] != "links:write" {
+		t.Errorf("响应应指出缺少 links:write，得到 %s", rec.Body.String())
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	if rec := serve(t, fakeAuth("admin"), RequireRole("admin")); rec.Code != http.StatusOK {
+		t.Errorf("admin 应放行，得到 %d", rec.Code)
+	}
+	rec := serve(t, fakeAuth("user", "*"), RequireRole("admin"))
+	var body forbiddenBody
+	_ = json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusForbidden || len(body.RequiredRoles) != 1 || body.Role != "user" {
+		t.Errorf("期望 403 且列出所需角色，得到 %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGuard_RequiresAuthentication(t *testing.T) {
+	if rec := serve(t, RequireScopes("links:read")); rec.Code != http.StatusUnauthorized {
+		t.Errorf("未登录应返回 401，得到 %d", rec.Code)
+	}
+}
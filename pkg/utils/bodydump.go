@@ -0,0 +1,339 @@
+package utils
+
+import (
+	"bytes"
+	"cmp"
+	"echotest/config"
+	"echotest/pkg/requestid"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math/rand/v2"
+	"mime"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// BodyLog body 抓取的独立日志流，由 InitLogger 按 log.body_dump.sink 创建，不受全局日志级别控制
+var BodyLog = zap.NewNop()
+
+const (
+	defaultBodyDumpFile     = "./logs/body.log"
+	defaultBodyDumpMaxBytes = 4096
+	// bodyDumpCaptureLimit 响应最多缓存的字节数：脱敏需要完整的 JSON，截断到 max_bytes 在脱敏之后进行。
+	// 请求体已由 BodyLimit 限制
+	bodyDumpCaptureLimit = 1 << 20
+	redacted             = "[REDACTED]"
+)
+
+// alwaysRedactHeaders 始终脱敏的头，与 redact_headers 合并
+var alwaysRedactHeaders = []string{echo.HeaderAuthorization, echo.HeaderCookie, echo.HeaderSetCookie, "X-API-Key", "Proxy-Authorization"}
+
+// newBodyLogger 按 log.body_dump.sink 创建 BodyLog；未配置 body_dump 时同样创建，
+// 便于热加载开启（文件在首次写入时才创建）
+func newBodyLogger(cfg *config.LogConfig) (*zap.Logger, error) {
+	sink := config.LogSinkConfig{Type: "file"}
+	if bd := cfg.BodyDump; bd != nil && bd.Sink != nil {
+		sink = *bd.Sink
+	}
+	// 不回退到 log.filename 与 log.format，避免与主日志混在一起
+	if sink.Type == "file" {
+		sink.Filename = cmp.Or(sink.Filename, defaultBodyDumpFile)
+	}
+	sink.Format = cmp.Or(sink.Format, "json")
+	core, err := newSinkCore(cfg, sink, zapcore.DebugLevel)
+	if err != nil {
+		return zap.NewNop(), fmt.Errorf("body dump sink %s: %w", sink.Type, err)
+	}
+	return zap.New(core).Named("bodydump").With(serviceField(cfg)), nil
+}
+
+// bodyDumpConfig 取出 log.body_dump，log 未配置时为 nil
+func bodyDumpConfig(cfg *config.LogConfig) *config.BodyDumpConfig {
+	if cfg == nil {
+		return nil
+	}
+	return cfg.BodyDump
+}
+
+// bodyDumpRules 由 BodyDumpConfig 解析而来，整体替换
+type bodyDumpRules struct {
+	// routes "METHOD /path" 或 "/path"
+	routes     map[string]struct{}
+	sampleRate float64
+	maxBytes   int
+	paths      [][]string
+	headers    map[string]struct{}
+}
+
+// bodyDumper 可在运行时替换规则的 body 抓取中间件；规则为 nil 时不抓取
+type bodyDumper struct {
+	rules  atomic.Pointer[bodyDumpRules]
+	logger func() *zap.Logger
+}
+
+func newBodyDumper(cfg *config.BodyDumpConfig) *bodyDumper {
+	d := &bodyDumper{logger: func() *zap.Logger { return BodyLog }}
+	d.Set(cfg)
+	return d
+}
+
+// Set 替换抓取规则，cfg 为 nil 或未配置路由与抽样时关闭
+func (d *bodyDumper) Set(cfg *config.BodyDumpConfig) {
+	if cfg == nil || (len(cfg.Routes) == 0 && cfg.SampleRate == 0) {
+		d.rules.Store(nil)
+		return
+	}
+	r := &bodyDumpRules{
+		routes:     make(map[string]struct{}, len(cfg.Routes)),
+		sampleRate: cfg.SampleRate,
+		maxBytes:   cmp.Or(cfg.MaxBytes, defaultBodyDumpMaxBytes),
+		headers:    map[string]struct{}{},
+	}
+	for _, route := range cfg.Routes {
+		method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+		if ok {
+			route = strings.ToUpper(method) + " " + strings.TrimSpace(path)
+		}
+		r.routes[route] = struct{}{}
+	}
+	for _, p := range cfg.RedactPaths {
+		r.paths = append(r.paths, strings.Split(p, "."))
+	}
+	for _, h := range slices.Concat(alwaysRedactHeaders, cfg.RedactHeaders) {
+		r.headers[http.CanonicalHeaderKey(h)] = struct{}{}
+	}
+	d.rules.Store(r)
+}
+
+// enabled 路由命中或抽样命中时抓取
+func (r *bodyDumpRules) enabled(c *echo.Context) bool {
+	path := c.Path()
+	if _, ok := r.routes[path]; ok {
+		return true
+	}
+	if _, ok := r.routes[c.Request().Method+" "+path]; ok {
+		return true
+	}
+	return r.sampleRate > 0 && rand.Float64()*100 < r.sampleRate
+}
+
+// Middleware 须挂载在 Gzip 之后，才能拿到压缩前的响应
+func (d *bodyDumper) Middleware() echo.MiddlewareFunc {
+	dump := middleware.BodyDumpWithConfig(middleware.BodyDumpConfig{
+		Skipper: func(c *echo.Context) bool {
+			r := d.rules.Load()
+			if r == nil || !r.enabled(c) {
+				return true
+			}
+			c.Set("bodyDumpRules", r)
+			return false
+		},
+		Handler: func(c *echo.Context, reqBody, resBody []byte, err error) {
+			r, _ := c.Get("bodyDumpRules").(*bodyDumpRules)
+			if r != nil {
+				d.logger().Info("body dump", r.fields(c, reqBody, resBody, err)...)
+			}
+		},
+		MaxRequestBytes:  -1,
+		MaxResponseBytes: bodyDumpCaptureLimit,
+	})
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		// 错误响应由全局错误处理器写出，抓取时在抓取范围内提前处理（响应已提交，外层不会重复写）；
+		// 未抓取的请求仍由外层处理
+		return dump(func(c *echo.Context) error {
+			err := next(c)
+			if err != nil && c.Get("bodyDumpRules") != nil {
+				c.Echo().HTTPErrorHandler(c, err)
+			}
+			return err
+		})
+	}
+}
+
+func (r *bodyDumpRules) fields(c *echo.Context, reqBody, resBody []byte, err error) []zap.Field {
+	req := c.Request()
+	fields := []zap.Field{
+		zap.String("method", req.Method),
+		zap.String("uri", r.redactURI(req.URL)),
+	}
+	if rf := requestid.ContextFields(req.Context()); rf != nil {
+		fields = append(fields, rf...)
+	}
+	if resp, uerr := echo.UnwrapResponse(c.Response()); uerr == nil {
+		fields = append(fields, zap.Int("status", resp.Status))
+	}
+	fields = append(fields, zap.Object("request_headers", r.redactHeaders(req.Header)))
+	fields = append(fields, r.body("request", req.Header, reqBody)...)
+	fields = append(fields, zap.Object("response_headers", r.redactHeaders(c.Response().Header())))
+	fields = append(fields, r.body("response", c.Response().Header(), resBody)...)
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	return fields
+}
+
+// redactHeaders 按名称排序输出，多值以逗号连接
+func (r *bodyDumpRules) redactHeaders(h http.Header) zapcore.ObjectMarshaler {
+	return zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		for _, name := range slices.Sorted(maps.Keys(h)) {
+			if _, ok := r.headers[http.CanonicalHeaderKey(name)]; ok {
+				enc.AddString(name, redacted)
+				continue
+			}
+			enc.AddString(name, strings.Join(h[name], ", "))
+		}
+		return nil
+	})
+}
+
+// body 脱敏并截断 body；二进制、已压缩或无法解析（无法可靠脱敏）的内容只记录原因
+func (r *bodyDumpRules) body(prefix string, h http.Header, b []byte) []zap.Field {
+	if len(b) == 0 {
+		return nil
+	}
+	size := zap.Int(prefix+"_body_size", len(b))
+	if h.Get(echo.HeaderContentEncoding) != "" {
+		return []zap.Field{size, zap.String(prefix+"_body_skipped", "encoded")}
+	}
+	mediaType, _, _ := mime.ParseMediaType(h.Get(echo.HeaderContentType))
+	var (
+		out []byte
+		err error
+	)
+	switch {
+	case mediaType == echo.MIMEApplicationJSON || strings.HasSuffix(mediaType, "+json"):
+		out, err = r.redactJSON(b)
+	case mediaType == echo.MIMEApplicationForm:
+		out, err = r.redactForm(b)
+	case strings.HasPrefix(mediaType, "text/") || mediaType == echo.MIMEApplicationXML || strings.HasSuffix(mediaType, "+xml"):
+		out = b
+	default:
+		return []zap.Field{size, zap.String(prefix+"_body_skipped", "binary content type "+strconv.Quote(mediaType))}
+	}
+	if err != nil {
+		return []zap.Field{size, zap.String(prefix+"_body_skipped", "unparsable: "+err.Error())}
+	}
+	fields := []zap.Field{size}
+	if len(out) > r.maxBytes {
+		out = out[:r.maxBytes]
+		fields = append(fields, zap.Bool(prefix+"_body_truncated", true))
+	}
+	return append(fields, zap.ByteString(prefix+"_body", out))
+}
+
+// redactJSON 脱敏后重新编码（字段按名称排序），数字保持原样
+func (r *bodyDumpRules) redactJSON(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	v = r.redactValue(v, nil)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// redactValue path 为 v 所在位置，命中 paths 或敏感字段名时替换为 [REDACTED]
+func (r *bodyDumpRules) redactValue(v any, path []string) any {
+	if r.redactPath(path) {
+		return redacted
+	}
+	switch t := v.(type) {
+	case map[string]any:
+		for k, e := range t {
+			t[k] = r.redactValue(e, append(path, k))
+		}
+	case []any:
+		for i, e := range t {
+			t[i] = r.redactValue(e, append(path, strconv.Itoa(i)))
+		}
+	}
+	return v
+}
+
+// redactPath 字段名敏感或命中 redact_paths 时脱敏
+func (r *bodyDumpRules) redactPath(path []string) bool {
+	if len(path) == 0 {
+		return false
+	}
+	return sensitiveKey(path[len(path)-1]) || slices.ContainsFunc(r.paths, func(p []string) bool { return pathMatch(p, path) })
+}
+
+// pathMatch * 匹配任意字段或数组下标
+func pathMatch(pattern, path []string) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != "*" && pattern[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sensitiveKey password、token、secret、key 及以 _token、_secret、_key 结尾的字段（如 api_key）无论位置始终脱敏
+func sensitiveKey(k string) bool {
+	k = strings.ToLower(k)
+	switch k {
+	case "password", "token", "secret", "key":
+		return true
+	}
+	return strings.HasSuffix(k, "_token") || strings.HasSuffix(k, "_secret") || strings.HasSuffix(k, "_key")
+}
+
+// redactForm 脱敏表单中的敏感字段与命中 redact_paths 的顶层字段，字段按名称排序
+func (r *bodyDumpRules) redactForm(b []byte) ([]byte, error) {
+	values, err := url.ParseQuery(string(b))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(r.redactValues(values)), nil
+}
+
+// redactURI 按表单规则脱敏查询参数，如 ?token=...；无法解析时不输出查询参数
+func (r *bodyDumpRules) redactURI(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.RequestURI()
+	}
+	values, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return u.EscapedPath() + "?" + redacted
+	}
+	return u.EscapedPath() + "?" + r.redactValues(values)
+}
+
+func (r *bodyDumpRules) redactValues(values url.Values) string {
+	var sb strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(values)) {
+		for _, v := range values[k] {
+			if sb.Len() > 0 {
+				sb.WriteByte('&')
+			}
+			sb.WriteString(url.QueryEscape(k))
+			sb.WriteByte('=')
+			if r.redactPath([]string{k}) {
+				sb.WriteString(redacted)
+			} else {
+				sb.WriteString(url.QueryEscape(v))
+			}
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,204 @@
+package utils
+
+import (
+	"echotest/config"
+	"echotest/pkg/problem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v5"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// dumpServer 挂载 body 抓取中间件的 Echo，返回记录的抓取日志
+func dumpServer(cfg *config.BodyDumpConfig) (*echo.Echo, *observer.ObservedLogs) {
+	core, logs := observer.New(zap.DebugLevel)
+	d := newBodyDumper(cfg)
+	d.logger = func() *zap.Logger { return zap.New(core) }
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler(problem.Default)
+	e.Use(d.Middleware())
+	e.POST("/login", func(c *echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]any{"access_token": "at", "token_type": "Bearer", "user": map[string]any{"id": 7, "email": "a@b.c"}})
+	})
+	e.POST("/upload", func(c *echo.Context) error {
+		return c.Blob(http.StatusOK, "image/png", []byte{0x89, 'P', 'N', 'G'})
+	})
+	e.GET("/links/:code", func(c *echo.Context) error {
+		return echo.NewHTTPError(http.StatusNotFound, "link not found")
+	})
+	e.POST("/api/keys", func(c *echo.Context) error {
+		return c.JSON(http.StatusCreated, map[string]any{"id": 3, "name": "ci", "prefix": "ek_1a2b", "scopes": []string{"links:write"}, "key": "ek_1a2b3c4d5e6f"})
+	})
+	return e, logs
+}
+
+func dump(e *echo.Echo, method, target, contentType, body string) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set(echo.HeaderContentType, contentType)
+	}
+	req.Header.Set(echo.HeaderAuthorization, "Bearer secret-jwt")
+	req.Header.Set("X-Tenant-Id", "t1")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestBodyDump_RedactsJSONAndHeaders(t *testing.T) {
+	e, logs := dumpServer(&config.BodyDumpConfig{
+		Routes:        []string{"post /login"},
+		RedactPaths:   []string{"user.email", "items.*.card"},
+		RedactHeaders: []string{"x-tenant-id"},
+	})
+	dump(e, http.MethodPost, "/login?next=%2Fhome&token=t1&API_TOKEN=t2", echo.MIMEApplicationJSON,
+		`{"email":"a@b.c","password":"p@ss","profile":{"refresh_token":"rt"},"items":[{"card":"4111","n":1.50}]}`)
+
+	if logs.Len() != 1 {
+		t.Fatalf("命中路由应记录 1 条，得到 %d", logs.Len())
+	}
+	fields := logs.All()[0].ContextMap()
+	wantReq := `{"email":"a@b.c","items":[{"card":"[REDACTED]","n":1.50}],"password":"[REDACTED]","profile":{"refresh_token":"[REDACTED]"}}`
+	if fields["request_body"] != wantReq {
+		t.Errorf("请求 body 脱敏不正确，得到 %v", fields["request_body"])
+	}
+	wantRes := `{"access_token":"[REDACTED]","token_type":"Bearer","user":{"email":"[REDACTED]","id":7}}`
+	if fields["response_body"] != wantRes {
+		t.Errorf("响应 body 脱敏不正确，得到 %v", fields["response_body"])
+	}
+	if want := "/login?API_TOKEN=[REDACTED]&next=%2Fhome&token=[REDACTED]"; fields["uri"] != want {
+		t.Errorf("查询参数应脱敏，期望 %s，得到 %v", want, fields["uri"])
+	}
+	headers, _ := fields["request_headers"].(map[string]any)
+	if headers["Authorization"] != redacted || headers["X-Tenant-Id"] != redacted || headers["Content-Type"] != echo.MIMEApplicationJSON {
+		t.Errorf("请求头脱敏不正确，得到 %v", headers)
+	}
+}
+
+func TestBodyDump_RedactsKeysAndSecrets(t *testing.T) {
+	e, logs := dumpServer(&config.BodyDumpConfig{Routes: []string{"post /api/keys"}})
+	dump(e, http.MethodPost, "/api/keys?api_key=k1", echo.MIMEApplicationJSON,
+		`{"name":"ci","webhook":{"signing_key":"sk","client_secret":"cs"},"scopes":["links:write"]}`)
+
+	if logs.Len() != 1 {
+		t.Fatalf("命中路由应记录 1 条，得到 %d", logs.Len())
+	}
+	fields := logs.All()[0].ContextMap()
+	wantReq := `{"name":"ci","scopes":["links:write"],"webhook":{"client_secret":"[REDACTED]","signing_key":"[REDACTED]"}}`
+	if fields["request_body"] != wantReq {
+		t.Errorf("请求 body 中的 *_key、*_secret 应脱敏，得到 %v", fields["request_body"])
+	}
+	wantRes := `{"id":3,"key":"[REDACTED]","name":"ci","prefix":"ek_1a2b","scopes":["links:write"]}`
+	if fields["response_body"] != wantRes {
+		t.Errorf("创建 API key 的响应中 key 应脱敏，得到 %v", fields["response_body"])
+	}
+	if want := "/api/keys?api_key=[REDACTED]"; fields["uri"] != want {
+		t.Errorf("查询参数 api_key 应脱敏，期望 %s，得到 %v", want, fields["uri"])
+	}
+}
+
+func TestBodyDump_RouteAndSampling(t *testing.T) {
+	e, logs := dumpServer(&config.BodyDumpConfig{Routes: []string{"/links/:code"}})
+	dump(e, http.MethodPost, "/login", echo.MIMEApplicationJSON, `{}`)
+	if logs.Len() != 0 {
+		t.Fatalf("未命中路由且未抽样时不应记录，得到 %v", logs.All())
+	}
+	// 错误响应由错误处理器写出，同样应被抓取
+	dump(e, http.MethodGet, "/links/abc", "", "")
+	if logs.Len() != 1 {
+		t.Fatalf("不带方法的路由应匹配任意方法，得到 %d", logs.Len())
+	}
+	fields := logs.All()[0].ContextMap()
+	if fields["status"] != int64(http.StatusNotFound) || !strings.Contains(fields["response_body"].(string), "link not found") {
+		t.Errorf("应记录错误响应，得到 %v", fields)
+	}
+
+	e, logs = dumpServer(&config.BodyDumpConfig{SampleRate: 100})
+	dump(e, http.MethodPost, "/login", echo.MIMEApplicationJSON, `{}`)
+	if logs.Len() != 1 {
+		t.Errorf("抽样 100%% 时应记录所有请求，得到 %d", logs.Len())
+	}
+	e, logs = dumpServer(nil)
+	dump(e, http.MethodPost, "/login", echo.MIMEApplicationJSON, `{}`)
+	if logs.Len() != 0 {
+		t.Errorf("未配置时不应记录，得到 %d", logs.Len())
+	}
+}
+
+func TestBodyDump_SkippedRequestsLeaveErrorsToOuterHandler(t *testing.T) {
+	d := newBodyDumper(&config.BodyDumpConfig{Routes: []string{"/other"}})
+	e := echo.New()
+	var committed bool
+	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			err := next(c)
+			if r, _ := echo.UnwrapResponse(c.Response()); r != nil {
+				committed = r.Committed
+			}
+			return err
+		}
+	})
+	e.Use(d.Middleware())
+	e.GET("/links/:code", func(c *echo.Context) error {
+		return echo.NewHTTPError(http.StatusNotFound, "link not found")
+	})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/links/abc", nil))
+	if committed {
+		t.Error("未抓取的请求不应在中间件内提前写出错误响应")
+	}
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("错误仍应由外层处理，得到 %d", rec.Code)
+	}
+}
+
+func TestBodyDump_SkipsBinaryAndTruncates(t *testing.T) {
+	e, logs := dumpServer(&config.BodyDumpConfig{SampleRate: 100, MaxBytes: 8})
+	dump(e, http.MethodPost, "/upload", echo.MIMEApplicationForm, "name=report&password=p%40ss&note=a+b")
+	dump(e, http.MethodPost, "/login", echo.MIMEApplicationJSON, `{"a":`)
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("期望 2 条，得到 %d", len(entries))
+	}
+	upload := entries[0].ContextMap()
+	if upload["request_body"] != "name=rep" || upload["request_body_truncated"] != true {
+		t.Errorf("应在脱敏后截断，得到 %v", upload)
+	}
+	if _, ok := upload["response_body"]; ok || !strings.Contains(upload["response_body_skipped"].(string), "image/png") {
+		t.Errorf("二进制响应只应记录原因，得到 %v", upload)
+	}
+	if login := entries[1].ContextMap(); login["request_body_skipped"] == nil || login["request_body"] != nil {
+		t.Errorf("无法解析的 JSON 无法可靠脱敏，不应记录内容，得到 %v", login)
+	}
+
+	rules := &bodyDumpRules{maxBytes: 1 << 10}
+	out, err := rules.redactForm([]byte("name=report&password=p%40ss&note=a+b"))
+	if err != nil || string(out) != "name=report&note=a+b&password=[REDACTED]" {
+		t.Errorf("表单脱敏不正确，得到 %s, %v", out, err)
+	}
+}
+
+func TestNewBodyLogger_SeparateStream(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.LogConfig{
+		Level:    "error",
+		Filename: filepath.Join(dir, "app.log"),
+		BodyDump: &config.BodyDumpConfig{Sink: &config.LogSinkConfig{Type: "file", Filename: filepath.Join(dir, "body.log")}},
+	}
+	logger, err := newBodyLogger(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("body dump")
+	_ = logger.Sync()
+	if lines := readLines(t, filepath.Join(dir, "body.log")); len(lines) != 1 || !strings.Contains(lines[0], `"logger":"bodydump"`) {
+		t.Errorf("应写入独立文件且不受全局级别控制，得到 %v", lines)
+	}
+	if _, err := os.Stat(cfg.Filename); !os.IsNotExist(err) {
+		t.Errorf("不应写入主日志文件: %v", err)
+	}
+}
@@ -3,49 +3,87 @@ package utils
 import (
 	"context"
 	"echotest/config"
+	"echotest/pkg/loadshed"
+	"echotest/pkg/problem"
 	"echotest/pkg/ratelimit"
+	"echotest/pkg/requestid"
+	"errors"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strings"
 	"syscall"
 
 	"github.com/labstack/echo-contrib/echoprometheus"
 	"github.com/labstack/echo/v5"
 	"github.com/labstack/echo/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-func Init(cfg *config.Config) (*echo.Echo, context.Context, context.CancelFunc) {
+// Init 创建 Echo 并挂载中间件；body limit、限流、CORS 来源、日志级别与 body 抓取规则订阅 mgr 的变更，热加载后立即生效。
+// 同时返回全局按 IP 限流器，调用方可为其设置共享后端（SetBackend）
+func Init(mgr *config.Manager) (*echo.Echo, *ratelimit.Limiter, context.Context, context.CancelFunc) {
+	cfg := mgr.Current()
 	ec := echo.New()
-	InitLogger()
+	if err := InitLogger(cfg.Log); err != nil {
+		SlogLogger.Error("some log sinks are unavailable", "error", err)
+	}
 	ec.Logger = SlogLogger
+	if err := LogLevel.Apply(cfg.Log); err != nil {
+		ec.Logger.Error("invalid log level, fallback to info", "error", err)
+	}
 	// 最先挂载：为每个请求生成或透传 X-Request-Id，便于按 ID 查整条链路日志
 	ec.Use(middleware.RequestID())
+	// 请求级 logger：c.Logger() 与 slog.InfoContext(ctx, ...) 自动带上 request_id、route、user_id、trace_id
+	ec.Use(requestid.Middleware())
 	ec.Use(middleware.Recover())
 	ec.Use(RequestLoggerWithZap())
 	ec.Validator = NewCustomValidator()
+	// 所有错误统一输出为 application/problem+json，领域错误由各 handler 包注册到 problem.Default
+	ec.HTTPErrorHandler = problem.HTTPErrorHandler(problem.Default)
+	origins := newDynamicOrigins(cfg.Server.CORSAllowOrigins)
 	ec.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{"*"},
-		AllowMethods: []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete},
+		UnsafeAllowOriginFunc: origins.AllowOrigin,
+		AllowMethods:          []string{http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete},
+		// 浏览器端脚本需要读取限流头来退避，以及配额即将用完的提醒
+		ExposeHeaders: []string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After", "X-Quota-Warning"},
 	}))
-	bodyLimit := int64(cfg.Server.BodyLimit)
-	rateLimitRate, rateLimitBurst := cfg.Server.RateLimitRate, cfg.Server.RateLimitBurst
-	if cfg != nil && cfg.Server != nil {
-		if cfg.Server.BodyLimit > 0 {
-			bodyLimit = cfg.Server.BodyLimit
-		}
-		if cfg.Server.RateLimitRate > 0 {
-			rateLimitRate = cfg.Server.RateLimitRate
-		}
-		if cfg.Server.RateLimitBurst > 0 {
-			rateLimitBurst = cfg.Server.RateLimitBurst
-		}
+	bodyLimit, rateLimitRate, rateLimitBurst := serverLimits(cfg.Server)
+	dynBodyLimit := newDynamicBodyLimit(bodyLimit)
+	limiter := ratelimit.New(ratelimit.Config{
+		Rate:    rateLimitRate,
+		Burst:   rateLimitBurst,
+		MaxKeys: cfg.Server.RateLimitMaxKeys,
+		IdleTTL: cfg.Server.RateLimitIdleTTL,
+		Prefix:  "global:",
+	})
+	registerCollector(ec, limiter.Collector("global"))
+	ec.Use(dynBodyLimit.Middleware())
+	ec.Use(limiter.Middleware())
+	// 过载保护放在全局限流之后：被限流的 429 不占并发名额，也不计入延迟样本；
+	// 仍在日志之后，被丢弃的请求也会记录
+	if cfg.LoadShed != nil {
+		shed := newLoadShed(cfg.LoadShed)
+		registerCollector(ec, shed.Collector())
+		ec.Use(shed.Middleware(loadShedPriority(cfg.LoadShed)))
 	}
-	ec.Use(middleware.BodyLimit(bodyLimit))
-	ec.Use(ratelimit.New(ratelimit.Config{Rate: rateLimitRate, Burst: rateLimitBurst}).Middleware())
+	dumper := newBodyDumper(bodyDumpConfig(cfg.Log))
+	subscribeReload(ec, mgr, dynBodyLimit, origins, limiter, dumper)
 	ec.Use(middleware.Gzip())
+	// 按路由或抽样记录请求/响应 body 到 BodyLog；在 Gzip 之后才能拿到压缩前的响应
+	ec.Use(dumper.Middleware())
 	ec.Use(middleware.Secure())
-	// 注意：CSRF 在没有配置的情况下在 v5 中可能也需要具体配置，这里保持默认
-	ec.Use(middleware.CSRF())
+	// 注意：CSRF 在没有配置的情况下在 v5 中可能也需要具体配置
+	// 以下请求直接跳过：携带 Authorization 或 X-API-Key 头的 API 请求不依赖 cookie；
+	// JSON 请求体在跨域时必须先经过 CORS 预检，且凭证都在请求体中，同样不存在 CSRF 风险
+	ec.Use(middleware.CSRFWithConfig(middleware.CSRFConfig{
+		Skipper: func(c *echo.Context) bool {
+			req := c.Request()
+			return req.Header.Get(echo.HeaderAuthorization) != "" || req.Header.Get("X-API-Key") != "" ||
+				strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON)
+		},
+	}))
 	ec.Use(echoprometheus.NewMiddleware("echotest"))
 	// 指标监控服务
 	go func() {
@@ -58,5 +96,45 @@ func Init(cfg *config.Config) (*echo.Echo, context.Context, context.CancelFunc)
 	}()
 	// 关键修改：不要在这里 defer cancel()
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	return ec, ctx, cancel
+	// 定期回收限流器中的空闲 key，随应用退出停止
+	go limiter.Run(ctx)
+	go watchLogLevelSignal(ctx, ec.Logger)
+	return ec, limiter, ctx, cancel
+}
+
+func newLoadShed(c *config.LoadShedConfig) *loadshed.Limiter {
+	return loadshed.New(loadshed.Config{
+		InitialLimit: c.InitialLimit,
+		MinLimit:     c.MinLimit,
+		MaxLimit:     c.MaxLimit,
+		Tolerance:    c.Tolerance,
+		QueueTimeout: c.QueueTimeout,
+		MaxQueue:     c.MaxQueue,
+		Reserve:      c.Reserve,
+	})
+}
+
+// loadShedPriority 按路由路径划分优先级
+func loadShedPriority(c *config.LoadShedConfig) func(*echo.Context) loadshed.Priority {
+	return func(ctx *echo.Context) loadshed.Priority {
+		path := ctx.Path()
+		switch {
+		case slices.Contains(c.CriticalPaths, path):
+			return loadshed.Critical
+		case slices.Contains(c.HighPaths, path):
+			return loadshed.High
+		default:
+			return loadshed.Normal
+		}
+	}
+}
+
+// registerCollector 注册到默认 registry（/metrics 使用），重复注册时忽略
+func registerCollector(ec *echo.Echo, c prometheus.Collector) {
+	if err := prometheus.Register(c); err != nil {
+		var already prometheus.AlreadyRegisteredError
+		if !errors.As(err, &already) {
+			ec.Logger.Error("failed to register metrics collector", "error", err)
+		}
+	}
 }
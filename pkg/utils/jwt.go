@@ -1,30 +1,68 @@
 package utils
 
 import (
+	"context"
+	"crypto/rand"
 	"echotest/config"
+	"echotest/pkg/jwks"
+	"encoding/hex"
 	"fmt"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo-jwt/v5"
 	"github.com/labstack/echo/v5"
+	"net/http"
+	"path/filepath"
 	"time"
 )
 
+// Denylist 按 jti 判断 access token 是否已被吊销（如用户已登出）
+type Denylist interface {
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+// NewKeySet 按配置创建 JWT 密钥集合：配置了 keys 时从 PEM 文件加载非对称密钥，否则使用 HS256 secret。
+// baseDir 为 PEM 相对路径的基准目录，一般是配置文件所在目录。
+func NewKeySet(cfg config.JWTConfig, baseDir string) (*jwks.Set, error) {
+	if len(cfg.Keys) == 0 {
+		return jwks.NewHMAC([]byte(cfg.Secret)), nil
+	}
+	specs := make([]jwks.Spec, 0, len(cfg.Keys))
+	for _, k := range cfg.Keys {
+		specs = append(specs, jwks.Spec{
+			ID:             k.ID,
+			Algorithm:      k.Algorithm,
+			PrivateKeyFile: resolvePath(baseDir, k.PrivateKeyFile),
+			PublicKeyFile:  resolvePath(baseDir, k.PublicKeyFile),
+		})
+	}
+	return jwks.Load(specs, cfg.SigningKey)
+}
+
+func resolvePath(baseDir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(baseDir, path)
+}
+
 // JWT 返回基于 echo-jwt 的 JWT 认证中间件，兼容 Echo v5。
-// secret 为签名密钥，与 pkg/jwt 生成 token 时使用的密钥一致（如 []byte(cfg.JWT.Secret)）。
+// keys 为签发 token 时使用的同一个密钥集合，按 token 头中的 kid 选择校验密钥。
+// denylist 不为 nil 时，jti 已被吊销的 token 返回 401。
 // 校验通过后会在 context 中设置 "user"（*jwt.Token）、"email"、"userID"，便于与原有逻辑兼容。
-func JWT(secret []byte) echo.MiddlewareFunc {
+func JWT(keys *jwks.Set, denylist Denylist) echo.MiddlewareFunc {
 	return echojwt.WithConfig(echojwt.Config{
-		SigningKey:    secret,
-		SigningMethod: "HS256",
-		TokenLookup:   "header:Authorization:Bearer ",
-		ContextKey:    "user",
+		// KeyFunc 按 kid 取密钥并校验算法与密钥匹配
+		KeyFunc:     keys.Keyfunc,
+		TokenLookup: "header:Authorization:Bearer ",
+		ContextKey:  "user",
 
 		// 使用与 pkg/jwt 相同的 UserCliams，保证生成的 token 可被正确解析
 		NewClaimsFunc: func(c *echo.Context) jwt.Claims {
 			return &UserCliams{}
 		},
 
-		// 校验成功后设置 email、userID，兼容原有 c.Get("email") / c.Get("userID") 用法
+		// 校验成功后设置 email、userID，兼容原有 c.Get("email") / c.Get("userID") 用法；
+		// 同时设置 role、scopes 供 RequireRole、RequireScopes 等授权中间件使用
 		SuccessHandler: func(c *echo.Context) error {
 			token, ok := c.Get("user").(*jwt.Token)
 			if !ok {
@@ -34,49 +72,73 @@ func JWT(secret []byte) echo.MiddlewareFunc {
 			if !ok {
 				return nil
 			}
-			c.Set("email", claims.Email)
-			c.Set("userID", claims.UserID)
+			if denylist != nil && claims.ID != "" {
+				revoked, err := denylist.IsRevoked(c.Request().Context(), claims.ID)
+				if err != nil {
+					return err
+				}
+				if revoked {
+					return echo.NewHTTPError(http.StatusUnauthorized, "token has been revoked")
+				}
+			}
+			setPrincipal(c, Principal{UserID: claims.UserID, Email: claims.Email, Role: claims.Role, Plan: claims.Plan, Scopes: claims.Scopes})
 			return nil
 		},
 	})
 }
 
 type JWTS struct {
-	secret   []byte
+	keys     *jwks.Set
 	duration time.Duration
 }
 
-func NewJWT(cfg config.JWTConfig) *JWTS {
+// NewJWT 创建 access token 签发器，duration 为 token 有效期
+func NewJWT(keys *jwks.Set, duration time.Duration) *JWTS {
 	return &JWTS{
-		secret:   []byte(cfg.Secret),
-		duration: cfg.Duration,
+		keys:     keys,
+		duration: duration,
 	}
 }
 
 type UserCliams struct {
-	Email  string `json:"email"`
-	UserID int    `json:"user_id"`
+	Email  string   `json:"email"`
+	UserID int      `json:"user_id"`
+	Role   string   `json:"role,omitempty"`
+	Plan   string   `json:"plan,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func (j *JWTS) Generate(email string, useID int) (string, error) {
+// TTL 返回 access token 的有效期
+func (j *JWTS) TTL() time.Duration {
+	return j.duration
+}
+
+// Generate 签发 access token，每个 token 带唯一的 jti，用于登出后吊销；
+// role、scopes 供授权中间件判断，plan 供限流策略选择额度
+func (j *JWTS) Generate(p Principal) (string, error) {
+	jti, err := newJTI()
+	if err != nil {
+		return "", err
+	}
 	claims := UserCliams{
-		Email:  email,
-		UserID: useID,
+		Email:  p.Email,
+		UserID: p.UserID,
+		Role:   p.Role,
+		Plan:   p.Plan,
+		Scopes: p.Scopes,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.duration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(j.secret)
+	return j.keys.Sign(claims)
 }
 
 func (j *JWTS) ParseToken(tokenString string) (*UserCliams, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &UserCliams{}, func(t *jwt.Token) (interface{}, error) {
-		return j.secret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &UserCliams{}, j.keys.Keyfunc, jwt.WithValidMethods(j.keys.Methods()))
 	if err != nil {
 		return nil, err
 	}
@@ -87,3 +149,11 @@ func (j *JWTS) ParseToken(tokenString string) (*UserCliams, error) {
 
 	return nil, fmt.Errorf("failed to parseToken")
 }
+
+func newJTI() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
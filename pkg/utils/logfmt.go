@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var logfmtPool = buffer.NewPool()
+
+// logfmtEncoder 以 logfmt（空格分隔的 key=value）输出日志。字段先交给 JSON encoder 编码，
+// 再按原有顺序改写：嵌套对象展开为 a.b=value，数组保留为 JSON 文本，含空格等字符的值加引号
+type logfmtEncoder struct {
+	zapcore.Encoder
+}
+
+func newLogfmtEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return logfmtEncoder{zapcore.NewJSONEncoder(cfg)}
+}
+
+func (e logfmtEncoder) Clone() zapcore.Encoder {
+	return logfmtEncoder{e.Encoder.Clone()}
+}
+
+func (e logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	line, err := e.Encoder.EncodeEntry(ent, fields)
+	if err != nil {
+		return nil, err
+	}
+	defer line.Free()
+	out := logfmtPool.Get()
+	if err := writeLogfmt(out, bytes.TrimSpace(line.Bytes()), ""); err != nil {
+		out.Free()
+		return nil, err
+	}
+	out.AppendString(zapcore.DefaultLineEnding)
+	return out, nil
+}
+
+// writeLogfmt 把 JSON 对象按 key 顺序写为 logfmt，prefix 为嵌套对象的 key 前缀
+func writeLogfmt(out *buffer.Buffer, object []byte, prefix string) error {
+	dec := json.NewDecoder(bytes.NewReader(object))
+	dec.UseNumber()
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key := prefix + tok.(string)
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		switch raw[0] {
+		case '{':
+			if err := writeLogfmt(out, raw, key+"."); err != nil {
+				return err
+			}
+			continue
+		case '"':
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return err
+			}
+			appendLogfmtPair(out, key, s)
+		case '[':
+			var compact bytes.Buffer
+			if err := json.Compact(&compact, raw); err != nil {
+				return err
+			}
+			appendLogfmtPair(out, key, compact.String())
+		default:
+			// 数字、true/false 与 null 原样输出
+			appendLogfmtPair(out, key, string(raw))
+		}
+	}
+	return nil
+}
+
+func appendLogfmtPair(out *buffer.Buffer, key, value string) {
+	if out.Len() > 0 {
+		out.AppendByte(' ')
+	}
+	out.AppendString(key)
+	out.AppendByte('=')
+	if needsQuote(value) {
+		out.AppendString(strconv.Quote(value))
+		return
+	}
+	out.AppendString(value)
+}
+
+func needsQuote(s string) bool {
+	if s == "" {
+		return true
+	}
+	return strings.ContainsFunc(s, func(r rune) bool {
+		return r == ' ' || r == '=' || r == '"' || !unicode.IsPrint(r)
+	})
+}
@@ -1,11 +1,18 @@
 package utils
 
 import (
+	"cmp"
 	"context"
+	"echotest/config"
+	"echotest/pkg/requestid"
 	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
 	"time"
 
 	"github.com/labstack/echo/v5"
@@ -113,109 +120,307 @@ var (
 	SlogLogger *slog.Logger
 )
 
-func InitLogger() {
-	// 0. 确保日志目录存在（lumberjack 不会自动创建目录，缺失会导致只写控制台）
-	logPath := "./logs/app.log"
-	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
-		// 无法创建目录时退化为仅控制台，避免启动失败
-		encoderConfig := zap.NewProductionEncoderConfig()
-		encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
-		core := zapcore.NewCore(zapcore.NewConsoleEncoder(encoderConfig), zapcore.AddSync(os.Stdout), zap.InfoLevel)
-		Log = zap.New(core, zap.AddCaller())
-		SlogLogger = slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelInfo}))
-		return
+const (
+	defaultLogFile = "./logs/app.log"
+	defaultService = "echotest"
+)
+
+// InitLogger 按 log 配置创建 Log 与 SlogLogger，cfg 为 nil 时使用默认配置。
+// 无法打开的 sink 会被跳过并返回错误，全部不可用时退化为只输出到 stdout，不影响启动
+func InitLogger(cfg *config.LogConfig) error {
+	if cfg == nil {
+		cfg = &config.LogConfig{}
 	}
-	// 1. 定义日志文件的滚动规则
-	w := zapcore.AddSync(&lumberjack.Logger{
-		Filename:   logPath,
-		MaxSize:    10,   // 每个文件最大 10MB
-		MaxBackups: 5,    // 保留最近 5 个旧文件
-		MaxAge:     30,   // 保留最近 30 天的日志
-		Compress:   true, // 是否压缩旧文件
-	})
+	logger, err := newLogger(cfg, LogLevel)
+	// 外层按 logger 名称应用 log.overrides
+	Log = logger.WithOptions(zap.WrapCore(LogLevel.Core))
+	// 供 Echo ec.Logger 使用；与 zap core 共用 LogLevel，运行时调整同时生效。
+	// 从 ctx 提取 requestid.Middleware 写入的请求字段，并设为 slog 默认 logger，slog.InfoContext(ctx, ...) 同样带上这些字段
+	SlogLogger = newSlogLogger(Log, LogLevel)
+	slog.SetDefault(SlogLogger)
+	bodyLog, bodyErr := newBodyLogger(cfg)
+	BodyLog = bodyLog
+	return errors.Join(err, bodyErr)
+}
 
-	// 2. 设置编码配置（JSON 格式适合生产，Console 格式适合开发）
+// NamedLogger 返回子系统的 slog logger，对应 zap logger 名为 name（zap.Logger.Named），
+// log.overrides 据此单独调整其级别。目前使用的名称有 database、ratelimit、analytics
+func NamedLogger(name string) *slog.Logger {
+	return newSlogLogger(Log.Named(name), LogLevel)
+}
+
+func newSlogLogger(logger *zap.Logger, level slog.Leveler) *slog.Logger {
+	return slog.New(NewZapHandler(logger, level).WithContextFields(requestid.ContextFields))
+}
+
+// newLogger 为每个 sink 创建一个 core 并合并，所有日志都带上 service 字段
+func newLogger(cfg *config.LogConfig, level zapcore.LevelEnabler) (*zap.Logger, error) {
+	sinks := cfg.Sinks
+	if len(sinks) == 0 {
+		sinks = []config.LogSinkConfig{
+			{Type: "stdout", Format: cmp.Or(cfg.Format, "console")},
+			{Type: "file"},
+		}
+	}
+	var (
+		cores []zapcore.Core
+		errs  []error
+	)
+	for _, s := range sinks {
+		core, err := newSinkCore(cfg, s, level)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("log sink %s: %w", s.Type, err))
+			continue
+		}
+		cores = append(cores, core)
+	}
+	if len(cores) == 0 {
+		cores = append(cores, zapcore.NewCore(newEncoder("console"), zapcore.Lock(os.Stdout), level))
+	}
+	logger := zap.New(zapcore.NewTee(cores...), zap.AddCaller()).With(serviceField(cfg))
+	return logger, errors.Join(errs...)
+}
+
+func newSinkCore(cfg *config.LogConfig, s config.LogSinkConfig, level zapcore.LevelEnabler) (zapcore.Core, error) {
+	if s.Level != "" {
+		min, err := zapcore.ParseLevel(s.Level)
+		if err != nil {
+			return nil, err
+		}
+		level = sinkLevel{global: level, min: min}
+	}
+	var w zapcore.WriteSyncer
+	switch s.Type {
+	case "stdout":
+		w = zapcore.Lock(os.Stdout)
+	case "stderr":
+		w = zapcore.Lock(os.Stderr)
+	case "file":
+		fw, err := fileSink(cfg, s)
+		if err != nil {
+			return nil, err
+		}
+		w = fw
+	default:
+		return nil, fmt.Errorf("unknown sink type %q", s.Type)
+	}
+	return zapcore.NewCore(newEncoder(cmp.Or(s.Format, cfg.Format, "json")), w, level), nil
+}
+
+// fileSink 按大小滚动的日志文件，sink 未设置的参数取 log 段的值
+func fileSink(cfg *config.LogConfig, s config.LogSinkConfig) (zapcore.WriteSyncer, error) {
+	name := cmp.Or(s.Filename, cfg.Filename, defaultLogFile)
+	// lumberjack 不会自动创建目录，缺失会导致写入失败
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+	compress := cfg.Compress
+	if s.Compress != nil {
+		compress = *s.Compress
+	}
+	return zapcore.AddSync(&lumberjack.Logger{
+		Filename:   name,
+		MaxSize:    cmp.Or(s.MaxSize, cfg.MaxSize, 10),
+		MaxBackups: cmp.Or(s.MaxBackups, cfg.MaxBackups, 5),
+		MaxAge:     cmp.Or(s.MaxAge, cfg.MaxAge, 30),
+		Compress:   compress,
+	}), nil
+}
+
+// newEncoder 按格式创建 encoder：json 适合采集，console 适合开发，logfmt 便于 grep 与部分日志平台解析
+func newEncoder(format string) zapcore.Encoder {
 	encoderConfig := zap.NewProductionEncoderConfig()
 	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder // 使用可读的时间格式
+	switch format {
+	case "console":
+		return zapcore.NewConsoleEncoder(encoderConfig)
+	case "logfmt":
+		return newLogfmtEncoder(encoderConfig)
+	default:
+		return zapcore.NewJSONEncoder(encoderConfig)
+	}
+}
 
-	// 3. 创建 Core
-	// 如果你想同时输出到控制台和文件，可以使用 NewTee
-	core := zapcore.NewTee(
-		zapcore.NewCore(zapcore.NewJSONEncoder(encoderConfig), w, zap.InfoLevel),
-		zapcore.NewCore(zapcore.NewConsoleEncoder(encoderConfig), zapcore.AddSync(os.Stdout), zap.InfoLevel),
-	)
+// sinkLevel 在全局级别之上叠加 sink 自身的最低级别
+type sinkLevel struct {
+	global zapcore.LevelEnabler
+	min    zapcore.Level
+}
 
-	// 4. 生成 Logger
-	Log = zap.New(core, zap.AddCaller())
+func (l sinkLevel) Enabled(lvl zapcore.Level) bool {
+	return lvl >= l.min && l.global.Enabled(lvl)
+}
 
-	// 5. 初始化 SlogLogger，供 Echo ec.Logger 使用；否则 ec.Logger 为 nil，框架会用默认 logger 只打控制台
-	SlogLogger = slog.New(NewZapHandler(Log, slog.LevelInfo))
+// serviceField 附加到每条日志的静态字段：服务名、版本与主机名
+func serviceField(cfg *config.LogConfig) zap.Field {
+	host, _ := os.Hostname()
+	return zap.Dict("service",
+		zap.String("name", cmp.Or(cfg.Service, defaultService)),
+		zap.String("version", cmp.Or(cfg.Version, buildVersion())),
+		zap.String("host", host),
+	)
+}
+
+// buildVersion 构建信息中的模块版本，go build 时为 (devel) 或带 VCS 信息的伪版本
+func buildVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "unknown"
 }
 
-// ZapHandler 实现 slog.Handler 接口，将 slog 调用转换为 zap
+// ZapHandler 实现 slog.Handler，把 slog 记录写入 zap：WithGroup 的组在有属性时才成为 zap namespace，
+// slog.Group 转为嵌套对象，LogValuer 先求值，caller 取自记录的调用位置（slog.Record.PC）
 type ZapHandler struct {
 	logger *zap.Logger
-	level  slog.Level
+	level  slog.Leveler
+	// fields WithAttrs 添加的属性及其所在组的 namespace，在 Handle 时写在 contextFields 之后；
+	// 不预先写入 logger，否则之后的 contextFields 会落在这些 namespace 内
+	fields []zap.Field
+	// groups 已通过 WithGroup 打开但还没有属性的组，有属性时才写入 namespace，空组不输出
+	groups []string
+	// contextFields 从 Handle 的 ctx 中提取附加字段
+	contextFields func(ctx context.Context) []zap.Field
 }
 
-// NewZapHandler 创建新的 ZapHandler
-func NewZapHandler(logger *zap.Logger, level slog.Level) *ZapHandler {
+// NewZapHandler 创建新的 ZapHandler；level 可以是固定的 slog.Level，也可以是 LogLevel 这样可在运行时调整的级别。
+// caller 由 slog 记录提供，logger 自身的 AddCaller 设置不影响结果
+func NewZapHandler(logger *zap.Logger, level slog.Leveler) *ZapHandler {
 	return &ZapHandler{
-		logger: logger,
+		logger: logger.WithOptions(zap.WithCaller(false)),
 		level:  level,
 	}
 }
 
-// Enabled 检查指定级别是否启用
+// WithContextFields 返回从 ctx 提取附加字段的 Handler，字段总是写在顶层，不受 WithGroup 影响
+func (h *ZapHandler) WithContextFields(fn func(ctx context.Context) []zap.Field) *ZapHandler {
+	h2 := *h
+	h2.contextFields = fn
+	return &h2
+}
+
+// Enabled 检查指定级别是否启用：同时满足 handler 自身级别与 zap core 的级别
 func (h *ZapHandler) Enabled(ctx context.Context, level slog.Level) bool {
-	return level >= h.level
+	if level < h.level.Level() {
+		return false
+	}
+	return h.logger.Core().Enabled(zapLevel(level))
 }
 
 // Handle 处理日志记录
 func (h *ZapHandler) Handle(ctx context.Context, record slog.Record) error {
-	fields := make([]zap.Field, 0, record.NumAttrs())
+	ce := h.logger.Check(zapLevel(record.Level), record.Message)
+	if ce == nil {
+		return nil
+	}
+	// 零值时间不输出，与 slog 的约定一致
+	ce.Time = record.Time
+	if record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		ce.Caller = zapcore.NewEntryCaller(frame.PC, frame.File, frame.Line, true)
+		ce.Caller.Function = frame.Function
+	}
+
+	var fields []zap.Field
+	if h.contextFields != nil && ctx != nil {
+		fields = h.contextFields(ctx)
+	}
+	fields = append(fields, h.fields...)
+	attrs := make([]zap.Field, 0, record.NumAttrs())
 	record.Attrs(func(a slog.Attr) bool {
-		fields = append(fields, zap.Any(a.Key, a.Value.Any()))
+		attrs = appendAttr(attrs, a)
 		return true
 	})
-
-	// 添加时间戳
-	fields = append(fields, zap.Time("time", record.Time))
-
-	// 根据级别记录日志
-	switch record.Level {
-	case slog.LevelDebug:
-		h.logger.Debug(record.Message, fields...)
-	case slog.LevelInfo:
-		h.logger.Info(record.Message, fields...)
-	case slog.LevelWarn:
-		h.logger.Warn(record.Message, fields...)
-	case slog.LevelError:
-		h.logger.Error(record.Message, fields...)
-	default:
-		h.logger.Info(record.Message, fields...)
+	if len(attrs) > 0 {
+		fields = append(fields, namespaces(h.groups)...)
+		fields = append(fields, attrs...)
 	}
-
+	ce.Write(fields...)
 	return nil
 }
 
 // WithAttrs 返回带有附加属性的新 Handler
 func (h *ZapHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
-	fields := make([]zap.Field, len(attrs))
-	for i, attr := range attrs {
-		fields[i] = zap.Any(attr.Key, attr.Value.Any())
+	var fields []zap.Field
+	for _, a := range attrs {
+		fields = appendAttr(fields, a)
 	}
-	return &ZapHandler{
-		logger: h.logger.With(fields...),
-		level:  h.level,
+	if len(fields) == 0 {
+		return h
 	}
+	h2 := *h
+	h2.fields = append(append(slices.Clip(h.fields), namespaces(h.groups)...), fields...)
+	h2.groups = nil
+	return &h2
 }
 
-// WithGroup 返回带有组名的新 Handler
+// WithGroup 返回带有组名的新 Handler，之后的属性都写在该组内
 func (h *ZapHandler) WithGroup(name string) slog.Handler {
-	return &ZapHandler{
-		logger: h.logger.Named(name),
-		level:  h.level,
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(slices.Clip(h.groups), name)
+	return &h2
+}
+
+func namespaces(groups []string) []zap.Field {
+	fields := make([]zap.Field, len(groups))
+	for i, g := range groups {
+		fields[i] = zap.Namespace(g)
+	}
+	return fields
+}
+
+// appendAttr 把 slog 属性转为 zap 字段：空属性与空组忽略，key 为空的组展开到当前层级
+func appendAttr(fields []zap.Field, a slog.Attr) []zap.Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		var inner []zap.Field
+		for _, ga := range a.Value.Group() {
+			inner = appendAttr(inner, ga)
+		}
+		if len(inner) == 0 {
+			return fields
+		}
+		if a.Key == "" {
+			return append(fields, inner...)
+		}
+		return append(fields, zap.Dict(a.Key, inner...))
+	case slog.KindString:
+		return append(fields, zap.String(a.Key, a.Value.String()))
+	case slog.KindInt64:
+		return append(fields, zap.Int64(a.Key, a.Value.Int64()))
+	case slog.KindUint64:
+		return append(fields, zap.Uint64(a.Key, a.Value.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, zap.Float64(a.Key, a.Value.Float64()))
+	case slog.KindBool:
+		return append(fields, zap.Bool(a.Key, a.Value.Bool()))
+	case slog.KindDuration:
+		return append(fields, zap.Duration(a.Key, a.Value.Duration()))
+	case slog.KindTime:
+		return append(fields, zap.Time(a.Key, a.Value.Time()))
+	default:
+		return append(fields, zap.Any(a.Key, a.Value.Any()))
+	}
+}
+
+// zapLevel 把 slog 级别映射到 zap，slog 的自定义级别归入不高于它的最近一级
+func zapLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level >= slog.LevelError:
+		return zapcore.ErrorLevel
+	case level >= slog.LevelWarn:
+		return zapcore.WarnLevel
+	case level >= slog.LevelInfo:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
 	}
 }
 
@@ -281,28 +486,30 @@ func RequestLoggerWithZap() echo.MiddlewareFunc {
 				zap.String("remote_ip", v.RemoteIP),
 			}
 
-			// 添加请求 ID（如果存在）
-			if v.RequestID != "" {
+			// 添加请求 ID、路由、用户与链路追踪 ID（如果存在）
+			if rf := requestid.ContextFields(c.Request().Context()); rf != nil {
+				fields = append(fields, rf...)
+			} else if v.RequestID != "" {
 				fields = append(fields, zap.String("request_id", v.RequestID))
 			}
 
-			// 根据状态码和错误选择日志级别
+			// 日志级别取决于最终状态码：handler 返回的 4xx 错误（如 429、404）只是客户端错误，
+			// 不应与 5xx 一样记为 ERROR
 			if v.Error != nil {
-				// 有错误时记录错误信息
 				fields = append(fields, zap.Error(v.Error))
+			}
+			switch {
+			case v.Status >= 500:
+				zapLogger.Error("Server error", fields...)
+			case v.Status >= 400:
+				zapLogger.Warn("Client error", fields...)
+			case v.Error != nil:
+				// 响应已提交后才返回的错误，状态码无法反映
 				zapLogger.Error("REQUEST_ERROR", fields...)
-			} else {
-				// 根据状态码选择级别
-				switch {
-				case v.Status >= 500:
-					zapLogger.Error("Server error", fields...)
-				case v.Status >= 400:
-					zapLogger.Warn("Client error", fields...)
-				case v.Status >= 300:
-					zapLogger.Info("Redirection", fields...)
-				default:
-					zapLogger.Info("Success", fields...)
-				}
+			case v.Status >= 300:
+				zapLogger.Info("Redirection", fields...)
+			default:
+				zapLogger.Info("Success", fields...)
 			}
 
 			return nil
@@ -0,0 +1,325 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"echotest/config"
+	"echotest/pkg/problem"
+	"echotest/pkg/requestid"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/slogtest"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+func TestNewLogger_SinksFormatsAndLevels(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.LogConfig{
+		Format:  "logfmt",
+		Service: "linksvc",
+		Version: "1.2.3",
+		Sinks: []config.LogSinkConfig{
+			{Type: "file", Filename: filepath.Join(dir, "all.log"), Format: "json"},
+			{Type: "file", Filename: filepath.Join(dir, "errors.log"), Level: "error"},
+		},
+	}
+	logger, err := newLogger(cfg, zap.NewAtomicLevelAt(zap.DebugLevel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("starting", zap.String("addr", ":8080"))
+	logger.Error("query failed", zap.String("sql", "select 1"), zap.Int("attempt", 2))
+	_ = logger.Sync()
+
+	all := readLines(t, filepath.Join(dir, "all.log"))
+	if len(all) != 2 {
+		t.Fatalf("json sink 应记录全部 2 条日志，得到 %v", all)
+	}
+	var entry struct {
+		Msg     string            `json:"msg"`
+		Service map[string]string `json:"service"`
+		Addr    string            `json:"addr"`
+	}
+	if err := json.Unmarshal([]byte(all[0]), &entry); err != nil {
+		t.Fatalf("json sink 应输出 JSON: %v, %s", err, all[0])
+	}
+	host, _ := os.Hostname()
+	if entry.Msg != "starting" || entry.Addr != ":8080" ||
+		entry.Service["name"] != "linksvc" || entry.Service["version"] != "1.2.3" || entry.Service["host"] != host {
+		t.Errorf("日志应带 service 静态字段，得到 %s", all[0])
+	}
+
+	errs := readLines(t, filepath.Join(dir, "errors.log"))
+	if len(errs) != 1 {
+		t.Fatalf("level=error 的 sink 只应记录 error，得到 %v", errs)
+	}
+	for _, want := range []string{"level=error", `msg="query failed"`, `sql="select 1"`, "attempt=2", "service.name=linksvc", "service.version=1.2.3"} {
+		if !strings.Contains(errs[0], want) {
+			t.Errorf("logfmt 输出缺少 %s: %s", want, errs[0])
+		}
+	}
+}
+
+func TestNewLogger_GlobalLevelAppliesToAllSinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	level := zap.NewAtomicLevelAt(zap.InfoLevel)
+	logger, err := newLogger(&config.LogConfig{Filename: path, Sinks: []config.LogSinkConfig{{Type: "file", Level: "debug"}}}, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("hidden")
+	level.SetLevel(zap.DebugLevel)
+	logger.Debug("visible")
+	_ = logger.Sync()
+
+	lines := readLines(t, path)
+	if len(lines) != 1 || !strings.Contains(lines[0], "visible") {
+		t.Errorf("sink 级别不能低于全局级别，调整全局级别后应立即生效，得到 %v", lines)
+	}
+}
+
+func TestNewLogger_UnavailableSinkIsSkipped(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.LogConfig{Sinks: []config.LogSinkConfig{
+		{Type: "file", Filename: filepath.Join(blocker, "app.log")},
+		{Type: "file", Filename: filepath.Join(dir, "ok.log")},
+	}}
+	logger, err := newLogger(cfg, zap.NewAtomicLevelAt(zap.InfoLevel))
+	if err == nil {
+		t.Fatal("无法创建目录的 sink 应返回错误")
+	}
+	if logger == nil {
+		t.Fatal("其余 sink 仍应可用")
+	}
+	logger.Info("still logging")
+	_ = logger.Sync()
+	if lines := readLines(t, filepath.Join(dir, "ok.log")); len(lines) != 1 {
+		t.Errorf("可用的 sink 应正常写入，得到 %v", lines)
+	}
+}
+
+func TestLogfmtEncoder(t *testing.T) {
+	enc := newLogfmtEncoder(zapcore.EncoderConfig{MessageKey: "msg", LevelKey: "level", EncodeLevel: zapcore.LowercaseLevelEncoder})
+	enc.AddString("component", "db")
+	buf, err := enc.EncodeEntry(zapcore.Entry{Level: zapcore.WarnLevel, Message: "slow query"}, []zapcore.Field{
+		zap.String("empty", ""),
+		zap.String("expr", "a=b"),
+		zap.Strings("tags", []string{"x", "y"}),
+		zap.Bool("ok", false),
+		zap.Error(errors.New(`bad "input"`)),
+		zap.Dict("user", zap.Int64("id", 7), zap.Dict("plan", zap.String("name", "pro"))),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `level=warn msg="slow query" component=db empty="" expr="a=b" tags="[\"x\",\"y\"]" ok=false error="bad \"input\"" user.id=7 user.plan.name=pro` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("期望\n%s得到\n%s", want, got)
+	}
+}
+
+// jsonZapHandler 输出到 buf 的 ZapHandler，使用 slog 的默认 key，便于 slogtest 检查
+func jsonZapHandler(buf *bytes.Buffer) *ZapHandler {
+	enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+		TimeKey:     slog.TimeKey,
+		LevelKey:    slog.LevelKey,
+		MessageKey:  slog.MessageKey,
+		CallerKey:   "caller",
+		EncodeTime:  zapcore.RFC3339NanoTimeEncoder,
+		EncodeLevel: zapcore.LowercaseLevelEncoder,
+		// 完整路径便于断言调用位置
+		EncodeCaller:   zapcore.FullCallerEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+	})
+	core := zapcore.NewCore(enc, zapcore.AddSync(buf), zapcore.DebugLevel)
+	return NewZapHandler(zap.New(core, zap.AddCaller()), slog.LevelDebug)
+}
+
+func parseJSONLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		m := map[string]any{}
+		if err := json.Unmarshal(line, &m); err != nil {
+			t.Fatalf("不是合法 JSON: %v, %s", err, line)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestZapHandler_Conformance(t *testing.T) {
+	var buf bytes.Buffer
+	if err := slogtest.TestHandler(jsonZapHandler(&buf), func() []map[string]any {
+		return parseJSONLines(t, &buf)
+	}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestZapHandler_CallerAndTime(t *testing.T) {
+	var buf bytes.Buffer
+	slog.New(jsonZapHandler(&buf)).Info("hello", "n", 1)
+	entries := parseJSONLines(t, &buf)
+	if len(entries) != 1 {
+		t.Fatalf("期望 1 条日志，得到 %d", len(entries))
+	}
+	caller, _ := entries[0]["caller"].(string)
+	if !strings.Contains(caller, "logger_test.go:") {
+		t.Errorf("caller 应指向调用 slog 的位置，得到 %q", caller)
+	}
+	if len(entries[0]) != 5 {
+		t.Errorf("只应有 time、level、msg、caller 与属性，得到 %v", entries[0])
+	}
+}
+
+type ctxKey struct{}
+
+func TestZapHandler_ContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	h := jsonZapHandler(&buf).WithContextFields(func(ctx context.Context) []zap.Field {
+		if id, ok := ctx.Value(ctxKey{}).(string); ok {
+			return []zap.Field{zap.String("request_id", id)}
+		}
+		return nil
+	})
+	logger := slog.New(h).WithGroup("svc")
+	logger.InfoContext(context.WithValue(context.Background(), ctxKey{}, "req-1"), "with ctx", "k", "v")
+	logger.Info("without ctx")
+
+	entries := parseJSONLines(t, &buf)
+	if len(entries) != 2 {
+		t.Fatalf("期望 2 条日志，得到 %d", len(entries))
+	}
+	if entries[0]["request_id"] != "req-1" {
+		t.Errorf("应从 ctx 提取字段，得到 %v", entries[0])
+	}
+	if svc, _ := entries[0]["svc"].(map[string]any); svc["k"] != "v" {
+		t.Errorf("记录属性应在组内，得到 %v", entries[0])
+	}
+	if _, ok := entries[1]["request_id"]; ok {
+		t.Errorf("ctx 中没有值时不应输出，得到 %v", entries[1])
+	}
+
+	// WithGroup 之后再 With，ctx 字段仍在顶层
+	buf.Reset()
+	logger.With("a", 1).WithGroup("inner").InfoContext(context.WithValue(context.Background(), ctxKey{}, "req-2"), "with attrs", "k", "v")
+	entries = parseJSONLines(t, &buf)
+	if entries[0]["request_id"] != "req-2" {
+		t.Errorf("WithGroup+With 时 ctx 字段应在顶层，得到 %v", entries[0])
+	}
+	svc, _ := entries[0]["svc"].(map[string]any)
+	inner, _ := svc["inner"].(map[string]any)
+	if svc["a"] != float64(1) || svc["request_id"] != nil || inner["k"] != "v" {
+		t.Errorf("With 的属性应在组内，得到 %v", entries[0])
+	}
+}
+
+func TestRequestLogger_ContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	e := echo.New()
+	e.Logger = slog.New(jsonZapHandler(&buf).WithContextFields(requestid.ContextFields))
+	e.Use(middleware.RequestID(), requestid.Middleware())
+	e.GET("/links/:code", func(c *echo.Context) error {
+		c.Logger().Info("before auth")
+		setPrincipal(c, Principal{UserID: 42})
+		// 模拟 service 层：只拿到 context.Context
+		ctx := c.Request().Context()
+		slog.New(e.Logger.Handler()).InfoContext(ctx, "in service")
+		requestid.LoggerFromContext(ctx).Info("from ctx logger")
+		return c.NoContent(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/links/abc", nil)
+	req.Header.Set(echo.HeaderXRequestID, "req-1")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	e.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := parseJSONLines(t, &buf)
+	if len(entries) != 3 {
+		t.Fatalf("期望 3 条日志，得到 %d", len(entries))
+	}
+	for _, entry := range entries {
+		if entry["request_id"] != "req-1" || entry["route"] != "/links/:code" || entry["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+			t.Errorf("每条日志都应带请求字段，得到 %v", entry)
+		}
+	}
+	if _, ok := entries[0]["user_id"]; ok {
+		t.Errorf("认证前不应有 user_id，得到 %v", entries[0])
+	}
+	for _, entry := range entries[1:] {
+		if entry["user_id"] != float64(42) {
+			t.Errorf("认证后应带 user_id，得到 %v", entry)
+		}
+	}
+}
+
+func TestRequestLoggerWithZap_LevelFromStatus(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	old := Log
+	Log = zap.New(core)
+	t.Cleanup(func() { Log = old })
+
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler(problem.Default)
+	e.Use(RequestLoggerWithZap())
+	e.GET("/limited", func(c *echo.Context) error {
+		return problem.New(http.StatusTooManyRequests, "rate limit exceeded")
+	})
+	e.GET("/boom", func(c *echo.Context) error {
+		return errors.New("boom")
+	})
+
+	tests := []struct {
+		path   string
+		status int
+		level  zapcore.Level
+	}{
+		{"/limited", http.StatusTooManyRequests, zapcore.WarnLevel},
+		{"/boom", http.StatusInternalServerError, zapcore.ErrorLevel},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.status {
+			t.Fatalf("%s: 期望状态码 %d，得到 %d", tt.path, tt.status, rec.Code)
+		}
+		entries := logs.TakeAll()
+		if len(entries) != 1 {
+			t.Fatalf("%s: 期望 1 条日志，得到 %d", tt.path, len(entries))
+		}
+		if entries[0].Level != tt.level {
+			t.Errorf("%s: 期望级别 %s，得到 %s", tt.path, tt.level, entries[0].Level)
+		}
+		if _, ok := entries[0].ContextMap()["error"]; !ok {
+			t.Errorf("%s: 应附带 error 字段，得到 %v", tt.path, entries[0].ContextMap())
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package utils
+
+import (
+	"echotest/config"
+	"log/slog"
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LogLevel 所有 logger 共用的日志级别：zap core 与 SlogLogger 都由它决定，
+// 由配置（log.level、log.overrides）设置，可通过 /admin/loglevel 或 SIGUSR1 临时调整
+var LogLevel = NewLogLevels(zapcore.InfoLevel)
+
+// LogLevels 全局级别加按 logger 名称的覆盖。覆盖按名称前缀匹配，"db" 同时作用于 "db" 与 "db.pool"，
+// 多个覆盖匹配时取最长的。运行时的调整到期后恢复为配置中的级别
+type LogLevels struct {
+	global zap.AtomicLevel
+	// overrides 不可变的 map，修改时整体替换
+	overrides atomic.Pointer[map[string]zapcore.Level]
+	// minOverride 覆盖中的最低级别，没有覆盖时为 InvalidLevel（高于所有级别）
+	minOverride atomic.Int32
+
+	mu sync.Mutex
+	// base 配置中的级别，临时调整到期、Revert 或 SIGUSR1 关闭 debug 时恢复为它
+	base          zapcore.Level
+	baseOverrides map[string]zapcore.Level
+	revert        *time.Timer
+	revertAt      time.Time
+	// temporary 为 true 时存在尚未恢复的运行时调整，此时配置变更只更新 base
+	temporary bool
+}
+
+// LogLevelState 当前生效的级别
+type LogLevelState struct {
+	Level     string            `json:"level"`
+	Overrides map[string]string `json:"overrides"`
+	// RevertAt 临时调整的恢复时间
+	RevertAt *time.Time `json:"revert_at,omitempty"`
+}
+
+// NewLogLevels 创建全局级别为 level、没有覆盖的 LogLevels
+func NewLogLevels(level zapcore.Level) *LogLevels {
+	l := &LogLevels{global: zap.NewAtomicLevelAt(level), base: level}
+	l.setOverrides(nil)
+	return l
+}
+
+// ParseLogLevels 解析级别名称（debug/info/warn/error，空字符串视为 info）与覆盖
+func ParseLogLevels(level string, overrides map[string]string) (zapcore.Level, map[string]zapcore.Level, error) {
+	lvl, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return 0, nil, err
+	}
+	parsed := make(map[string]zapcore.Level, len(overrides))
+	for name, s := range overrides {
+		if parsed[name], err = zapcore.ParseLevel(s); err != nil {
+			return 0, nil, err
+		}
+	}
+	return lvl, parsed, nil
+}
+
+// Apply 应用配置中的级别与覆盖；存在未到期的运行时调整时只记录，恢复时生效
+func (l *LogLevels) Apply(cfg *config.LogConfig) error {
+	var (
+		level     string
+		overrides map[string]string
+	)
+	if cfg != nil {
+		level, overrides = cfg.Level, cfg.Overrides
+	}
+	lvl, parsed, err := ParseLogLevels(level, overrides)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.base, l.baseOverrides = lvl, parsed
+	if !l.temporary {
+		l.global.SetLevel(lvl)
+		l.setOverrides(parsed)
+	}
+	return nil
+}
+
+// Set 运行时调整级别；overrides 为 nil 时保留当前覆盖。revertAfter 大于 0 时到期自动恢复为配置中的级别，
+// 否则保持到 Revert 或进程重启
+func (l *LogLevels) Set(level zapcore.Level, overrides map[string]zapcore.Level, revertAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.set(level, overrides, revertAfter)
+}
+
+// Revert 撤销运行时调整，恢复为配置中的级别与覆盖
+func (l *LogLevels) Revert() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.restore()
+}
+
+// ToggleDebug 当前不是 debug 时切换为 debug，否则恢复为配置中的级别；返回切换后的全局级别
+func (l *LogLevels) ToggleDebug() zapcore.Level {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.global.Level() == zapcore.DebugLevel && l.base != zapcore.DebugLevel {
+		l.restore()
+	} else {
+		l.set(zapcore.DebugLevel, nil, 0)
+	}
+	return l.global.Level()
+}
+
+func (l *LogLevels) set(level zapcore.Level, overrides map[string]zapcore.Level, revertAfter time.Duration) {
+	l.stopRevert()
+	l.temporary = true
+	l.global.SetLevel(level)
+	if overrides != nil {
+		l.setOverrides(overrides)
+	}
+	if revertAfter > 0 {
+		var t *time.Timer
+		t = time.AfterFunc(revertAfter, func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			// 到期时可能已被新的调整取代
+			if l.revert == t {
+				l.restore()
+			}
+		})
+		l.revert, l.revertAt = t, time.Now().Add(revertAfter)
+	}
+}
+
+func (l *LogLevels) restore() {
+	l.stopRevert()
+	l.temporary = false
+	l.global.SetLevel(l.base)
+	l.setOverrides(l.baseOverrides)
+}
+
+// State 返回当前生效的级别
+func (l *LogLevels) State() LogLevelState {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := LogLevelState{Level: l.global.Level().String(), Overrides: map[string]string{}}
+	for name, lvl := range *l.overrides.Load() {
+		s.Overrides[name] = lvl.String()
+	}
+	if l.revert != nil {
+		at := l.revertAt
+		s.RevertAt = &at
+	}
+	return s
+}
+
+func (l *LogLevels) stopRevert() {
+	if l.revert != nil {
+		l.revert.Stop()
+		l.revert = nil
+	}
+}
+
+func (l *LogLevels) setOverrides(overrides map[string]zapcore.Level) {
+	m := maps.Clone(overrides)
+	if m == nil {
+		m = map[string]zapcore.Level{}
+	}
+	lowest := zapcore.InvalidLevel
+	for _, lvl := range m {
+		lowest = min(lowest, lvl)
+	}
+	l.overrides.Store(&m)
+	l.minOverride.Store(int32(lowest))
+}
+
+// Enabled 任一 logger 可能输出该级别时返回 true，具体 logger 是否输出由 EnabledFor 决定
+func (l *LogLevels) Enabled(lvl zapcore.Level) bool {
+	return l.global.Enabled(lvl) || int32(lvl) >= l.minOverride.Load()
+}
+
+// EnabledFor 名为 name 的 logger（zap.Logger.Named）是否输出该级别
+func (l *LogLevels) EnabledFor(name string, lvl zapcore.Level) bool {
+	if name != "" {
+		match, matched, found := "", zapcore.InvalidLevel, false
+		for prefix, o := range *l.overrides.Load() {
+			if (name == prefix || strings.HasPrefix(name, prefix+".")) && (!found || len(prefix) > len(match)) {
+				match, matched, found = prefix, o, true
+			}
+		}
+		if found {
+			return lvl >= matched
+		}
+	}
+	return l.global.Enabled(lvl)
+}
+
+// Level 实现 slog.Leveler：返回所有 logger 中最低的启用级别，供 slog handler 预先过滤
+func (l *LogLevels) Level() slog.Level {
+	lvl := l.global.Level()
+	if o := zapcore.Level(l.minOverride.Load()); o < lvl {
+		lvl = o
+	}
+	return slogLevel(lvl)
+}
+
+// Core 包装 zap core，按 logger 名称应用覆盖
+func (l *LogLevels) Core(c zapcore.Core) zapcore.Core {
+	return &levelCore{Core: c, levels: l}
+}
+
+func slogLevel(lvl zapcore.Level) slog.Level {
+	switch {
+	case lvl <= zapcore.DebugLevel:
+		return slog.LevelDebug
+	case lvl == zapcore.InfoLevel:
+		return slog.LevelInfo
+	case lvl == zapcore.WarnLevel:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+type levelCore struct {
+	zapcore.Core
+	levels *LogLevels
+}
+
+func (c *levelCore) Enabled(lvl zapcore.Level) bool {
+	return c.levels.Enabled(lvl)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.levels.EnabledFor(ent.LoggerName, ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
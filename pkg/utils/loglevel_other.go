@@ -0,0 +1,11 @@
+//go:build !unix
+
+package utils
+
+import (
+	"context"
+	"log/slog"
+)
+
+// watchLogLevelSignal 非 Unix 系统没有 SIGUSR1，只能通过 /admin/loglevel 调整
+func watchLogLevelSignal(ctx context.Context, logger *slog.Logger) {}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"context"
+	"echotest/config"
+	"log/slog"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// observed 返回由 levels 控制级别的 logger 与其记录的日志
+func observed(levels *LogLevels) (*zap.Logger, *observer.ObservedLogs) {
+	core, logs := observer.New(levels)
+	return zap.New(levels.Core(core)), logs
+}
+
+func TestLogLevels_Overrides(t *testing.T) {
+	levels := NewLogLevels(zapcore.InfoLevel)
+	if err := levels.Apply(&config.LogConfig{Level: "info", Overrides: map[string]string{
+		"db":        "debug",
+		"db.pool":   "error",
+		"ratelimit": "warn",
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	logger, logs := observed(levels)
+
+	logger.Debug("root debug")
+	logger.Named("db").Debug("db debug")
+	logger.Named("db").Named("query").Debug("db.query debug")
+	logger.Named("db").Named("pool").Warn("db.pool warn")
+	logger.Named("dbx").Debug("dbx debug")
+	logger.Named("ratelimit").Info("ratelimit info")
+	logger.Info("root info")
+
+	var got []string
+	for _, e := range logs.All() {
+		got = append(got, e.Message)
+	}
+	want := []string{"db debug", "db.query debug", "root info"}
+	if len(got) != len(want) {
+		t.Fatalf("期望 %v，得到 %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("期望 %v，得到 %v", want, got)
+		}
+	}
+	if levels.Level() != slog.LevelDebug {
+		t.Errorf("存在 debug 覆盖时 slog 预过滤级别应为 debug，得到 %v", levels.Level())
+	}
+}
+
+func TestLogLevels_OverrideThroughSlog(t *testing.T) {
+	levels := NewLogLevels(zapcore.InfoLevel)
+	if err := levels.Apply(&config.LogConfig{Level: "info", Overrides: map[string]string{"ratelimit": "debug"}}); err != nil {
+		t.Fatal(err)
+	}
+	logger, logs := observed(levels)
+
+	// 与 NamedLogger 相同的构造方式：子系统的 slog logger 对应具名的 zap logger
+	newSlogLogger(logger.Named("ratelimit"), levels).Debug("ratelimit debug", "key", "k")
+	newSlogLogger(logger.Named("analytics"), levels).Debug("analytics debug")
+	newSlogLogger(logger, levels).Debug("root debug")
+
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].Message != "ratelimit debug" || entries[0].LoggerName != "ratelimit" {
+		t.Fatalf("只有被覆盖的子系统应输出 debug，得到 %v", entries)
+	}
+}
+
+func TestLogLevels_SetRevertAndConfigReload(t *testing.T) {
+	levels := NewLogLevels(zapcore.InfoLevel)
+	_ = levels.Apply(&config.LogConfig{Level: "warn"})
+	logger, logs := observed(levels)
+
+	levels.Set(zapcore.DebugLevel, map[string]zapcore.Level{"db": zapcore.ErrorLevel}, 0)
+	// 临时调整期间的配置变更只更新恢复目标
+	_ = levels.Apply(&config.LogConfig{Level: "error"})
+	logger.Debug("during incident")
+	logger.Named("db").Warn("db warn")
+	if logs.Len() != 1 {
+		t.Fatalf("临时调整应生效且不被配置变更覆盖，得到 %v", logs.All())
+	}
+	if s := levels.State(); s.Level != "debug" || s.Overrides["db"] != "error" || s.RevertAt != nil {
+		t.Errorf("State 应反映临时调整，得到 %+v", s)
+	}
+
+	levels.Revert()
+	if s := levels.State(); s.Level != "error" || len(s.Overrides) != 0 {
+		t.Errorf("恢复后应使用最新配置，得到 %+v", s)
+	}
+	// 未处于临时调整时配置变更立即生效
+	_ = levels.Apply(&config.LogConfig{Level: "info"})
+	if s := levels.State(); s.Level != "info" {
+		t.Errorf("配置变更应立即生效，得到 %+v", s)
+	}
+}
+
+func TestLogLevels_AutoRevert(t *testing.T) {
+	levels := NewLogLevels(zapcore.InfoLevel)
+	levels.Set(zapcore.DebugLevel, nil, 20*time.Millisecond)
+	if s := levels.State(); s.Level != "debug" || s.RevertAt == nil {
+		t.Fatalf("应设置恢复时间，得到 %+v", s)
+	}
+	// 新的调整取代旧的定时器
+	levels.Set(zapcore.WarnLevel, nil, time.Hour)
+	time.Sleep(60 * time.Millisecond)
+	if s := levels.State(); s.Level != "warn" {
+		t.Fatalf("被取代的定时器不应恢复级别，得到 %+v", s)
+	}
+
+	levels.Set(zapcore.DebugLevel, nil, 20*time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for levels.State().Level != "info" {
+		if time.Now().After(deadline) {
+			t.Fatalf("到期后应恢复为配置中的级别，得到 %+v", levels.State())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if levels.State().RevertAt != nil {
+		t.Error("恢复后不应再有恢复时间")
+	}
+}
+
+func TestLogLevels_ToggleDebug(t *testing.T) {
+	levels := NewLogLevels(zapcore.InfoLevel)
+	if got := levels.ToggleDebug(); got != zapcore.DebugLevel {
+		t.Errorf("第一次切换应为 debug，得到 %v", got)
+	}
+	if got := levels.ToggleDebug(); got != zapcore.InfoLevel {
+		t.Errorf("再次切换应恢复为配置中的级别，得到 %v", got)
+	}
+}
+
+func TestZapHandler_FollowsLogLevels(t *testing.T) {
+	levels := NewLogLevels(zapcore.InfoLevel)
+	logger, logs := observed(levels)
+	slogger := slog.New(NewZapHandler(logger, levels))
+
+	slogger.Debug("hidden")
+	levels.Set(zapcore.DebugLevel, nil, 0)
+	slogger.DebugContext(context.Background(), "visible")
+	if logs.Len() != 1 || logs.All()[0].Message != "visible" {
+		t.Errorf("slog handler 应与 zap core 共用级别，得到 %v", logs.All())
+	}
+}
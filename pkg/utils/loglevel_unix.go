@@ -0,0 +1,27 @@
+//go:build unix
+
+package utils
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchLogLevelSignal 收到 SIGUSR1 时在 debug 与配置中的级别之间切换，ctx 取消后停止
+func watchLogLevelSignal(ctx context.Context, logger *slog.Logger) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+	defer signal.Stop(ch)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ch:
+			level := LogLevel.ToggleDebug()
+			logger.Warn("log level toggled by SIGUSR1", "level", level.String())
+		}
+	}
+}
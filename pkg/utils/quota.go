@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"context"
+	"echotest/config"
+	"echotest/pkg/problem"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v5"
+)
+
+// quotaRefundTimeout 撤销计数的超时时间；撤销不受请求 ctx 取消影响
+const quotaRefundTimeout = 2 * time.Second
+
+// Quota 当前请求适用的一项配额
+type Quota struct {
+	Name   string
+	Period string
+	// Subject 计数对象，如 user:1、apikey:3
+	Subject string
+	Limit   int64
+	Soft    int64
+}
+
+// QuotaUsage 一项配额在当前周期内的用量
+type QuotaUsage struct {
+	Quota
+	Used        int64
+	PeriodStart time.Time
+	// Reset 下一个周期开始的时间
+	Reset time.Time
+}
+
+// QuotaCounter 配额计数，由 service.QuotaService 实现
+type QuotaCounter interface {
+	// Consume 计数一次；已达到硬上限时不计数并返回 ok=false
+	Consume(ctx context.Context, q Quota) (u QuotaUsage, ok bool, err error)
+	// Refund 撤销一次 Consume，用于请求最终失败的情况
+	Refund(ctx context.Context, u QuotaUsage) error
+}
+
+// QuotaExceededError 配额已用完。实现了 problem.Provider，
+// 作为 error 返回时输出为 429 problem，扩展字段告知调用方哪项配额用完以及何时重置。
+type QuotaExceededError struct {
+	Message string
+	Quota   string
+	Period  string
+	Limit   int64
+	Used    int64
+	Reset   time.Time
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("%s: %s (%d per %s, resets at %s)", e.Message, e.Quota, e.Limit, e.Period, e.Reset.Format(time.RFC3339))
+}
+
+func (e *QuotaExceededError) Problem() *problem.Problem {
+	return problem.Typed(http.StatusTooManyRequests, "quota-exceeded", "Quota exceeded", e.Message).
+		With("quota", e.Quota).
+		With("period", e.Period).
+		With("limit", e.Limit).
+		With("used", e.Used).
+		With("reset", e.Reset)
+}
+
+// Quotas 按配置 quota.groups[group] 为请求计数，须放在认证中间件之后；未登录的请求不计数。
+// 任一配额达到硬上限时拒绝请求（已计数的配额会撤销），达到软上限时放行并在响应中附带 X-Quota-Warning。
+// 处理失败（返回 error 或状态码 >= 400）的请求不占用配额。
+func Quotas(mgr *config.Manager, counter QuotaCounter, group string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			cfg := mgr.Current()
+			var names []string
+			for _, name := range cfg.QuotaPolicies(group) {
+				policy := cfg.Quota.Policies[name]
+				if len(policy.Methods) == 0 || slices.Contains(policy.Methods, c.Request().Method) {
+					names = append(names, name)
+				}
+			}
+			quotas := ResolveQuotas(c, cfg, names)
+			if len(quotas) == 0 {
+				return next(c)
+			}
+
+			ctx := c.Request().Context()
+			consumed := make([]QuotaUsage, 0, len(quotas))
+			refund := func() {
+				if len(consumed) == 0 {
+					return
+				}
+				// 客户端断开或请求超时导致处理失败时 ctx 已取消，撤销仍须完成，否则配额被永久占用
+				rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), quotaRefundTimeout)
+				defer cancel()
+				for _, u := range consumed {
+					if err := counter.Refund(rctx, u); err != nil {
+						c.Logger().Error("failed to refund quota", "quota", u.Name, "subject", u.Subject, "error", err)
+					}
+				}
+			}
+			// 全部配额通过后才输出提醒，避免拒绝请求的响应中带有“即将用完”的提醒
+			var warnings []string
+			for _, q := range quotas {
+				u, ok, err := counter.Consume(ctx, q)
+				if err != nil {
+					refund()
+					return err
+				}
+				if !ok {
+					refund()
+					c.Response().Header().Set("Retry-After", strconv.FormatInt(max(int64(math.Ceil(time.Until(u.Reset).Seconds())), 1), 10))
+					return &QuotaExceededError{
+						Message: "quota exceeded",
+						Quota:   q.Name,
+						Period:  q.Period,
+						Limit:   q.Limit,
+						Used:    u.Used,
+						Reset:   u.Reset,
+					}
+				}
+				consumed = append(consumed, u)
+				if q.Soft > 0 && u.Used >= q.Soft {
+					warnings = append(warnings, fmt.Sprintf("%s; used=%d; limit=%d; reset=%s",
+						q.Name, u.Used, q.Limit, u.Reset.Format(time.RFC3339)))
+				}
+			}
+			for _, w := range warnings {
+				c.Response().Header().Add("X-Quota-Warning", w)
+			}
+
+			err := next(c)
+			if err != nil {
+				refund()
+			} else if resp, uerr := echo.UnwrapResponse(c.Response()); uerr == nil && resp.Status >= http.StatusBadRequest {
+				refund()
+			}
+			return err
+		}
+	}
+}
+
+// ResolveQuotas 按当前用户的套餐与认证方式解析 names 对应的配额；未登录时返回 nil
+func ResolveQuotas(c *echo.Context, cfg *config.Config, names []string) []Quota {
+	userID, ok := c.Get("userID").(int)
+	if !ok || userID <= 0 || cfg.Quota == nil {
+		return nil
+	}
+	plan, _ := c.Get("plan").(string)
+	quotas := make([]Quota, 0, len(names))
+	for _, name := range names {
+		policy, ok := cfg.Quota.Policies[name]
+		if !ok {
+			continue
+		}
+		subject := "user:" + strconv.Itoa(userID)
+		if id, ok := c.Get("apiKeyID").(int64); ok && policy.Subject == "apikey" {
+			subject = "apikey:" + strconv.FormatInt(id, 10)
+		}
+		limit, soft := policy.Limits(plan)
+		quotas = append(quotas, Quota{Name: name, Period: policy.Period, Subject: subject, Limit: limit, Soft: soft})
+	}
+	return quotas
+}
+
+// CurrentQuotas 返回当前请求适用的全部配额，按名称排序，供 /api/usage 展示
+func CurrentQuotas(c *echo.Context, cfg *config.Config) []Quota {
+	if cfg.Quota == nil {
+		return nil
+	}
+	names := make([]string, 0, len(cfg.Quota.Policies))
+	for name := range cfg.Quota.Policies {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return ResolveQuotas(c, cfg, names)
+}
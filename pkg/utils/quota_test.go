@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"echotest/config"
+	"echotest/pkg/problem"
+
+	"github.com/labstack/echo/v5"
+)
+
+const quotaYAML = `
+server:
+  port: 8080
+jwt:
+  secret: test-secret-0123456789
+  duration: 1h
+quota:
+  policies:
+    created:
+      period: month
+      subject: user
+      limit: 3
+      soft: 2
+      methods: [POST]
+  groups:
+    links: [created]
+`
+
+// memQuota 内存计数，按 subject/配额名计数
+type memQuota struct {
+	used map[string]int64
+}
+
+func (m *memQuota) Consume(_ context.Context, q Quota) (QuotaUsage, bool, error) {
+	k := q.Subject + "/" + q.Name
+	u := QuotaUsage{Quota: q, Reset: time.Now().Add(time.Hour)}
+	if m.used[k] >= q.Limit {
+		u.Used = m.used[k]
+		return u, false, nil
+	}
+	m.used[k]++
+	u.Used = m.used[k]
+	return u, true, nil
+}
+
+func (m *memQuota) Refund(ctx context.Context, u QuotaUsage) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.used[u.Subject+"/"+u.Name]--
+	return nil
+}
+
+func newQuotaServer(t *testing.T) (*echo.Echo, *memQuota) {
+	t.Helper()
+	return newQuotaServerWith(t, quotaYAML)
+}
+
+func newQuotaServerWith(t *testing.T, yaml string) (*echo.Echo, *memQuota) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mgr, err := config.NewManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := &memQuota{used: map[string]int64{}}
+	e := echo.New()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler(problem.Default)
+	g := e.Group("/links", fakeAuth("user"), Quotas(mgr, counter, "links"))
+	g.POST("", func(c *echo.Context) error {
+		if c.QueryParam("fail") != "" {
+			return c.NoContent(http.StatusBadRequest)
+		}
+		return c.NoContent(http.StatusCreated)
+	})
+	g.GET("", func(c *echo.Context) error { return c.NoContent(http.StatusOK) })
+	return e, counter
+}
+
+func TestQuotas(t *testing.T) {
+	e, counter := newQuotaServer(t)
+	do := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		return rec
+	}
+
+	// 失败的请求不占用配额
+	if rec := do(http.MethodPost, "/links?fail=1"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("期望 400，得到 %d", rec.Code)
+	}
+	if n := counter.used["user:1/created"]; n != 0 {
+		t.Errorf("失败的请求应撤销计数，得到 %d", n)
+	}
+
+	if rec := do(http.MethodPost, "/links"); rec.Header().Get("X-Quota-Warning") != "" {
+		t.Error("未达到软上限时不应提醒")
+	}
+	rec := do(http.MethodPost, "/links")
+	if w := rec.Header().Get("X-Quota-Warning"); !strings.HasPrefix(w, "created; used=2; limit=3") {
+		t.Errorf("达到软上限应提醒，得到 %q", w)
+	}
+	do(http.MethodPost, "/links")
+
+	rec = do(http.MethodPost, "/links")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("超出硬上限应返回 429，得到 %d", rec.Code)
+	}
+	var body struct {
+		Quota  string `json:"quota"`
+		Period string `json:"period"`
+		Limit  int64  `json:"limit"`
+		Used   int64  `json:"used"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Quota != "created" || body.Limit != 3 || body.Used != 3 || body.Period != "month" {
+		t.Errorf("响应应说明用完的配额，得到 %s", rec.Body.String())
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("应带 Retry-After")
+	}
+
+	// 只对 POST 计数
+	if rec := do(http.MethodGet, "/links"); rec.Code != http.StatusOK {
+		t.Errorf("GET 不受配额限制，得到 %d", rec.Code)
+	}
+}
+
+func TestQuotas_RefundAfterClientGone(t *testing.T) {
+	e, counter := newQuotaServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest(http.MethodPost, "/links?fail=1", nil).WithContext(ctx)
+	e.ServeHTTP(httptest.NewRecorder(), req)
+	if n := counter.used["user:1/created"]; n != 0 {
+		t.Errorf("请求 ctx 已取消时仍应撤销计数，得到 %d", n)
+	}
+}
+
+func TestQuotas_NoWarningOnRejection(t *testing.T) {
+	e, _ := newQuotaServerWith(t, `
+server:
+  port: 8080
+jwt:
+  secret: test-secret-0123456789
+  duration: 1h
+quota:
+  policies:
+    created:
+      period: month
+      subject: user
+      limit: 10
+      soft: 1
+    burst:
+      period: day
+      subject: user
+      limit: 1
+  groups:
+    links: [created, burst]
+`)
+	do := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/links", nil))
+		return rec
+	}
+	if rec := do(); rec.Code != http.StatusCreated || rec.Header().Get("X-Quota-Warning") == "" {
+		t.Fatalf("期望 201 且带提醒，得到 %d %q", rec.Code, rec.Header().Get("X-Quota-Warning"))
+	}
+	rec := do()
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("期望 429，得到 %d", rec.Code)
+	}
+	if w := rec.Header().Get("X-Quota-Warning"); w != "" {
+		t.Errorf("被拒绝的请求不应带配额提醒，得到 %q", w)
+	}
+}
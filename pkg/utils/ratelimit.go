@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"context"
+	"echotest/config"
+	"echotest/pkg/ratelimit"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v5"
+)
+
+// rateLimitSweepInterval 回收各策略限流器空闲 key 的间隔
+const rateLimitSweepInterval = time.Minute
+
+// RateLimits 按配置 ratelimit.policies 创建的命名限流器，按路由组挂载。
+// 每个策略的每个套餐对应一个独立的 ratelimit.Limiter，首次用到时创建；
+// 配置热加载后已创建的限流器立即应用新的 rate、burst。
+type RateLimits struct {
+	mgr *config.Manager
+	ec  *echo.Echo
+
+	mu       sync.Mutex
+	limiters map[string]*ratelimit.Limiter // "策略名/套餐" -> 限流器
+	backend  ratelimit.Backend
+}
+
+// NewRateLimits 创建 RateLimits 并订阅配置变更
+func NewRateLimits(mgr *config.Manager, ec *echo.Echo) *RateLimits {
+	r := &RateLimits{mgr: mgr, ec: ec, limiters: map[string]*ratelimit.Limiter{}}
+	mgr.Subscribe(func(_, cur *config.Config) { r.apply(cur) })
+	return r
+}
+
+// Group 返回路由组的限流中间件，依次检查该组绑定的全部策略；需要按用户限流时应放在认证中间件之后
+func (r *RateLimits) Group(group string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			cfg := r.mgr.Current()
+			for _, name := range cfg.RateLimitPolicies(group) {
+				policy := cfg.RateLimit.Policies[name]
+				if len(policy.Methods) > 0 && !slices.Contains(policy.Methods, c.Request().Method) {
+					continue
+				}
+				plan, _ := c.Get("plan").(string)
+				if ok, err := r.limiter(name, plan, policy).Take(c, rateLimitKey(c, policy)); !ok {
+					return err
+				}
+			}
+			return next(c)
+		}
+	}
+}
+
+// Run 定期回收各限流器中的空闲 key，阻塞直到 ctx 取消
+func (r *RateLimits) Run(ctx context.Context) {
+	ticker := time.NewTicker(rateLimitSweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			for _, l := range r.limiters {
+				l.Sweep()
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+// SetBackend 为已创建和之后创建的全部策略限流器设置共享后端
+func (r *RateLimits) SetBackend(b ratelimit.Backend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.backend = b
+	for _, l := range r.limiters {
+		l.SetBackend(b)
+	}
+}
+
+// limiter 返回策略 name 在 plan 下的限流器，不存在时按策略额度创建。
+// 策略没有为该套餐单独配置额度时，所有此类套餐共用同一个限流器。
+func (r *RateLimits) limiter(name, plan string, policy config.RateLimitPolicy) *ratelimit.Limiter {
+	if _, ok := policy.Plans[plan]; !ok {
+		plan = ""
+	}
+	id := name + "/" + plan
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if l, ok := r.limiters[id]; ok {
+		return l
+	}
+	rate, burst := policy.Limit(plan)
+	s := r.mgr.Current().Server
+	l := ratelimit.New(ratelimit.Config{
+		Rate:    rate,
+		Burst:   burst,
+		MaxKeys: s.RateLimitMaxKeys,
+		IdleTTL: s.RateLimitIdleTTL,
+		Prefix:  id + ":",
+	})
+	l.SetBackend(r.backend)
+	r.limiters[id] = l
+	registerCollector(r.ec, l.Collector(id))
+	return l
+}
+
+// apply 把新配置中的额度应用到已创建的限流器；策略被删除时保留限流器，但不会再被任何路由组引用
+func (r *RateLimits) apply(cur *config.Config) {
+	if cur.RateLimit == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, policy := range cur.RateLimit.Policies {
+		if l, ok := r.limiters[name+"/"]; ok {
+			l.SetLimit(policy.Rate, policy.Burst)
+		}
+		for plan, pl := range policy.Plans {
+			if l, ok := r.limiters[name+"/"+plan]; ok {
+				l.SetLimit(pl.Rate, pl.Burst)
+			}
+		}
+	}
+}
+
+// rateLimitKey 按策略的 key 来源取限流 key；取不到用户或 API key 时退化为按 IP
+func rateLimitKey(c *echo.Context, policy config.RateLimitPolicy) string {
+	switch policy.Key {
+	case "header":
+		if v := c.Request().Header.Get(policy.Header); v != "" {
+			return "header:" + v
+		}
+	case "apikey":
+		if id, ok := c.Get("apiKeyID").(int64); ok {
+			return "apikey:" + strconv.FormatInt(id, 10)
+		}
+		fallthrough
+	case "user":
+		if id, ok := c.Get("userID").(int); ok && id > 0 {
+			return "user:" + strconv.Itoa(id)
+		}
+	}
+	return "ip:" + c.RealIP()
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"echotest/config"
+
+	"github.com/labstack/echo/v5"
+)
+
+const rateLimitYAML = `
+server:
+  port: 8080
+jwt:
+  secret: test-secret-0123456789
+  duration: 1h
+ratelimit:
+  policies:
+    create:
+      rate: 0.001
+      burst: 1
+      key: user
+      methods: [POST]
+      plans:
+        pro: { rate: 0.001, burst: 3 }
+  groups:
+    links: [create]
+`
+
+func newRateLimitServer(t *testing.T) *echo.Echo {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(rateLimitYAML), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mgr, err := config.NewManager(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := echo.New()
+	limits := NewRateLimits(mgr, e)
+	// 用请求头模拟认证中间件写入的 userID 与 plan
+	auth := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			if id := c.Request().Header.Get("X-User"); id != "" {
+				c.Set("userID", int(id[0]-'0'))
+				c.Set("plan", c.Request().Header.Get("X-Plan"))
+			}
+			return next(c)
+		}
+	}
+	ok := func(c *echo.Context) error { return c.NoContent(http.StatusOK) }
+	e.POST("/links", ok, auth, limits.Group("links"))
+	e.GET("/links", ok, auth, limits.Group("links"))
+	return e
+}
+
+func do(e *echo.Echo, method, user, plan string) int {
+	req := httptest.NewRequest(method, "/links", nil)
+	req.Header.Set("X-User", user)
+	req.Header.Set("X-Plan", plan)
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestRateLimits_PerUserAndPlan(t *testing.T) {
+	e := newRateLimitServer(t)
+
+	if do(e, http.MethodPost, "1", "free") != http.StatusOK || do(e, http.MethodPost, "1", "free") != http.StatusTooManyRequests {
+		t.Error("free 套餐突发为 1，第 2 次创建应被限流")
+	}
+	if do(e, http.MethodPost, "2", "free") != http.StatusOK {
+		t.Error("不同用户应独立计数")
+	}
+	for i := 0; i < 3; i++ {
+		if code := do(e, http.MethodPost, "3", "pro"); code != http.StatusOK {
+			t.Errorf("pro 套餐第 %d 次创建: 期望 200，得到 %d", i+1, code)
+		}
+	}
+	if do(e, http.MethodGet, "1", "free") != http.StatusOK {
+		t.Error("策略只限制 POST，GET 不应受影响")
+	}
+}
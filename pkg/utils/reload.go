@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"sync/atomic"
+
+	"echotest/config"
+	"echotest/pkg/ratelimit"
+
+	"github.com/labstack/echo/v5"
+	"github.com/labstack/echo/v5/middleware"
+)
+
+// 以下中间件的参数在配置热加载后无需重启即可生效
+
+// dynamicBodyLimit 可在运行时替换上限的 BodyLimit 中间件
+type dynamicBodyLimit struct {
+	mw atomic.Pointer[echo.MiddlewareFunc]
+}
+
+func newDynamicBodyLimit(limit int64) *dynamicBodyLimit {
+	d := &dynamicBodyLimit{}
+	d.Set(limit)
+	return d
+}
+
+// Set 替换请求体上限（字节）
+func (d *dynamicBodyLimit) Set(limit int64) {
+	mw := middleware.BodyLimit(limit)
+	d.mw.Store(&mw)
+}
+
+func (d *dynamicBodyLimit) Middleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c *echo.Context) error {
+			return (*d.mw.Load())(next)(c)
+		}
+	}
+}
+
+// dynamicOrigins CORS 允许来源列表，包含 "*" 时允许所有来源
+type dynamicOrigins struct {
+	origins atomic.Pointer[map[string]struct{}]
+}
+
+func newDynamicOrigins(origins []string) *dynamicOrigins {
+	d := &dynamicOrigins{}
+	d.Set(origins)
+	return d
+}
+
+// Set 替换允许来源列表，为空时等同于 "*"
+func (d *dynamicOrigins) Set(origins []string) {
+	if len(origins) == 0 {
+		origins = []string{"*"}
+	}
+	set := make(map[string]struct{}, len(origins))
+	for _, o := range origins {
+		set[o] = struct{}{}
+	}
+	d.origins.Store(&set)
+}
+
+// AllowOrigin 供 CORSConfig.UnsafeAllowOriginFunc 使用
+func (d *dynamicOrigins) AllowOrigin(_ *echo.Context, origin string) (string, bool, error) {
+	set := *d.origins.Load()
+	if _, ok := set["*"]; ok {
+		return "*", true, nil
+	}
+	if _, ok := set[origin]; ok {
+		return origin, true, nil
+	}
+	return "", false, nil
+}
+
+// serverLimits 从配置中取出 body limit 与限流参数，未配置时使用默认值
+func serverLimits(s *config.ServerInfo) (bodyLimit int64, rateLimitRate float64, rateLimitBurst int) {
+	bodyLimit, rateLimitRate, rateLimitBurst = 100<<10, 10, 20
+	if s == nil {
+		return
+	}
+	if s.BodyLimit > 0 {
+		bodyLimit = s.BodyLimit
+	}
+	if s.RateLimitRate > 0 {
+		rateLimitRate = s.RateLimitRate
+	}
+	if s.RateLimitBurst > 0 {
+		rateLimitBurst = s.RateLimitBurst
+	}
+	return
+}
+
+// subscribeReload 注册配置变更回调，把新值应用到可热更新的中间件与日志级别
+func subscribeReload(ec *echo.Echo, mgr *config.Manager, bodyLimit *dynamicBodyLimit, origins *dynamicOrigins, limiter *ratelimit.Limiter, dumper *bodyDumper) {
+	mgr.Subscribe(func(_, cur *config.Config) {
+		bl, r, burst := serverLimits(cur.Server)
+		bodyLimit.Set(bl)
+		limiter.SetLimit(r, burst)
+		origins.Set(cur.Server.CORSAllowOrigins)
+		if err := LogLevel.Apply(cur.Log); err != nil {
+			ec.Logger.Error("failed to apply log level", "error", err)
+		}
+		dumper.Set(bodyDumpConfig(cur.Log))
+		ec.Logger.Info("runtime config applied",
+			"body_limit", bl, "rate_limit_rate", r, "rate_limit_burst", burst,
+			"cors_allow_origins", cur.Server.CORSAllowOrigins)
+	})
+}
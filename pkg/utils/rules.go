@@ -0,0 +1,416 @@
+package utils
+
+import (
+	"net/netip"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+	// 内嵌 IANA 时区数据库，iana_tz 在没有 /usr/share/zoneinfo 的精简镜像中也能校验
+	_ "time/tzdata"
+
+	"echotest/pkg/openapi"
+
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+)
+
+// ReservedAliases 不能用作短链接别名的词（不区分大小写）：与顶层路由冲突或容易被用于仿冒
+var ReservedAliases = []string{
+	"admin", "api", "auth", "docs", "health", "healthz", "help", "login", "logout",
+	"metrics", "openapi", "ping", "register", "signup", "static", "status", "support", "www",
+}
+
+var (
+	aliasRegex = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9_-]{1,30})[A-Za-z0-9]$`)
+	e164Regex  = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
+	// nonPublicPrefixes netip 未归入 private/loopback/link-local，但同样不能从公网访问的地址段
+	nonPublicPrefixes = []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/8"),
+		netip.MustParsePrefix("100.64.0.0/10"), // 运营商级 NAT
+		netip.MustParsePrefix("192.0.0.0/24"),
+		netip.MustParsePrefix("198.18.0.0/15"), // 基准测试
+		netip.MustParsePrefix("64:ff9b::/96"),  // NAT64，可映射到任意 IPv4
+	}
+)
+
+// rule 自定义校验规则及其翻译
+type rule struct {
+	tag string
+	fn  validator.Func
+	// messages 各语言的消息模板，locale -> key -> 模板；key 通常与 tag 相同，{0} 为字段名
+	messages map[string]map[string]string
+	// translate 生成消息，为 nil 时使用 tag 对应模板并以 fe.Param() 作为 {1}
+	translate func(t ut.Translator, fe validator.FieldError) (string, error)
+	// schema 规则在 OpenAPI 文档中的表示
+	schema openapi.RuleFunc
+}
+
+// rules 返回 NewCustomValidator 注册的自定义规则，now 为 before/after 比较的当前时间
+//
+//	after[=d]               时间晚于 now+d（d 为 time.ParseDuration 格式，可为负），省略 d 时晚于当前时间
+//	before[=d]              时间早于 now+d
+//	date_range=Start [max]  时间晚于同一结构体的 Start 字段，且与其间隔不超过 max；Start 为空时不校验
+//	safe_url                仅 http/https、不含账号密码、不指向 localhost 与内网 IP 的 URL
+//	alias                   3-32 位字母、数字、- 或 _，首尾为字母或数字，且不在 ReservedAliases 中
+//	iana_tz                 IANA 时区名，如 Asia/Shanghai
+//	phone_e164              E.164 格式电话号码，如 +8613800138000
+//	duration[=max]          time.ParseDuration 格式的正时长字符串，如 15m，给出 max 时不能超过 max
+func rules(now func() time.Time) []rule {
+	return []rule{
+		{
+			tag: "after",
+			fn:  timeBound(now, true),
+			messages: map[string]map[string]string{
+				"en": {"after": "{0} must be later than {1}", "after_now": "{0} must be a time in the future", "now": "now"},
+				"zh": {"after": "{0}必须晚于{1}", "after_now": "{0}必须是将来的时间", "now": "当前时间"},
+			},
+			translate: translateTimeBound,
+			schema:    describeTimeBound("晚于"),
+		},
+		{
+			tag: "before",
+			fn:  timeBound(now, false),
+			messages: map[string]map[string]string{
+				"en": {"before": "{0} must be earlier than {1}", "before_now": "{0} must be a time in the past", "now": "now"},
+				"zh": {"before": "{0}必须早于{1}", "before_now": "{0}必须是过去的时间", "now": "当前时间"},
+			},
+			translate: translateTimeBound,
+			schema:    describeTimeBound("早于"),
+		},
+		{
+			tag: "date_range",
+			fn:  dateRange,
+			messages: map[string]map[string]string{
+				"en": {
+					"date_range":      "{0} must be later than {1}",
+					"date_range_span": "{0} must be later than {1} and at most {2} after it",
+				},
+				"zh": {
+					"date_range":      "{0}必须晚于{1}",
+					"date_range_span": "{0}必须晚于{1}，且间隔不超过{2}",
+				},
+			},
+			translate: translateDateRange,
+			schema: func(s *openapi.Schema, param string) {
+				start, span, _ := strings.Cut(param, " ")
+				if span == "" {
+					openapi.Describe("须晚于 "+start)(s, param)
+					return
+				}
+				openapi.Describe("须晚于 "+start+"，间隔不超过 "+span)(s, param)
+			},
+		},
+		{
+			tag: "safe_url",
+			fn:  safeURL,
+			messages: map[string]map[string]string{
+				"en": {"safe_url": "{0} must be a public HTTP or HTTPS URL without credentials"},
+				"zh": {"safe_url": "{0}必须是不含账号密码的公网 HTTP 或 HTTPS URL"},
+			},
+			schema: func(s *openapi.Schema, param string) {
+				openapi.Format("uri")(s, param)
+				openapi.Describe("仅限公网 http/https 地址，不含账号密码")(s, param)
+			},
+		},
+		{
+			tag: "alias",
+			fn:  validAlias,
+			messages: map[string]map[string]string{
+				"en": {"alias": "{0} must be 3-32 letters, digits, '-' or '_' starting and ending with a letter or digit, and not a reserved word"},
+				"zh": {"alias": "{0}必须是 3 到 32 位字母、数字、- 或 _，以字母或数字开头和结尾，且不能是保留字"},
+			},
+			schema: func(s *openapi.Schema, param string) {
+				openapi.Pattern(aliasRegex.String())(s, param)
+				openapi.Describe("不能是保留字: "+strings.Join(ReservedAliases, ", "))(s, param)
+			},
+		},
+		{
+			tag: "iana_tz",
+			fn:  ianaTimeZone,
+			messages: map[string]map[string]string{
+				"en": {"iana_tz": "{0} must be a valid IANA time zone such as Asia/Shanghai"},
+				"zh": {"iana_tz": "{0}必须是有效的 IANA 时区，如 Asia/Shanghai"},
+			},
+			schema: openapi.Describe("IANA 时区名，如 Asia/Shanghai"),
+		},
+		{
+			tag: "phone_e164",
+			fn:  func(fl validator.FieldLevel) bool { return e164Regex.MatchString(fl.Field().String()) },
+			messages: map[string]map[string]string{
+				"en": {"phone_e164": "{0} must be a phone number in E.164 format such as +8613800138000"},
+				"zh": {"phone_e164": "{0}必须是 E.164 格式的电话号码，如 +8613800138000"},
+			},
+			schema: openapi.Pattern(e164Regex.String()),
+		},
+		{
+			tag: "duration",
+			fn:  durationString,
+			messages: map[string]map[string]string{
+				"en": {"duration": "{0} must be a duration such as 15m", "duration_max": "{0} must be a duration such as 15m, at most {1}"},
+				"zh": {"duration": "{0}必须是时长，如 15m", "duration_max": "{0}必须是不超过 {1} 的时长，如 15m"},
+			},
+			translate: func(t ut.Translator, fe validator.FieldError) (string, error) {
+				if fe.Param() == "" {
+					return t.T("duration", fe.Field())
+				}
+				return t.T("duration_max", fe.Field(), fe.Param())
+			},
+			schema: func(s *openapi.Schema, param string) {
+				text := "时长，如 15m"
+				if param != "" {
+					text += "，不超过 " + param
+				}
+				openapi.Describe(text)(s, param)
+			},
+		},
+	}
+}
+
+// SchemaRules 返回自定义规则在 OpenAPI 文档中的表示，供 openapi.Document.Rule 注册
+func SchemaRules() map[string]openapi.RuleFunc {
+	out := map[string]openapi.RuleFunc{}
+	for _, r := range rules(time.Now) {
+		if r.schema != nil {
+			out[r.tag] = r.schema
+		}
+	}
+	return out
+}
+
+// describeTimeBound 把 after=1h 描述为“须晚于当前时间 + 1h”
+func describeTimeBound(relation string) openapi.RuleFunc {
+	return func(s *openapi.Schema, param string) {
+		bound := "当前时间"
+		if rest, ok := strings.CutPrefix(param, "-"); ok {
+			bound += " - " + rest
+		} else if param != "" {
+			bound += " + " + strings.TrimPrefix(param, "+")
+		}
+		openapi.Describe("须"+relation+bound)(s, param)
+	}
+}
+
+// timeBound after/before 规则：参数为相对当前时间的偏移，格式错误属于编程错误，与内置规则一样 panic
+func timeBound(now func() time.Time, after bool) validator.Func {
+	return func(fl validator.FieldLevel) bool {
+		t, ok := fl.Field().Interface().(time.Time)
+		if !ok {
+			return false
+		}
+		bound := now().Add(parseDurationParam(fl.Param()))
+		if after {
+			return t.After(bound)
+		}
+		return t.Before(bound)
+	}
+}
+
+// translateTimeBound 无参数时为“必须是将来/过去的时间”，有参数时把 after=1h 表示为“now + 1h”
+func translateTimeBound(t ut.Translator, fe validator.FieldError) (string, error) {
+	p := fe.Param()
+	if p == "" {
+		return t.T(fe.Tag()+"_now", fe.Field())
+	}
+	bound, err := t.T("now")
+	if err != nil {
+		return "", err
+	}
+	if rest, ok := strings.CutPrefix(p, "-"); ok {
+		bound += " - " + rest
+	} else {
+		bound += " + " + strings.TrimPrefix(p, "+")
+	}
+	return t.T(fe.Tag(), fe.Field(), bound)
+}
+
+func dateRange(fl validator.FieldLevel) bool {
+	end, ok := fl.Field().Interface().(time.Time)
+	if !ok {
+		return false
+	}
+	name, span, _ := strings.Cut(fl.Param(), " ")
+	field, kind, _, found := fl.GetStructFieldOKAdvanced2(fl.Parent(), name)
+	if !found {
+		return false
+	}
+	if kind == reflect.Ptr {
+		// 起始时间为 nil：没有可比较的范围
+		return true
+	}
+	start, ok := field.Interface().(time.Time)
+	if !ok || start.IsZero() {
+		return ok
+	}
+	if !end.After(start) {
+		return false
+	}
+	return span == "" || end.Sub(start) <= parseDurationParam(span)
+}
+
+func translateDateRange(t ut.Translator, fe validator.FieldError) (string, error) {
+	start, span, _ := strings.Cut(fe.Param(), " ")
+	if span == "" {
+		return t.T("date_range", fe.Field(), start)
+	}
+	return t.T("date_range_span", fe.Field(), start, span)
+}
+
+// dateRangeError date_range 的校验错误，Param 中起始字段的结构体字段名已换成 fieldName 得到的名称，
+// 避免消息中出现 StartsAt 这类 Go 字段名
+type dateRangeError struct {
+	validator.FieldError
+	param string
+}
+
+func (e dateRangeError) Param() string {
+	return e.param
+}
+
+func (e dateRangeError) Translate(t ut.Translator) string {
+	msg, err := translateDateRange(t, e)
+	if err != nil {
+		return e.Error()
+	}
+	return msg
+}
+
+// renameDateRangeFields 将 verrs 中 date_range 错误引用的起始字段换成 fieldName 得到的名称；
+// root 为被校验值的类型，找不到起始字段时保留原参数
+func renameDateRangeFields(root reflect.Type, verrs validator.ValidationErrors) {
+	for i, fe := range verrs {
+		if fe.Tag() != "date_range" {
+			continue
+		}
+		parent, ok := parentStruct(root, fe.StructNamespace())
+		if !ok {
+			continue
+		}
+		start, span, _ := strings.Cut(fe.Param(), " ")
+		f, ok := parent.FieldByName(start)
+		if !ok {
+			continue
+		}
+		name := fieldName(f)
+		if name == "" {
+			continue
+		}
+		if span != "" {
+			name += " " + span
+		}
+		verrs[i] = dateRangeError{FieldError: fe, param: name}
+	}
+}
+
+// parentStruct 沿 StructNamespace（如 Req.Items[0].EndsAt）找到错误字段所在的结构体类型
+func parentStruct(root reflect.Type, namespace string) (reflect.Type, bool) {
+	parts := strings.Split(namespace, ".")
+	if len(parts) < 2 {
+		return nil, false
+	}
+	t := indirectType(root)
+	for _, part := range parts[1 : len(parts)-1] {
+		if t.Kind() != reflect.Struct {
+			return nil, false
+		}
+		name, _, _ := strings.Cut(part, "[")
+		f, ok := t.FieldByName(name)
+		if !ok {
+			return nil, false
+		}
+		t = indirectType(f.Type)
+		// Items[0]、M[key] 等：每层下标取一次元素类型
+		for n := strings.Count(part, "["); n > 0; n-- {
+			switch t.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+				t = indirectType(t.Elem())
+			default:
+				return nil, false
+			}
+		}
+	}
+	return t, t.Kind() == reflect.Struct
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func parseDurationParam(param string) time.Duration {
+	if param == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(param)
+	if err != nil {
+		panic("validator: invalid duration parameter " + param + ": " + err.Error())
+	}
+	return d
+}
+
+func durationString(fl validator.FieldLevel) bool {
+	d, err := time.ParseDuration(fl.Field().String())
+	if err != nil || d <= 0 {
+		return false
+	}
+	return fl.Param() == "" || d <= parseDurationParam(fl.Param())
+}
+
+// safeURL 只检查 URL 本身，不解析 DNS：域名解析到内网地址（含 DNS rebinding）需在发起请求时另行检查
+func safeURL(fl validator.FieldLevel) bool {
+	u, err := url.Parse(fl.Field().String())
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User != nil || u.Opaque != "" {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "" || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return publicAddr(addr)
+	}
+	// 顶级域不会是纯数字或 0x 开头；这类主机名是 2130706433、0x7f.1 等 IP 的简写，会被解析器当作地址
+	labels := strings.Split(host, ".")
+	tld := labels[len(labels)-1]
+	return strings.Trim(tld, "0123456789") != "" && !strings.HasPrefix(tld, "0x")
+}
+
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+func validAlias(fl validator.FieldLevel) bool {
+	alias := fl.Field().String()
+	if !aliasRegex.MatchString(alias) {
+		return false
+	}
+	for _, word := range ReservedAliases {
+		if strings.EqualFold(alias, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// ianaTimeZone 与内置 timezone 不同，不接受 Local（取决于服务器配置）
+func ianaTimeZone(fl validator.FieldLevel) bool {
+	name := fl.Field().String()
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
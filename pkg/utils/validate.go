@@ -1,25 +1,170 @@
 package utils
 
 import (
+	"cmp"
+	"errors"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	zh_translations "github.com/go-playground/validator/v10/translations/zh"
 )
 
+// builtinTranslations validator 未内置翻译的内置规则的消息，{0} 为字段名，{1} 为规则参数
+var builtinTranslations = map[string]map[string]string{
+	"http_url": {
+		"en": "{0} must be a valid HTTP or HTTPS URL",
+		"zh": "{0}必须是有效的 HTTP 或 HTTPS URL",
+	},
+}
+
 type CustomValidator struct {
 	Validator *validator.Validate
+	// Translator 各语言的校验错误消息，目前支持 en、zh，其他语言使用 en
+	Translator *ut.UniversalTranslator
 }
 
+// NewCustomValidator 创建注册了自定义规则（见 rules）与 en、zh 翻译的校验器
 func NewCustomValidator() *CustomValidator {
+	return newCustomValidator(time.Now)
+}
+
+func newCustomValidator(now func() time.Time) *CustomValidator {
 	v := validator.New()
-	v.RegisterValidation("after", func(fl validator.FieldLevel) bool {
-		startTime, ok := fl.Field().Interface().(time.Time)
-		return ok && startTime.After(time.Now())
-	})
+	v.RegisterTagNameFunc(fieldName)
+	custom := rules(now)
+	for _, r := range custom {
+		if err := v.RegisterValidation(r.tag, r.fn); err != nil {
+			panic("validator: register " + r.tag + ": " + err.Error())
+		}
+	}
+
+	enLocale := en.New()
+	uni := ut.New(enLocale, enLocale, zh.New())
+	registerTranslations(v, uni, "en", en_translations.RegisterDefaultTranslations, custom)
+	registerTranslations(v, uni, "zh", zh_translations.RegisterDefaultTranslations, custom)
 	return &CustomValidator{
-		Validator: v,
+		Validator:  v,
+		Translator: uni,
 	}
 }
 func (cv *CustomValidator) Validate(i interface{}) error {
-	return cv.Validator.Struct(i)
+	err := cv.Validator.Struct(i)
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		renameDateRangeFields(reflect.TypeOf(i), verrs)
+	}
+	return err
+}
+
+// TranslateField 按 Accept-Language 返回字段校验错误的可读消息，实现 problem.FieldTranslator
+func (cv *CustomValidator) TranslateField(fe validator.FieldError, acceptLanguage string) string {
+	trans, _ := cv.Translator.FindTranslator(acceptedLocales(acceptLanguage)...)
+	return fe.Translate(trans)
+}
+
+// registerTranslations 注册 locale 的内置翻译、builtinTranslations 与自定义规则的翻译；只在启动时执行，失败说明翻译模板有误
+func registerTranslations(v *validator.Validate, uni *ut.UniversalTranslator, locale string,
+	defaults func(*validator.Validate, ut.Translator) error, custom []rule) {
+	trans, _ := uni.GetTranslator(locale)
+	if err := defaults(v, trans); err != nil {
+		panic("validator: register " + locale + " translations: " + err.Error())
+	}
+	for tag, texts := range builtinTranslations {
+		if text, ok := texts[locale]; ok {
+			custom = append(custom, rule{tag: tag, messages: map[string]map[string]string{locale: {tag: text}}})
+		}
+	}
+	// 多个规则共用的 key（如 now）只添加一次；不能用 t.T(key) 探测，带 {0} 占位的模板缺参数时会 panic
+	added := make(map[string]bool)
+	for _, r := range custom {
+		messages, ok := r.messages[locale]
+		if !ok {
+			continue
+		}
+		translate := r.translate
+		if translate == nil {
+			translate = func(t ut.Translator, fe validator.FieldError) (string, error) {
+				return t.T(fe.Tag(), fe.Field(), fe.Param())
+			}
+		}
+		err := v.RegisterTranslation(r.tag, trans,
+			func(t ut.Translator) error {
+				for key, text := range messages {
+					if added[key] {
+						continue
+					}
+					if err := t.Add(key, text, false); err != nil {
+						return err
+					}
+					added[key] = true
+				}
+				return nil
+			},
+			func(t ut.Translator, fe validator.FieldError) string {
+				msg, err := translate(t, fe)
+				if err != nil {
+					return fe.Error()
+				}
+				return msg
+			})
+		if err != nil {
+			panic("validator: register " + locale + " translation for " + r.tag + ": " + err.Error())
+		}
+	}
+}
+
+// fieldName 校验错误中使用的字段名：依次取 json、query、param、form tag，都没有时使用结构体字段名
+func fieldName(f reflect.StructField) string {
+	for _, key := range []string{"json", "query", "param", "form"} {
+		name, _, _ := strings.Cut(f.Tag.Get(key), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// acceptedLocales 按 q 值从高到低解析 Accept-Language，如 "zh-CN,zh;q=0.9,en;q=0.8" 得到 zh_cn、zh、zh、en，
+// 每个地区标签后附带其基础语言，便于匹配只注册了基础语言的翻译
+func acceptedLocales(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag: strings.ToLower(strings.ReplaceAll(tag, "-", "_")), q: q})
+		}
+	}
+	slices.SortStableFunc(tags, func(a, b weighted) int { return cmp.Compare(b.q, a.q) })
+	locales := make([]string, 0, 2*len(tags))
+	for _, t := range tags {
+		locales = append(locales, t.tag)
+		if base, _, ok := strings.Cut(t.tag, "_"); ok {
+			locales = append(locales, base)
+		}
+	}
+	return locales
 }
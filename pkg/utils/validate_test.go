@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"echotest/pkg/problem"
+
+	"github.com/go-playground/locales/en"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v5"
+)
+
+type createThingRequest struct {
+	TargetURL string     `json:"target_url" validate:"required,http_url"`
+	Name      string     `json:"name,omitempty" validate:"required,max=5"`
+	Limit     int        `query:"limit" validate:"omitempty,max=100"`
+	ExpiresAt *time.Time `json:"expires_at" validate:"omitempty,after"`
+}
+
+func validationErrors(t *testing.T, cv *CustomValidator, req any) validator.ValidationErrors {
+	t.Helper()
+	var verrs validator.ValidationErrors
+	if err := cv.Validate(req); !errors.As(err, &verrs) {
+		t.Fatalf("期望 ValidationErrors，得到 %v", err)
+	}
+	return verrs
+}
+
+func TestCustomValidator_FieldNamesFromTags(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	verrs := validationErrors(t, NewCustomValidator(), createThingRequest{Name: "too long", Limit: 101, ExpiresAt: &past})
+	var fields []string
+	for _, fe := range verrs {
+		fields = append(fields, fe.Field())
+	}
+	if want := []string{"target_url", "name", "limit", "expires_at"}; !slices.Equal(fields, want) {
+		t.Errorf("字段名应取自 json/query tag，期望 %v，得到 %v", want, fields)
+	}
+}
+
+func TestCustomValidator_TranslateField(t *testing.T) {
+	cv := NewCustomValidator()
+	past := time.Now().Add(-time.Hour)
+	verrs := validationErrors(t, cv, createThingRequest{TargetURL: "ftp://x", Name: "too long", ExpiresAt: &past})
+
+	cases := []struct {
+		lang string
+		want []string
+	}{
+		{"zh-CN,zh;q=0.9,en;q=0.8", []string{"target_url必须是有效的 HTTP 或 HTTPS URL", "name长度不能超过5个字符", "expires_at必须是将来的时间"}},
+		{"en-US,en;q=0.9", []string{"target_url must be a valid HTTP or HTTPS URL", "name must be a maximum of 5 characters in length", "expires_at must be a time in the future"}},
+		// q 值优先于顺序
+		{"en;q=0.5, zh", []string{"target_url必须是有效的 HTTP 或 HTTPS URL", "name长度不能超过5个字符", "expires_at必须是将来的时间"}},
+		// 不支持的语言与缺省时使用英文
+		{"fr-FR", []string{"target_url must be a valid HTTP or HTTPS URL", "name must be a maximum of 5 characters in length", "expires_at must be a time in the future"}},
+		{"", []string{"target_url must be a valid HTTP or HTTPS URL", "name must be a maximum of 5 characters in length", "expires_at must be a time in the future"}},
+	}
+	for _, tc := range cases {
+		var got []string
+		for _, fe := range verrs {
+			got = append(got, cv.TranslateField(fe, tc.lang))
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("Accept-Language %q: 期望 %q，得到 %q", tc.lang, tc.want, got)
+		}
+	}
+}
+
+func TestRegisterTranslations_SharedTemplatedKey(t *testing.T) {
+	v := validator.New()
+	uni := ut.New(en.New())
+	shared := map[string]map[string]string{"en": {"weekday": "{0} must be a weekday"}}
+	custom := []rule{{tag: "workday", messages: shared}, {tag: "office_day", messages: shared}}
+	noDefaults := func(*validator.Validate, ut.Translator) error { return nil }
+	// 第二个规则登记共用的带占位 key 时不应 panic 或报重复
+	registerTranslations(v, uni, "en", noDefaults, custom)
+
+	trans, _ := uni.GetTranslator("en")
+	if msg, err := trans.T("weekday", "start"); err != nil || msg != "start must be a weekday" {
+		t.Errorf("共用 key 应只登记一次，得到 %q, %v", msg, err)
+	}
+}
+
+func TestAcceptedLocales(t *testing.T) {
+	cases := map[string][]string{
+		"zh-CN,zh;q=0.9,en;q=0.8": {"zh_cn", "zh", "zh", "en"},
+		"en;q=0.1, zh-TW":         {"zh_tw", "zh", "en"},
+		"*, de;q=0, ja":           {"ja"},
+		"":                        {},
+	}
+	for header, want := range cases {
+		if got := acceptedLocales(header); !slices.Equal(got, want) {
+			t.Errorf("%q: 期望 %v，得到 %v", header, want, got)
+		}
+	}
+}
+
+// 通过 problem.HTTPErrorHandler 输出时，errors[].message 按 Accept-Language 翻译
+func TestValidationProblem_LocalizedMessages(t *testing.T) {
+	e := echo.New()
+	e.Validator = NewCustomValidator()
+	e.HTTPErrorHandler = problem.HTTPErrorHandler(&problem.Registry{})
+	e.POST("/things", func(c *echo.Context) error {
+		var req createThingRequest
+		if err := c.Bind(&req); err != nil {
+			return err
+		}
+		return c.Validate(&req)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/things", strings.NewReader(`{"target_url":"https://example.com"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("Accept-Language", "zh-CN")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	var body struct {
+		Errors []problem.FieldError `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	want := []problem.FieldError{{Field: "name", Rule: "required", Message: "name为必填字段"}}
+	if rec.Code != http.StatusBadRequest || !slices.Equal(body.Errors, want) {
+		t.Errorf("期望 400 %+v，得到 %d %s", want, rec.Code, rec.Body.String())
+	}
+}